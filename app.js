@@ -1679,7 +1679,16 @@ async function detectWebgpu(onProgress) {
   }
 
   webgpu.adapterFeatures = Array.from(adapter.features || []).sort();
-  webgpu.limits = adapter.limits ? Object.fromEntries(Object.entries(adapter.limits)) : null;
+  webgpu.limits = (() => {
+    if (!adapter.limits) return null;
+    // GPUSupportedLimits exposes its members as prototype getters, so Object.entries() sees nothing.
+    const out = {};
+    for (const key in adapter.limits) {
+      const v = adapter.limits[key];
+      if (typeof v === "number") out[key] = v;
+    }
+    return out;
+  })();
 
   try {
     if (typeof navigator.gpu.getPreferredCanvasFormat === "function") {
@@ -1690,8 +1699,15 @@ async function detectWebgpu(onProgress) {
   }
 
   try {
-    if (typeof adapter.requestAdapterInfo === "function") {
-      webgpu.adapterInfo = await adapter.requestAdapterInfo();
+    // GPUAdapterInfo members are prototype getters; copy them so the report serializes.
+    const info = adapter.info || (typeof adapter.requestAdapterInfo === "function" ? await adapter.requestAdapterInfo() : null);
+    if (info) {
+      webgpu.adapterInfo = {
+        vendor: info.vendor || "",
+        architecture: info.architecture || "",
+        device: info.device || "",
+        description: info.description || "",
+      };
     }
   } catch (err) {
     webgpu.warnings.push(`requestAdapterInfo() not available/blocked: ${String(err)}`);
@@ -2365,6 +2381,8 @@ async function run() {
   renderStructuredWebglInfo(webgl1, dom.webgl1Structured);
 
   lastReport = {
+    // Bump with currentReportSchemaVersion in schema_version.go when the report shape changes.
+    schemaVersion: 3,
     generatedAt: new Date().toISOString(),
     userAgent: navigator.userAgent,
     client,
@@ -2377,6 +2395,7 @@ async function run() {
       colorGamutRec2020: client?.display?.colorGamutRec2020 === true,
       colorGamutP3: client?.display?.colorGamutP3 === true,
       colorGamutSRGB: client?.display?.colorGamutSRGB === true,
+      hdrVideoDecoding: client?.display?.hdrVideoDecoding || null,
     },
     webgpu,
     webgl2,
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// asnDB tags submissions with the submitter's autonomous system from a local
+// MaxMind GeoLite2-ASN or DB-IP ASN database, and flags cloud and hosting
+// networks: CI bots and headless browsers on rented machines report
+// SwiftShader-style capabilities that skew the numbers for real users.
+type asnDB struct {
+	*mmdbFile
+	datacenter map[uint]bool
+}
+
+type mmdbASNRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// datacenterASNs are the large public clouds and hosting providers. Networks
+// that also carry end users (Cloudflare WARP, mobile carriers) are left out on
+// purpose; -datacenter-asns adds local ones.
+var datacenterASNs = map[uint]string{
+	16509:  "Amazon",
+	14618:  "Amazon",
+	8987:   "Amazon",
+	15169:  "Google",
+	19527:  "Google",
+	396982: "Google Cloud",
+	8075:   "Microsoft",
+	31898:  "Oracle",
+	36351:  "IBM SoftLayer",
+	14061:  "DigitalOcean",
+	63949:  "Akamai (Linode)",
+	20473:  "Vultr",
+	24940:  "Hetzner",
+	213230: "Hetzner",
+	16276:  "OVH",
+	12876:  "Scaleway",
+	51167:  "Contabo",
+	45102:  "Alibaba",
+	37963:  "Alibaba",
+	132203: "Tencent",
+	45090:  "Tencent",
+	9009:   "M247",
+	60068:  "Datacamp (CDN77)",
+	36352:  "ColoCrossing",
+	53667:  "FranTech",
+}
+
+// datacenterOrgKeywords catch smaller hosting providers by name.
+var datacenterOrgKeywords = []string{"hosting", "datacenter", "data center", "colocation", "vps", "dedicated server"}
+
+func openASNDB(path string, extra []uint) (*asnDB, error) {
+	f, err := openMMDBFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("asn db: %w", err)
+	}
+	db := &asnDB{mmdbFile: f, datacenter: make(map[uint]bool)}
+	for asn := range datacenterASNs {
+		db.datacenter[asn] = true
+	}
+	for _, asn := range extra {
+		db.datacenter[asn] = true
+	}
+	return db, nil
+}
+
+// parseASNList reads a comma-separated list of AS numbers ("16509" or
+// "AS16509").
+func parseASNList(s string) ([]uint, error) {
+	var out []uint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		digits := part
+		if len(digits) > 2 && strings.EqualFold(digits[:2], "AS") {
+			digits = digits[2:]
+		}
+		n, err := strconv.ParseUint(digits, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid ASN %q", part)
+		}
+		out = append(out, uint(n))
+	}
+	return out, nil
+}
+
+// Lookup fills the ASN fields of geo; ok is false when the address isn't in
+// the database.
+func (db *asnDB) Lookup(ipStr string, geo *GeoInfo) (ok bool) {
+	ip := net.ParseIP(strings.Trim(ipStr, "[]"))
+	if ip == nil {
+		return false
+	}
+	var rec mmdbASNRecord
+	if err := db.lookup(ip, &rec); err != nil || rec.Number == 0 {
+		return false
+	}
+	geo.ASN = rec.Number
+	geo.ASOrg = clampString(rec.Org, 128)
+	geo.Datacenter = db.isDatacenter(rec.Number, rec.Org)
+	return true
+}
+
+func (db *asnDB) isDatacenter(asn uint, org string) bool {
+	if db.datacenter[asn] {
+		return true
+	}
+	org = strings.ToLower(org)
+	for _, kw := range datacenterOrgKeywords {
+		if strings.Contains(org, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverGeo builds the Geo section stored with a submission from the country
+// resolver chain and the ASN database. Client-provided geo fields are never
+// trusted; nil means nothing is known.
+func (s *Store) serverGeo(ctx context.Context, now time.Time, ip string, r *http.Request) *GeoInfo {
+	var geo GeoInfo
+	if s.geo != nil {
+		geo.CountryCode = s.geo.CountryCode(ctx, now, ip, r)
+	}
+	if s.asn != nil {
+		s.asn.Lookup(ip, &geo)
+	}
+	if geo == (GeoInfo{}) {
+		return nil
+	}
+	return &geo
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestASNDBLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	asn := func(n uint32, org string) map[string]any {
+		return map[string]any{"autonomous_system_number": n, "autonomous_system_organization": org}
+	}
+	writeTestMMDB(t, path, "GeoLite2-ASN", []testMMDBEntry{
+		{"3.0.0.0/15", asn(16509, "AMAZON-02")},
+		{"24.0.0.0/12", asn(7922, "COMCAST-7922")},
+		{"45.0.0.0/16", asn(64500, "Example Hosting Ltd")},
+		{"62.0.0.0/16", asn(64501, "Example Telecom")},
+	})
+	db, err := openASNDB(path, []uint{64501})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		ok   bool
+		want GeoInfo
+	}{
+		{"3.1.2.3", true, GeoInfo{ASN: 16509, ASOrg: "AMAZON-02", Datacenter: true}},
+		{"24.5.6.7", true, GeoInfo{ASN: 7922, ASOrg: "COMCAST-7922"}},
+		{"45.0.1.1", true, GeoInfo{ASN: 64500, ASOrg: "Example Hosting Ltd", Datacenter: true}},
+		{"62.0.1.1", true, GeoInfo{ASN: 64501, ASOrg: "Example Telecom", Datacenter: true}},
+		{"[24.5.6.7]", true, GeoInfo{ASN: 7922, ASOrg: "COMCAST-7922"}},
+		{"9.9.9.9", false, GeoInfo{CountryCode: "DE"}},
+		{"not-an-ip", false, GeoInfo{CountryCode: "DE"}},
+	}
+	for _, tt := range tests {
+		geo := GeoInfo{CountryCode: "DE"}
+		ok := db.Lookup(tt.ip, &geo)
+		if tt.ok {
+			tt.want.CountryCode = "DE"
+		}
+		if ok != tt.ok || geo != tt.want {
+			t.Errorf("Lookup(%q) = %v, %+v; want %v, %+v", tt.ip, ok, geo, tt.ok, tt.want)
+		}
+	}
+}
+
+func TestParseASNList(t *testing.T) {
+	got, err := parseASNList(" 16509, AS14618 ,as8075,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{16509, 14618, 8075}; !slices.Equal(got, want) {
+		t.Errorf("parseASNList = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"AS", "0", "ASx", "16509,amazon", "4294967296"} {
+		if _, err := parseASNList(bad); err == nil {
+			t.Errorf("parseASNList(%q): want error", bad)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type BatchResponse struct {
+	Accepted    int               `json:"accepted"`
+	Duplicates  int               `json:"duplicates"`
+	Rejected    int               `json:"rejected"`
+	RateLimited int               `json:"rateLimited"`
+	Results     []batchItemResult `json:"results"`
+	CountryCode string            `json:"countryCode,omitempty"`
+}
+
+type batchItemResult struct {
+	Index       int    `json:"index"`
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Details     string `json:"details,omitempty"`
+}
+
+// handleReportBatch accepts several reports from one client, either as a JSON
+// array or as NDJSON. Every item is validated and stored on its own and takes
+// one token from the client's rate limit bucket.
+func handleReportBatch(w http.ResponseWriter, r *http.Request, store *Store) {
+	now := time.Now()
+	ip := clientIP(r)
+
+	if !allowOrigin(r) {
+		store.Reject(now)
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": "forbidden origin"})
+		return
+	}
+
+	ndjson := false
+	ct := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Type")))
+	switch {
+	case ct == "", strings.HasPrefix(ct, "application/json"):
+		// Sniffed below: a leading '[' is an array, anything else NDJSON.
+	case strings.HasPrefix(ct, "application/x-ndjson"), strings.HasPrefix(ct, "application/ndjson"), strings.HasPrefix(ct, "application/jsonl"):
+		ndjson = true
+	default:
+		store.Reject(now)
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{"error": "content-type must be application/json or application/x-ndjson"})
+		return
+	}
+
+	body, err := decodedRequestBody(w, r, store.cfg.BatchMaxBytes)
+	if err != nil {
+		store.Reject(now)
+		writeRequestBodyError(w, err)
+		return
+	}
+	defer body.Close()
+	br := bufio.NewReaderSize(body, 64<<10)
+	if !ndjson {
+		ndjson = !startsWithArray(br)
+	}
+
+	geo := store.serverGeo(r.Context(), now, ip, r)
+
+	resp := BatchResponse{Results: []batchItemResult{}}
+	if geo != nil {
+		resp.CountryCode = geo.CountryCode
+	}
+	submit := func(raw []byte) {
+		res := store.submitBatchItem(now, ip, geo, raw)
+		res.Index = len(resp.Results)
+		switch res.Status {
+		case "accepted":
+			resp.Accepted += 1
+		case "duplicate":
+			resp.Duplicates += 1
+		case "rate_limited":
+			resp.RateLimited += 1
+		default:
+			resp.Rejected += 1
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	if ndjson {
+		err = forEachNDJSONItem(br, int(store.cfg.MaxBodyBytes), submit)
+	} else {
+		err = forEachArrayItem(br, submit)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, map[string]any{"error": "invalid body", "details": err.Error(), "partial": resp})
+		return
+	}
+	if len(resp.Results) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "empty batch"})
+		return
+	}
+	status := http.StatusOK
+	if resp.RateLimited == len(resp.Results) {
+		status = http.StatusTooManyRequests
+	}
+	writeJSON(w, status, resp)
+}
+
+func (s *Store) submitBatchItem(now time.Time, ip string, geo *GeoInfo, raw []byte) batchItemResult {
+	if !s.allowIP(now, ip) {
+		s.RateLimited(now)
+		return batchItemResult{Status: "rate_limited", Error: "rate limited"}
+	}
+	if int64(len(raw)) > s.cfg.MaxBodyBytes {
+		s.Reject(now)
+		return batchItemResult{Status: "rejected", Error: "invalid report", Details: fmt.Sprintf("report exceeds %d bytes", s.cfg.MaxBodyBytes)}
+	}
+
+	report, reason, err := decodeReport(raw)
+	if err != nil {
+		s.Reject(now)
+		return batchItemResult{Status: "rejected", Error: reason, Details: err.Error()}
+	}
+	// Never trust client-provided geo fields; each item gets its own copy of
+	// the server-derived ones.
+	report.Geo = nil
+	if geo != nil {
+		g := *geo
+		report.Geo = &g
+	}
+
+	res, err := s.SubmitRaw(now, ip, report, raw)
+	if err != nil {
+		return batchItemResult{Status: "rejected", Error: "failed to store report", Details: err.Error()}
+	}
+	return batchItemResult{Status: res.Status, Fingerprint: res.Fingerprint, Message: res.Message}
+}
+
+// startsWithArray reports whether the first non-space byte is '['.
+func startsWithArray(br *bufio.Reader) bool {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		_ = br.UnreadByte()
+		return b == '['
+	}
+}
+
+func forEachArrayItem(r io.Reader, fn func(raw []byte)) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array")
+	}
+	for dec.More() {
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		fn(item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return ensureEOF(dec)
+}
+
+func forEachNDJSONItem(r io.Reader, maxLine int, fn func(raw []byte)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxLine+1)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		fn(bytes.Clone(line))
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("line exceeds %d bytes", maxLine)
+		}
+		return err
+	}
+	return nil
+}
@@ -383,8 +383,14 @@ function columnTitleLines(col, groupBy) {
   const dt = String(col?.deviceType || "").trim();
   const os = String(col?.os || "").trim();
   const br = String(col?.browser || "").trim();
+  const gv = String(col?.gpuVendor || "").trim();
+  const ga = String(col?.gpuArchitecture || "").trim();
 
   switch (groupBy) {
+    case "gpu_vendor":
+      return [gv || "Unknown"];
+    case "gpu_architecture":
+      return [gv || "Unknown", ga || "Unknown"];
     case "os":
       return [os || "Unknown"];
     case "browser":
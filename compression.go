@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressBytes skips compression where the framing overhead outweighs the
+// savings.
+const minCompressBytes = 1024
+
+// errUnsupportedEncoding is answered with 415.
+var errUnsupportedEncoding = fmt.Errorf("content-encoding must be gzip, br or identity")
+
+// decodedRequestBody returns the request body with any Content-Encoding
+// removed. limit applies to both the bytes on the wire and the decompressed
+// stream, so a small compressed body can't expand past MaxBodyBytes.
+func decodedRequestBody(w http.ResponseWriter, r *http.Request, limit int64) (io.ReadCloser, error) {
+	wire := http.MaxBytesReader(w, r.Body, limit)
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return wire, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(wire)
+		if err != nil {
+			wire.Close()
+			return nil, err
+		}
+		return http.MaxBytesReader(w, decodedBody{Reader: zr, wire: wire}, limit), nil
+	case "br":
+		br := brotli.NewReader(wire)
+		return http.MaxBytesReader(w, decodedBody{Reader: br, wire: wire}, limit), nil
+	default:
+		wire.Close()
+		return nil, errUnsupportedEncoding
+	}
+}
+
+type decodedBody struct {
+	io.Reader
+	wire io.Closer
+}
+
+func (d decodedBody) Close() error { return d.wire.Close() }
+
+// writeRequestBodyError maps body read failures to 415, 413 or 400.
+func writeRequestBodyError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{"error": err.Error()})
+	case errors.As(err, &maxErr):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": "body too large", "details": err.Error()})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body", "details": err.Error()})
+	}
+}
+
+// negotiateEncoding picks br or gzip from Accept-Encoding ("" for identity).
+func negotiateEncoding(r *http.Request) string {
+	best := ""
+	bestQ := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 || (name != "br" && name != "gzip") {
+			continue
+		}
+		// Prefer br on ties: it is noticeably smaller for JSON and JS.
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+func newCompressWriter(w io.Writer, encoding string) io.WriteCloser {
+	if encoding == "br" {
+		return brotli.NewWriterLevel(w, 5)
+	}
+	zw, _ := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	return zw
+}
+
+func compressBytes(body []byte, encoding string) []byte {
+	var buf bytes.Buffer
+	cw := newCompressWriter(&buf, encoding)
+	_, _ = cw.Write(body)
+	_ = cw.Close()
+	return buf.Bytes()
+}
+
+func compressibleContentType(ct string) bool {
+	ct = strings.ToLower(ct)
+	return strings.HasPrefix(ct, "text/") ||
+		strings.Contains(ct, "javascript") ||
+		strings.Contains(ct, "json") ||
+		strings.Contains(ct, "xml") ||
+		strings.HasPrefix(ct, "image/svg")
+}
+
+// compressResponseWriter compresses full (200) responses with a compressible
+// Content-Type; everything else, including ranges, passes through unchanged.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	cw       io.WriteCloser
+	decided  bool
+}
+
+func (c *compressResponseWriter) WriteHeader(status int) {
+	if c.decided {
+		return
+	}
+	c.decided = true
+	h := c.Header()
+	if status == http.StatusOK && h.Get("Content-Encoding") == "" && compressibleContentType(h.Get("Content-Type")) {
+		size, err := strconv.Atoi(h.Get("Content-Length"))
+		if err != nil || size >= minCompressBytes {
+			h.Set("Content-Encoding", c.encoding)
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			c.cw = newCompressWriter(c.ResponseWriter, c.encoding)
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressResponseWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.cw != nil {
+		return c.cw.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *compressResponseWriter) Close() error {
+	if c.cw != nil {
+		return c.cw.Close()
+	}
+	return nil
+}
+
+// withCompression negotiates a response encoding for h.
+func withCompression(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r)
+		if encoding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportWebGPUFormats fixes the per-format CSV columns up front so rows can be
+// streamed: every GPUTextureFormat in textureFormats, in catalog order.
+// WebGL-only formats are only present in the NDJSON export.
+var exportWebGPUFormats = func() []string {
+	var names []string
+	for _, f := range textureFormats {
+		if !f.WebGLOnly {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}()
+
+var exportFormatUsages = []string{"sampled", "filterable", "renderable", "storage"}
+
+var exportMetaColumns = []string{
+	"fingerprint", "createdAt", "receivedAt", "schemaVersion",
+	"browser", "os", "deviceType", "cpuArch", "country", "asn", "asOrg", "datacenter",
+	"gpuVendor", "gpuArchitecture", "gpuFamily", "gpuModel", "gpuBackend", "appleSilicon",
+	"webgpuAvailable", "webgl2Available", "webgl1Available", "hdrDisplay",
+}
+
+// parseExportFormat accepts ndjson (default) or csv.
+func parseExportFormat(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "ndjson", "jsonl":
+		return "ndjson", nil
+	case "csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("format must be ndjson or csv")
+	}
+}
+
+func exportContentType(format string) string {
+	if format == "csv" {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson; charset=utf-8"
+}
+
+// Export streams every report matching filter to w, newest receivedAt first.
+// Client IPs and raw payloads are never written.
+func (s *Store) Export(ctx context.Context, w io.Writer, format string, filter StatsFilter) (int, error) {
+	bw := bufio.NewWriterSize(w, 64<<10)
+	n := 0
+	var err error
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(exportCSVHeader()); err != nil {
+			return 0, err
+		}
+		err = s.reports.Export(ctx, filter, func(sr StoredReport) error {
+			n += 1
+			return cw.Write(exportCSVRow(sr))
+		})
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	default:
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		err = s.reports.Export(ctx, filter, func(sr StoredReport) error {
+			n += 1
+			sr.IP = ""
+			sr.Raw = nil
+			return enc.Encode(sr)
+		})
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// exportResponseWriter records whether any of the body reached the client.
+type exportResponseWriter struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (e *exportResponseWriter) Write(p []byte) (int, error) {
+	e.wrote = true
+	return e.w.Write(p)
+}
+
+func exportCSVHeader() []string {
+	header := make([]string, 0, len(exportMetaColumns)+len(exportWebGPUFormats)*len(exportFormatUsages))
+	header = append(header, exportMetaColumns...)
+	for _, format := range exportWebGPUFormats {
+		for _, usage := range exportFormatUsages {
+			header = append(header, format+":"+usage)
+		}
+	}
+	return header
+}
+
+// exportCSVRow flattens reportMeta plus one cell per format usage: "1" or "0"
+// when the report tested it, empty otherwise.
+func exportCSVRow(sr StoredReport) []string {
+	meta := reportMetaFromReport(sr.Report)
+	// ASN and datacenter are empty when no -asn-db lookup was made.
+	var asn, datacenter string
+	if geo := sr.Report.Geo; geo != nil && geo.ASN != 0 {
+		asn = strconv.FormatUint(uint64(geo.ASN), 10)
+		datacenter = exportBool(meta.Datacenter)
+	}
+	row := make([]string, 0, len(exportMetaColumns)+len(exportWebGPUFormats)*len(exportFormatUsages))
+	row = append(row,
+		sr.Fingerprint,
+		exportTime(sr.CreatedAt),
+		exportTime(sr.ReceivedAt),
+		strconv.Itoa(meta.SchemaVersion),
+		meta.Browser,
+		meta.OS,
+		meta.DeviceType,
+		meta.CPUArch,
+		meta.Country,
+		asn,
+		meta.ASOrg,
+		datacenter,
+		meta.GPUVendor,
+		meta.GPUArchitecture,
+		meta.GPUFamily,
+		meta.GPUModel,
+		meta.GPUBackend,
+		exportBoolPtr(meta.AppleSilicon),
+		exportBool(meta.WebGPUAvailable),
+		exportBool(meta.WebGL2Available),
+		exportBool(meta.WebGL1Available),
+		exportBool(meta.HDRDisplay),
+	)
+
+	byFormat := make(map[string]WebGPUFormat, len(sr.Report.WebGPU.Formats))
+	for _, f := range sr.Report.WebGPU.Formats {
+		byFormat[f.Format] = f
+	}
+	for _, name := range exportWebGPUFormats {
+		f, ok := byFormat[name]
+		if !ok {
+			for range exportFormatUsages {
+				row = append(row, "")
+			}
+			continue
+		}
+		row = append(row,
+			exportBool(f.Sampled),
+			exportBoolPtr(f.Filterable),
+			exportBool(f.Renderable),
+			exportBool(f.Storage),
+		)
+	}
+	return row
+}
+
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func exportBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func exportBoolPtr(v *bool) string {
+	if v == nil {
+		return ""
+	}
+	return exportBool(*v)
+}
+
+// runExportCommand implements `hdr-detection export`, which writes the same
+// stream as GET /api/export to a file.
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	mongoURI := fs.String("mongo-uri", strings.TrimSpace(firstEnv("MONGO_URI", "MONGODB_URI")), "MongoDB connection string (env MONGO_URI/MONGODB_URI)")
+	storeSpec := fs.String("store", strings.TrimSpace(os.Getenv("STORE")), "report store: mongo or sqlite:<path> (env STORE; default mongo when MONGO_URI is set)")
+	format := fs.String("format", "ndjson", "output format: ndjson or csv")
+	out := fs.String("out", "", "output file (default stdout)")
+	filterQuery := fs.String("filter", "", "StatsFilter as a query string, e.g. \"browser=Chrome&webgpuAvailable=true\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exportFormat, err := parseExportFormat(*format)
+	if err != nil {
+		return err
+	}
+	q, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(*filterQuery), "?"))
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	filter := parseStatsFilter(q)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cfg := Config{MaxReports: 2000}
+	reports, err := openReportStore(ctx, *storeSpec, *mongoURI, cfg)
+	if err != nil {
+		return fmt.Errorf("store init: %w", err)
+	}
+	if reports.Name() == "memory" {
+		return fmt.Errorf("nothing to export from the in-memory store; set -store or -mongo-uri")
+	}
+	store := NewStore(cfg, reports)
+
+	if *out == "" {
+		_, err := store.Export(context.Background(), os.Stdout, exportFormat, filter)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		return nil
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	n, err := store.Export(context.Background(), f, exportFormat, filter)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d reports to %s\n", n, *out)
+	return nil
+}
+
+func exportFileName(now time.Time, format string) string {
+	ext := "ndjson"
+	if format == "csv" {
+		ext = "csv"
+	}
+	return "hdr-detection-reports-" + strconv.FormatInt(now.Unix(), 10) + "." + ext
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExportCSVRow(t *testing.T) {
+	header := exportCSVHeader()
+	column := func(row []string, name string) string {
+		t.Helper()
+		i := slices.Index(header, name)
+		if i < 0 {
+			t.Fatalf("no %q column", name)
+		}
+		return row[i]
+	}
+
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tagged := StoredReport{
+		Fingerprint: "fnv1a:abc",
+		ReceivedAt:  received,
+		Report: Report{
+			SchemaVersion: 3,
+			Geo:           &GeoInfo{CountryCode: "DE", ASN: 24940, ASOrg: "Hetzner Online GmbH", Datacenter: true},
+			WebGPU: WebGPUReport{Available: true, Formats: []WebGPUFormat{
+				{Format: "rgba16float", Sampled: true, Filterable: ptr(true), Renderable: true},
+			}},
+		},
+	}
+	untagged := StoredReport{Fingerprint: "fnv1a:def", Report: Report{Geo: &GeoInfo{CountryCode: "US"}}}
+
+	for _, sr := range []StoredReport{tagged, untagged} {
+		if row := exportCSVRow(sr); len(row) != len(header) {
+			t.Fatalf("%s: %d cells for %d columns", sr.Fingerprint, len(row), len(header))
+		}
+	}
+
+	row := exportCSVRow(tagged)
+	for name, want := range map[string]string{
+		"fingerprint":            "fnv1a:abc",
+		"receivedAt":             "2026-03-01T12:00:00Z",
+		"schemaVersion":          "3",
+		"country":                "DE",
+		"asn":                    "24940",
+		"asOrg":                  "Hetzner Online GmbH",
+		"datacenter":             "1",
+		"webgpuAvailable":        "1",
+		"rgba16float:sampled":    "1",
+		"rgba16float:filterable": "1",
+		"rgba16float:storage":    "0",
+		"r8unorm:sampled":        "",
+	} {
+		if got := column(row, name); got != want {
+			t.Errorf("tagged %s = %q, want %q", name, got, want)
+		}
+	}
+
+	row = exportCSVRow(untagged)
+	for name, want := range map[string]string{
+		"schemaVersion": "1",
+		"country":       "US",
+		"asn":           "",
+		"asOrg":         "",
+		"datacenter":    "",
+	} {
+		if got := column(row, name); got != want {
+			t.Errorf("untagged %s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CountryResolver maps a submission to an ISO 3166-1 alpha-2 country code,
+// or "" when it has no answer. ip is clientIP(r).
+type CountryResolver interface {
+	CountryCode(ctx context.Context, now time.Time, ip string, r *http.Request) string
+}
+
+// countryResolverChain asks each resolver in turn and returns the first answer.
+type countryResolverChain []CountryResolver
+
+func (c countryResolverChain) CountryCode(ctx context.Context, now time.Time, ip string, r *http.Request) string {
+	for _, resolver := range c {
+		if code := resolver.CountryCode(ctx, now, ip, r); code != "" {
+			return code
+		}
+	}
+	return ""
+}
+
+// headerCountryResolver trusts CDN/proxy geo headers, but only on requests
+// that came through a trusted proxy.
+type headerCountryResolver struct {
+	names []string
+}
+
+func newHeaderCountryResolver(names []string) headerCountryResolver {
+	if len(names) == 0 {
+		names = defaultGeoHeaders
+	}
+	return headerCountryResolver{names: names}
+}
+
+func (h headerCountryResolver) CountryCode(_ context.Context, _ time.Time, _ string, r *http.Request) string {
+	if !trustProxyHeadersForRequest(r) {
+		return ""
+	}
+	return countryCodeFromHeaders(r.Header, h.names)
+}
+
+func (db *mmdbCountryDB) CountryCode(_ context.Context, _ time.Time, ipStr string, _ *http.Request) string {
+	ip := net.ParseIP(strings.Trim(ipStr, "[]"))
+	if ip == nil {
+		return ""
+	}
+	code, err := db.Lookup(ip)
+	if err != nil {
+		return ""
+	}
+	return code
+}
+
+// countryIsResolver asks api.country.is. Behind a trusted proxy that only
+// reveals a private address (local dev), it resolves the server's own egress
+// IP instead.
+type countryIsResolver struct {
+	httpClient *http.Client
+}
+
+func (c countryIsResolver) CountryCode(ctx context.Context, _ time.Time, ipStr string, r *http.Request) string {
+	ip := net.ParseIP(strings.Trim(ipStr, "[]"))
+	lookup := ""
+	if ip != nil && isPublicIP(ip) {
+		lookup = ip.String()
+	} else if !trustProxyHeadersForRequest(r) {
+		return ""
+	}
+	code, err := lookupCountryCodeCountryIs(ctx, c.httpClient, lookup)
+	if err != nil {
+		return ""
+	}
+	return code
+}
+
+// rangeCountryResolver maps CIDR ranges from a "cidr,country" CSV file, e.g. a
+// corporate network plan. The most specific matching range wins.
+type rangeCountryResolver struct {
+	ranges []countryRange
+}
+
+type countryRange struct {
+	prefix netip.Prefix
+	code   string
+}
+
+func loadRangeCountryResolver(path string) (*rangeCountryResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	res := &rangeCountryResolver{}
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("%s:%d: want cidr,country", path, line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(rec[0]))
+		if err != nil {
+			if first {
+				continue // header row, possibly after comments
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		code := normalizeCountryCode(rec[1])
+		if code == "" {
+			return nil, fmt.Errorf("%s:%d: invalid country code %q", path, line, rec[1])
+		}
+		res.ranges = append(res.ranges, countryRange{prefix: prefix.Masked(), code: code})
+	}
+	return res, nil
+}
+
+func (c *rangeCountryResolver) CountryCode(_ context.Context, _ time.Time, ipStr string, _ *http.Request) string {
+	addr, err := netip.ParseAddr(strings.Trim(ipStr, "[]"))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	best, bestBits := "", -1
+	for _, rg := range c.ranges {
+		if rg.prefix.Bits() > bestBits && rg.prefix.Contains(addr) {
+			best, bestBits = rg.code, rg.prefix.Bits()
+		}
+	}
+	return best
+}
+
+// staticCountryResolver answers the same code for everyone (tests, single-
+// market deployments).
+type staticCountryResolver string
+
+func (s staticCountryResolver) CountryCode(context.Context, time.Time, string, *http.Request) string {
+	return string(s)
+}
+
+// cachedCountryResolver remembers answers per IP for ttl and misses for
+// negativeTTL, during which the chain moves straight on to the next provider.
+type cachedCountryResolver struct {
+	next        CountryResolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu           sync.Mutex
+	cache        map[string]geoCacheEntry
+	cleanupEvery time.Duration
+	lastCleanup  time.Time
+}
+
+type geoCacheEntry struct {
+	countryCode string
+	expiresAt   time.Time
+}
+
+func newCachedCountryResolver(next CountryResolver, ttl time.Duration, negativeTTL time.Duration) *cachedCountryResolver {
+	return &cachedCountryResolver{
+		next:         next,
+		ttl:          ttl,
+		negativeTTL:  negativeTTL,
+		cache:        make(map[string]geoCacheEntry),
+		cleanupEvery: 10 * time.Minute,
+		lastCleanup:  time.Now(),
+	}
+}
+
+func (c *cachedCountryResolver) CountryCode(ctx context.Context, now time.Time, ip string, r *http.Request) string {
+	key := ip
+	if parsed := net.ParseIP(strings.Trim(ip, "[]")); parsed != nil {
+		key = parsed.String()
+	}
+	if code, ok := c.getCached(now, key); ok {
+		return code
+	}
+	code := c.next.CountryCode(ctx, now, ip, r)
+	c.setCached(now, key, code)
+	return code
+}
+
+func (c *cachedCountryResolver) getCached(now time.Time, key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanupLocked(now)
+
+	entry, ok := c.cache[key]
+	if !ok || now.After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.countryCode, true
+}
+
+func (c *cachedCountryResolver) setCached(now time.Time, key string, code string) {
+	ttl := c.ttl
+	if code == "" {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanupLocked(now)
+	c.cache[key] = geoCacheEntry{
+		countryCode: code,
+		expiresAt:   now.Add(ttl),
+	}
+}
+
+func (c *cachedCountryResolver) cleanupLocked(now time.Time) {
+	if now.Sub(c.lastCleanup) < c.cleanupEvery {
+		return
+	}
+	for key, e := range c.cache {
+		if now.After(e.expiresAt) {
+			delete(c.cache, key)
+		}
+	}
+	c.lastCleanup = now
+}
+
+// countryResolverSpec is one parsed -geo-chain entry.
+type countryResolverSpec struct {
+	Kind        string
+	Arg         string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+// parseCountryResolverChain parses a comma-separated list of
+//
+//	kind[:arg][;ttl=D][;negative-ttl=D]
+//
+// where kind is one of
+//
+//	headers[:Name|Name...]  trusted proxy geo headers (default: defaultGeoHeaders)
+//	mmdb[:path]             MaxMind/DB-IP database (default: -geoip-db)
+//	csv:path                "cidr,country" ranges
+//	http                    api.country.is (sends client IPs to a third party)
+//	static:CC               a fixed answer
+//	none                    resolve nothing (alone)
+//
+// Only http caches by default (24h, misses 1h).
+func parseCountryResolverChain(spec string) ([]countryResolverSpec, error) {
+	var out []countryResolverSpec
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ";")
+		kind, arg, _ := strings.Cut(strings.TrimSpace(parts[0]), ":")
+		rs := countryResolverSpec{Kind: strings.ToLower(strings.TrimSpace(kind)), Arg: strings.TrimSpace(arg)}
+		switch rs.Kind {
+		case "http":
+			rs.TTL, rs.NegativeTTL = 24*time.Hour, time.Hour
+		case "csv", "static":
+			if rs.Arg == "" {
+				return nil, fmt.Errorf("geo chain: %s needs an argument (%s:...)", rs.Kind, rs.Kind)
+			}
+		case "headers", "mmdb", "none":
+		default:
+			return nil, fmt.Errorf("geo chain: unknown resolver %q", kind)
+		}
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("geo chain: %s: invalid %s %q", rs.Kind, key, value)
+			}
+			switch strings.TrimSpace(key) {
+			case "ttl":
+				rs.TTL = d
+			case "negative-ttl":
+				rs.NegativeTTL = d
+			default:
+				return nil, fmt.Errorf("geo chain: %s: unknown option %q", rs.Kind, key)
+			}
+		}
+		out = append(out, rs)
+	}
+	for _, rs := range out {
+		if rs.Kind == "none" && len(out) > 1 {
+			return nil, fmt.Errorf("geo chain: none can't be combined with other resolvers")
+		}
+	}
+	return out, nil
+}
+
+// defaultCountryResolverChain keeps the -geoip-db/-geoip-http behaviour when
+// -geo-chain isn't set.
+func defaultCountryResolverChain(cfg Config) string {
+	chain := []string{"headers"}
+	if cfg.GeoIPDB != "" {
+		chain = append(chain, "mmdb")
+	}
+	if cfg.GeoIPHTTP {
+		chain = append(chain, "http")
+	}
+	return strings.Join(chain, ",")
+}
+
+// buildCountryResolver opens every provider of the configured chain; mmdb
+// databases start their reload loop here.
+func buildCountryResolver(cfg Config) (countryResolverChain, error) {
+	spec := cfg.GeoChain
+	if spec == "" {
+		spec = defaultCountryResolverChain(cfg)
+	}
+	specs, err := parseCountryResolverChain(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := countryResolverChain{}
+	for _, rs := range specs {
+		var resolver CountryResolver
+		switch rs.Kind {
+		case "none":
+			continue
+		case "headers":
+			var names []string
+			for _, name := range strings.Split(rs.Arg, "|") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+			resolver = newHeaderCountryResolver(names)
+		case "mmdb":
+			path := rs.Arg
+			if path == "" {
+				path = cfg.GeoIPDB
+			}
+			if path == "" {
+				return nil, fmt.Errorf("geo chain: mmdb needs a path (mmdb:<path> or -geoip-db)")
+			}
+			db, err := openMMDBCountryDB(path)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.GeoIPReloadEvery > 0 {
+				go db.reloadEvery(cfg.GeoIPReloadEvery)
+			}
+			resolver = db
+		case "csv":
+			ranges, err := loadRangeCountryResolver(rs.Arg)
+			if err != nil {
+				return nil, fmt.Errorf("geo chain: %w", err)
+			}
+			resolver = ranges
+		case "http":
+			resolver = countryIsResolver{httpClient: &http.Client{Timeout: 1500 * time.Millisecond}}
+		case "static":
+			code := normalizeCountryCode(rs.Arg)
+			if code == "" {
+				return nil, fmt.Errorf("geo chain: invalid static country %q", rs.Arg)
+			}
+			resolver = staticCountryResolver(code)
+		}
+		if rs.TTL > 0 || rs.NegativeTTL > 0 {
+			resolver = newCachedCountryResolver(resolver, rs.TTL, rs.NegativeTTL)
+		}
+		chain = append(chain, resolver)
+	}
+	return chain, nil
+}
+
+// describeCountryResolver is the startup log line for the chain.
+func describeCountryResolver(r CountryResolver) string {
+	switch v := r.(type) {
+	case countryResolverChain:
+		if len(v) == 0 {
+			return "none"
+		}
+		parts := make([]string, len(v))
+		for i, next := range v {
+			parts[i] = describeCountryResolver(next)
+		}
+		return strings.Join(parts, " -> ")
+	case *cachedCountryResolver:
+		return fmt.Sprintf("%s (ttl %s, negative %s)", describeCountryResolver(v.next), v.ttl, v.negativeTTL)
+	case headerCountryResolver:
+		return "headers"
+	case *mmdbCountryDB:
+		return fmt.Sprintf("mmdb %s [%s]", v.path, v.description())
+	case *rangeCountryResolver:
+		return fmt.Sprintf("csv (%d ranges)", len(v.ranges))
+	case countryIsResolver:
+		return "api.country.is"
+	case staticCountryResolver:
+		return "static " + string(v)
+	default:
+		return fmt.Sprintf("%T", r)
+	}
+}
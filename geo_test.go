@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCountryResolverChain(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []countryResolverSpec
+		wantErr string
+	}{
+		{spec: "", want: nil},
+		{spec: "headers", want: []countryResolverSpec{{Kind: "headers"}}},
+		{
+			spec: " Headers:CF-IPCountry|X-Country , mmdb:/data/geo.mmdb,http",
+			want: []countryResolverSpec{
+				{Kind: "headers", Arg: "CF-IPCountry|X-Country"},
+				{Kind: "mmdb", Arg: "/data/geo.mmdb"},
+				{Kind: "http", TTL: 24 * time.Hour, NegativeTTL: time.Hour},
+			},
+		},
+		{
+			spec: "csv:nets.csv;ttl=5m;negative-ttl=30s,static:de",
+			want: []countryResolverSpec{
+				{Kind: "csv", Arg: "nets.csv", TTL: 5 * time.Minute, NegativeTTL: 30 * time.Second},
+				{Kind: "static", Arg: "de"},
+			},
+		},
+		{spec: "http;ttl=0;negative-ttl=2h", want: []countryResolverSpec{{Kind: "http", NegativeTTL: 2 * time.Hour}}},
+		{spec: "mmdb,,", want: []countryResolverSpec{{Kind: "mmdb"}}},
+		{spec: "none", want: []countryResolverSpec{{Kind: "none"}}},
+
+		{spec: "dns", wantErr: `unknown resolver "dns"`},
+		{spec: "csv", wantErr: "csv needs an argument"},
+		{spec: "static:", wantErr: "static needs an argument"},
+		{spec: "none,headers", wantErr: "none can't be combined"},
+		{spec: "headers,none", wantErr: "none can't be combined"},
+		{spec: "http;ttl=soon", wantErr: `invalid ttl "soon"`},
+		{spec: "http;ttl=-1h", wantErr: `invalid ttl "-1h"`},
+		{spec: "http;negative-ttl", wantErr: `invalid negative-ttl ""`},
+		{spec: "http;max-age=1h", wantErr: `unknown option "max-age"`},
+	}
+	for _, tt := range tests {
+		got, err := parseCountryResolverChain(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseCountryResolverChain(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCountryResolverChain(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCountryResolverChain(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// countingCountryResolver answers from codes and records how often it was
+// asked.
+type countingCountryResolver struct {
+	codes map[string]string
+	calls int
+}
+
+func (c *countingCountryResolver) CountryCode(_ context.Context, _ time.Time, ip string, _ *http.Request) string {
+	c.calls++
+	return c.codes[ip]
+}
+
+func TestCountryResolverChain(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain countryResolverChain
+		ip    string
+		want  string
+	}{
+		{"empty", countryResolverChain{}, "8.8.8.8", ""},
+		{"first answer wins", countryResolverChain{staticCountryResolver("DE"), staticCountryResolver("FR")}, "8.8.8.8", "DE"},
+		{"misses fall through", countryResolverChain{staticCountryResolver(""), staticCountryResolver("FR")}, "8.8.8.8", "FR"},
+		{
+			"stub before static",
+			countryResolverChain{&countingCountryResolver{codes: map[string]string{"1.1.1.1": "AU"}}, staticCountryResolver("US")},
+			"1.1.1.1", "AU",
+		},
+		{
+			"stub miss then static",
+			countryResolverChain{&countingCountryResolver{codes: map[string]string{"1.1.1.1": "AU"}}, staticCountryResolver("US")},
+			"9.9.9.9", "US",
+		},
+		{"all miss", countryResolverChain{staticCountryResolver(""), &countingCountryResolver{}}, "9.9.9.9", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.chain.CountryCode(context.Background(), time.Now(), tt.ip, nil); got != tt.want {
+			t.Errorf("%s: CountryCode = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// The first answer stops the chain.
+	later := &countingCountryResolver{}
+	chain := countryResolverChain{staticCountryResolver("DE"), later}
+	chain.CountryCode(context.Background(), time.Now(), "8.8.8.8", nil)
+	if later.calls != 0 {
+		t.Errorf("resolver after an answer was asked %d times", later.calls)
+	}
+}
+
+func TestBuildCountryResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nets.csv")
+	if err := os.WriteFile(path, []byte("10.0.0.0/8,DE\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := buildCountryResolver(Config{GeoChain: "csv:" + path + ";negative-ttl=1m, static:fr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describeCountryResolver(chain), "csv (1 ranges) (ttl 0s, negative 1m0s) -> static FR"; got != want {
+		t.Errorf("describe = %q, want %q", got, want)
+	}
+	for ip, want := range map[string]string{"10.1.2.3": "DE", "8.8.8.8": "FR"} {
+		if got := chain.CountryCode(context.Background(), time.Now(), ip, nil); got != want {
+			t.Errorf("CountryCode(%s) = %q, want %q", ip, got, want)
+		}
+	}
+
+	if chain, err := buildCountryResolver(Config{GeoChain: "none"}); err != nil || describeCountryResolver(chain) != "none" {
+		t.Errorf("none: %v, %v", describeCountryResolver(chain), err)
+	}
+	if got := defaultCountryResolverChain(Config{GeoIPDB: "x.mmdb", GeoIPHTTP: true}); got != "headers,mmdb,http" {
+		t.Errorf("default chain = %q", got)
+	}
+	for _, spec := range []string{"static:Germany", "csv:" + filepath.Join(t.TempDir(), "missing.csv"), "mmdb"} {
+		if _, err := buildCountryResolver(Config{GeoChain: spec}); err == nil {
+			t.Errorf("buildCountryResolver(%q): want error", spec)
+		}
+	}
+}
+
+func TestCachedCountryResolver(t *testing.T) {
+	stub := &countingCountryResolver{codes: map[string]string{"8.8.8.8": "US"}}
+	cached := newCachedCountryResolver(stub, time.Hour, time.Minute)
+	t0 := time.Now()
+
+	steps := []struct {
+		at        time.Duration
+		ip        string
+		want      string
+		wantCalls int
+	}{
+		{0, "8.8.8.8", "US", 1},
+		{30 * time.Minute, "8.8.8.8", "US", 1},
+		{0, "9.9.9.9", "", 2},
+		{30 * time.Second, "9.9.9.9", "", 2}, // negative entry still fresh
+		{30 * time.Second, "[::ffff:9.9.9.9]", "", 2},
+		{61 * time.Second, "9.9.9.9", "", 3}, // negative entry expired
+		{61 * time.Minute, "8.8.8.8", "US", 4},
+	}
+	for i, st := range steps {
+		got := cached.CountryCode(context.Background(), t0.Add(st.at), st.ip, nil)
+		if got != st.want || stub.calls != st.wantCalls {
+			t.Fatalf("step %d (%s at +%s) = %q after %d calls, want %q after %d", i, st.ip, st.at, got, stub.calls, st.want, st.wantCalls)
+		}
+	}
+
+	// A cached miss moves the chain straight on to the next provider.
+	stub = &countingCountryResolver{codes: map[string]string{}}
+	chain := countryResolverChain{newCachedCountryResolver(stub, time.Hour, time.Minute), staticCountryResolver("GB")}
+	chain.CountryCode(context.Background(), t0, "9.9.9.9", nil)
+	stub.codes["9.9.9.9"] = "NL"
+	if got := chain.CountryCode(context.Background(), t0.Add(30*time.Second), "9.9.9.9", nil); got != "GB" || stub.calls != 1 {
+		t.Errorf("chain with a cached miss = %q after %d calls, want GB after 1", got, stub.calls)
+	}
+	if got := chain.CountryCode(context.Background(), t0.Add(2*time.Minute), "9.9.9.9", nil); got != "NL" {
+		t.Errorf("chain after the miss expired = %q, want NL", got)
+	}
+
+	// Without a negative TTL misses are not cached.
+	stub = &countingCountryResolver{}
+	cached = newCachedCountryResolver(stub, time.Hour, 0)
+	for range 3 {
+		cached.CountryCode(context.Background(), t0, "9.9.9.9", nil)
+	}
+	if stub.calls != 3 {
+		t.Errorf("misses with negative-ttl=0 cached: %d calls, want 3", stub.calls)
+	}
+}
+
+func TestRangeCountryResolver(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	path := write("nets.csv", `# corporate network plan
+# regenerated nightly
+cidr,country
+10.0.0.0/8,de
+10.20.0.0/16, FR
+10.20.30.0/24,NL
+10.20.30.40/32,BE
+2001:db8::/32,US
+2001:db8:1::/48,CA
+`)
+	res, err := loadRangeCountryResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]string{
+		"10.1.1.1":         "DE",
+		"10.20.1.1":        "FR",
+		"10.20.30.1":       "NL",
+		"10.20.30.40":      "BE",
+		"::ffff:10.20.1.1": "FR",
+		"2001:db8:2::1":    "US",
+		"[2001:db8:1::1]":  "CA",
+		"11.0.0.1":         "",
+		"garbage":          "",
+	} {
+		if got := res.CountryCode(context.Background(), time.Now(), ip, nil); got != want {
+			t.Errorf("CountryCode(%s) = %q, want %q", ip, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name, content, wantErr string
+	}{
+		{"bad-cidr.csv", "cidr,country\n10.0.0.0/8,DE\n10.0.0.0/33,FR\n", ":3:"},
+		{"no-header-bad-cidr.csv", "# comment\n10.0.0.0/8,DE\nnot-a-cidr,FR\n", ":3:"},
+		{"bad-country.csv", "10.0.0.0/8,Germany\n", "invalid country code"},
+		{"one-column.csv", "10.0.0.0/8\n", "want cidr,country"},
+	} {
+		if _, err := loadRangeCountryResolver(write(tt.name, tt.content)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbFile is a MaxMind-format database that is reloaded when the file
+// changes. It is read into memory rather than mapped: replacing the file in
+// place while mapped would crash the process, and reloadEvery swaps in new
+// versions as they appear.
+type mmdbFile struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+	// rejected identifies a file version that failed to load, so it is
+	// reported once rather than on every poll.
+	rejected string
+}
+
+func openMMDBFile(path string) (*mmdbFile, error) {
+	db := &mmdbFile{path: path}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// reload reads the file again if its size or modification time changed.
+func (db *mmdbFile) reload() (bool, error) {
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return false, fmt.Errorf("mmdb: %w", err)
+	}
+	version := fmt.Sprintf("%d/%d", fi.ModTime().UnixNano(), fi.Size())
+	db.mu.RLock()
+	unchanged := db.reader != nil && fi.ModTime().Equal(db.modTime) && fi.Size() == db.size
+	seen := version == db.rejected
+	db.mu.RUnlock()
+	if unchanged || seen {
+		return false, nil
+	}
+
+	buf, err := os.ReadFile(db.path)
+	if err == nil {
+		var reader *maxminddb.Reader
+		if reader, err = maxminddb.FromBytes(buf); err == nil {
+			db.mu.Lock()
+			db.reader = reader
+			db.modTime = fi.ModTime()
+			db.size = fi.Size()
+			db.rejected = ""
+			db.mu.Unlock()
+			return true, nil
+		}
+	}
+	db.mu.Lock()
+	db.rejected = version
+	db.mu.Unlock()
+	return false, fmt.Errorf("mmdb %s: %w", db.path, err)
+}
+
+// reloadEvery polls the file; a broken update keeps the previous database.
+func (db *mmdbFile) reloadEvery(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloaded, err := db.reload()
+		if err != nil {
+			log.Printf("mmdb reload failed (keeping the loaded version): %v", err)
+			continue
+		}
+		if reloaded {
+			log.Printf("mmdb reloaded: %s (%s)", db.path, db.description())
+		}
+	}
+}
+
+func (db *mmdbFile) lookup(ip net.IP, rec any) error {
+	db.mu.RLock()
+	reader := db.reader
+	db.mu.RUnlock()
+	return reader.Lookup(ip, rec)
+}
+
+// mmdbCountryDB resolves countries from a local MaxMind GeoLite2/GeoIP2 or
+// DB-IP country database, so lookups need no outbound call.
+type mmdbCountryDB struct {
+	*mmdbFile
+}
+
+// mmdbCountryRecord covers the fields shared by the MaxMind and DB-IP
+// country/city schemas.
+type mmdbCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+func openMMDBCountryDB(path string) (*mmdbCountryDB, error) {
+	f, err := openMMDBFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("geoip db: %w", err)
+	}
+	return &mmdbCountryDB{f}, nil
+}
+
+// Lookup returns "" when the address isn't in the database.
+func (db *mmdbCountryDB) Lookup(ip net.IP) (string, error) {
+	var rec mmdbCountryRecord
+	if err := db.lookup(ip, &rec); err != nil {
+		return "", err
+	}
+	if code := normalizeCountryCode(rec.Country.ISOCode); code != "" {
+		return code, nil
+	}
+	return normalizeCountryCode(rec.RegisteredCountry.ISOCode), nil
+}
+
+func (db *mmdbFile) description() string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	meta := db.reader.Metadata
+	return fmt.Sprintf("%s, built %s", meta.DatabaseType, time.Unix(int64(meta.BuildEpoch), 0).UTC().Format(time.DateOnly))
+}
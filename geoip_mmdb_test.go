@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMMDBEntry maps an IPv4 network to a record for writeTestMMDB.
+type testMMDBEntry struct {
+	cidr string
+	rec  map[string]any
+}
+
+// writeTestMMDB writes a minimal IPv4 MaxMind DB (24-bit records) holding
+// entries. Record values may be strings, uint32s, nested maps and string
+// slices, which is all the country and ASN schemas need.
+func writeTestMMDB(t *testing.T, path string, dbType string, entries []testMMDBEntry) {
+	t.Helper()
+
+	var data []byte
+	offsets := make([]int, len(entries))
+	for i, e := range entries {
+		offsets[i] = len(data)
+		data = appendMMDBValue(data, e.rec)
+	}
+
+	type node [2]int // >= 0: child node; -1: empty; < -1: -(data offset)-2
+	nodes := []node{{-1, -1}}
+	for i, e := range entries {
+		prefix := netip.MustParsePrefix(e.cidr)
+		addr := prefix.Addr().As4()
+		ip := binary.BigEndian.Uint32(addr[:])
+		n := 0
+		for bit := 0; bit < prefix.Bits(); bit++ {
+			b := int(ip>>(31-bit)) & 1
+			if bit == prefix.Bits()-1 {
+				nodes[n][b] = -offsets[i] - 2
+				break
+			}
+			if nodes[n][b] < 0 {
+				nodes = append(nodes, node{-1, -1})
+				nodes[n][b] = len(nodes) - 1
+			}
+			n = nodes[n][b]
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, nd := range nodes {
+		for _, rec := range nd {
+			v := rec
+			switch {
+			case rec == -1:
+				v = len(nodes)
+			case rec < -1:
+				v = len(nodes) + 16 + (-rec - 2)
+			}
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data)
+	buf.WriteString("\xab\xcd\xefMaxMind.com")
+	buf.Write(appendMMDBValue(nil, map[string]any{
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               dbType,
+		"languages":                   []string{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1767225600),
+		"description":                 map[string]any{"en": "test"},
+	}))
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendMMDBValue(b []byte, v any) []byte {
+	control := func(b []byte, typ, size int) []byte {
+		var ext []byte
+		if size >= 29 {
+			ext = []byte{byte(size - 29)}
+			size = 29
+		}
+		if typ <= 7 {
+			b = append(b, byte(typ<<5|size))
+		} else {
+			b = append(b, byte(size), byte(typ-7))
+		}
+		return append(b, ext...)
+	}
+	unsigned := func(b []byte, typ int, n uint64) []byte {
+		var digits []byte
+		for ; n > 0; n >>= 8 {
+			digits = append([]byte{byte(n)}, digits...)
+		}
+		return append(control(b, typ, len(digits)), digits...)
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(control(b, 2, len(v)), v...)
+	case uint16:
+		return unsigned(b, 5, uint64(v))
+	case uint32:
+		return unsigned(b, 6, uint64(v))
+	case uint64:
+		return unsigned(b, 9, v)
+	case map[string]any:
+		b = control(b, 7, len(v))
+		for k, x := range v {
+			b = appendMMDBValue(appendMMDBValue(b, k), x)
+		}
+		return b
+	case []string:
+		b = control(b, 11, len(v))
+		for _, x := range v {
+			b = appendMMDBValue(b, x)
+		}
+		return b
+	}
+	panic("appendMMDBValue: unsupported type")
+}
+
+func writeTestCountryDB(t *testing.T, path string, google string) {
+	writeTestMMDB(t, path, "GeoLite2-Country", []testMMDBEntry{
+		{"8.8.8.0/24", map[string]any{"country": map[string]any{"iso_code": google}}},
+		// Anycast ranges often carry only the registered country.
+		{"1.1.1.0/24", map[string]any{"registered_country": map[string]any{"iso_code": "AU"}}},
+	})
+}
+
+func TestMMDBCountryLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	writeTestCountryDB(t, path, "us")
+	db, err := openMMDBCountryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]string{
+		"8.8.8.8":   "US",
+		"8.8.8.255": "US",
+		"1.1.1.1":   "AU",
+		"8.8.4.4":   "",
+		"9.9.9.9":   "",
+	} {
+		got, err := db.Lookup(net.ParseIP(ip))
+		if err != nil || got != want {
+			t.Errorf("Lookup(%s) = %q, %v; want %q", ip, got, err, want)
+		}
+	}
+	if got := db.description(); !strings.HasPrefix(got, "GeoLite2-Country, built 2026-01-01") {
+		t.Errorf("description() = %q", got)
+	}
+}
+
+func TestMMDBReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	writeTestCountryDB(t, path, "US")
+	db, err := openMMDBCountryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := db.reload(); reloaded || err != nil {
+		t.Fatalf("reload of an unchanged file = %v, %v", reloaded, err)
+	}
+
+	// A new release of the same size; only the modification time differs.
+	touch := func(d time.Duration) {
+		t.Helper()
+		mtime := time.Now().Add(d)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestCountryDB(t, path, "CA")
+	touch(time.Hour)
+	if reloaded, err := db.reload(); !reloaded || err != nil {
+		t.Fatalf("reload after update = %v, %v", reloaded, err)
+	}
+	if got, _ := db.Lookup(net.ParseIP("8.8.8.8")); got != "CA" {
+		t.Fatalf("after reload: Lookup = %q, want CA", got)
+	}
+
+	// A truncated download is reported once and the loaded version kept.
+	if err := os.WriteFile(path, []byte("not an mmdb"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touch(2 * time.Hour)
+	if reloaded, err := db.reload(); reloaded || err == nil {
+		t.Fatalf("reload of a broken file = %v, %v; want error", reloaded, err)
+	}
+	if reloaded, err := db.reload(); reloaded || err != nil {
+		t.Fatalf("second reload of the same broken file = %v, %v; want no-op", reloaded, err)
+	}
+	if got, _ := db.Lookup(net.ParseIP("8.8.8.8")); got != "CA" {
+		t.Fatalf("after broken update: Lookup = %q, want CA", got)
+	}
+
+	writeTestCountryDB(t, path, "MX")
+	touch(3 * time.Hour)
+	if reloaded, err := db.reload(); !reloaded || err != nil {
+		t.Fatalf("reload after fix = %v, %v", reloaded, err)
+	}
+	if got, _ := db.Lookup(net.ParseIP("8.8.8.8")); got != "MX" {
+		t.Fatalf("after fix: Lookup = %q, want MX", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.reload(); err == nil {
+		t.Fatal("reload of a missing file: want error")
+	}
+	if got, _ := db.Lookup(net.ParseIP("8.8.8.8")); got != "MX" {
+		t.Fatalf("after removal: Lookup = %q, want MX", got)
+	}
+}
+
+func TestOpenMMDBCountryDBErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := openMMDBCountryDB(filepath.Join(dir, "missing.mmdb")); err == nil {
+		t.Error("missing file: want error")
+	}
+	broken := filepath.Join(dir, "broken.mmdb")
+	if err := os.WriteFile(broken, []byte("not an mmdb"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openMMDBCountryDB(broken); err == nil {
+		t.Error("broken file: want error")
+	}
+}
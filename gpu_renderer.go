@@ -0,0 +1,260 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GPURendererInfo is the normalized form of a WebGL UNMASKED_RENDERER_WEBGL
+// (or WebGPU adapter description) string.
+type GPURendererInfo struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Family  string `json:"family,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Backend string `json:"backend,omitempty"`
+}
+
+var (
+	rendererTrademarkRe = regexp.MustCompile(`(?i)\((?:R|TM|C)\)`)
+	rendererD3DSuffixRe = regexp.MustCompile(`(?i)\s+Direct3D\d+.*$`)
+	rendererSpacesRe    = regexp.MustCompile(`\s+`)
+	// rendererPCIIDRe matches the PCI device IDs Chrome on Windows appends,
+	// e.g. "(0x00003EA0)".
+	rendererPCIIDRe = regexp.MustCompile(`\s*\(0x[0-9A-Fa-f]+\)`)
+	// rendererParenRe matches driver tags such as Mesa's "(KBL GT2)" or
+	// "(navi21, LLVM 15.0.6, DRM 3.49, ...)"; applied until nothing is left
+	// so nested groups go too.
+	rendererParenRe = regexp.MustCompile(`\s*\([^()]*\)`)
+
+	rendererAppleMRe   = regexp.MustCompile(`(?i)\bApple\s+(M\d+(?:\s+(?:Pro|Max|Ultra))?)\b`)
+	rendererAppleARe   = regexp.MustCompile(`(?i)\bApple\s+(A\d+[A-Z]?)\b`)
+	rendererAdrenoRe   = regexp.MustCompile(`(?i)\bAdreno\s*(?:GPU\s*)?(\d+\w*)?`)
+	rendererMaliRe     = regexp.MustCompile(`(?i)\bMali[-\s]*([A-Z]*\d+\w*)?`)
+	rendererPowerVRRe  = regexp.MustCompile(`(?i)\bPowerVR\s*(.*)$`)
+	rendererXclipseRe  = regexp.MustCompile(`(?i)\bXclipse\s*(\d+\w*)?`)
+	rendererGeForceRe  = regexp.MustCompile(`(?i)\bGeForce\s*(.*)$`)
+	rendererQuadroRe   = regexp.MustCompile(`(?i)\b(Quadro|Tesla|TITAN)\s*(.*)$`)
+	rendererNvRTXARe   = regexp.MustCompile(`(?i)\bNVIDIA\s+RTX\s+(A\d+.*)$`)
+	rendererRadeonRe   = regexp.MustCompile(`(?i)\bRadeon\s*(.*)$`)
+	rendererIntelArcRe = regexp.MustCompile(`(?i)\bArc\s*(.*)$`)
+	rendererIntelGfxRe = regexp.MustCompile(`(?i)\b(UHD|HD|Iris(?:\s+(?:Xe|Plus|Pro))?|Xe)\s+Graphics\s*(.*)$`)
+)
+
+// parseGPURenderer normalizes renderer strings into vendor, family, model and
+// backend API. Examples:
+//
+//	"ANGLE (Apple, ANGLE Metal Renderer: Apple M2 Pro, Unspecified Version)"
+//	    -> apple / Apple M / M2 Pro / metal
+//	"ANGLE (NVIDIA, NVIDIA GeForce RTX 3080 Direct3D11 vs_5_0 ps_5_0, D3D11)"
+//	    -> nvidia / GeForce / RTX 3080 / d3d11
+//	"ANGLE (Intel, Intel(R) UHD Graphics 620 (0x00003EA0) Direct3D11 vs_5_0 ps_5_0, D3D11)"
+//	    -> intel / UHD Graphics / 620 / d3d11
+//	"Mesa Intel(R) UHD Graphics 620 (KBL GT2)" -> intel / UHD Graphics / 620
+//	"ANGLE (Qualcomm, Adreno (TM) 650, OpenGL ES 3.2)"
+//	    -> qualcomm / Adreno / 650 / opengles
+//	"Adreno (TM) 650"            -> qualcomm / Adreno / 650
+//	"Mali-G78 MP14"              -> arm / Mali / G78
+//	"PowerVR Rogue GE8320"       -> imagination / PowerVR / Rogue GE8320
+//	"Apple GPU"                  -> apple / Apple GPU
+//	"NVIDIA GeForce GTX 1060/PCIe/SSE2" -> nvidia / GeForce / GTX 1060
+func parseGPURenderer(raw string) GPURendererInfo {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return GPURendererInfo{}
+	}
+
+	var info GPURendererInfo
+	vendorHint := ""
+	device := s
+
+	if inner, rest, ok := unwrapANGLE(s); ok {
+		parts := splitTopLevelCommas(inner)
+		switch len(parts) {
+		case 0:
+		case 1:
+			device = parts[0]
+		case 2:
+			vendorHint, device = parts[0], parts[1]
+		default:
+			vendorHint, device = parts[0], parts[1]
+			info.Backend = rendererBackend(parts[len(parts)-1])
+		}
+		if info.Backend == "" {
+			info.Backend = rendererBackend(rest)
+		}
+		if info.Backend == "" {
+			// "ANGLE (Google, Vulkan 1.3.0 (SwiftShader Device ...), SwiftShader driver)"
+			info.Backend = rendererBackend(device)
+		}
+	}
+
+	if after, ok := cutPrefixFold(device, "ANGLE Metal Renderer:"); ok {
+		device = after
+		info.Backend = "metal"
+	}
+	if info.Backend == "" && rendererD3DSuffixRe.MatchString(device) {
+		info.Backend = rendererBackend(device)
+	}
+
+	device = rendererTrademarkRe.ReplaceAllString(device, "")
+	device = rendererD3DSuffixRe.ReplaceAllString(device, "")
+	if i := strings.Index(device, "/"); i > 0 {
+		// "GeForce GTX 1060/PCIe/SSE2"
+		device = device[:i]
+	}
+	device = strings.TrimSuffix(strings.TrimSpace(device), ", or similar")
+	device = rendererPCIIDRe.ReplaceAllString(device, "")
+	// Software renderers name themselves inside the driver tag:
+	// "Vulkan 1.3.0 (SwiftShader Device (Subzero))", "llvmpipe (LLVM 15.0.7, 256 bits)".
+	detail := strings.TrimSpace(rendererSpacesRe.ReplaceAllString(device, " "))
+	for {
+		stripped := rendererParenRe.ReplaceAllString(device, "")
+		if stripped == device {
+			break
+		}
+		device = stripped
+	}
+	device = strings.TrimSpace(rendererSpacesRe.ReplaceAllString(device, " "))
+
+	info.Vendor, info.Family, info.Model = classifyGPUDevice(device)
+	if info.Vendor == "" && detail != device {
+		info.Vendor, info.Family, info.Model = classifyGPUDevice(detail)
+	}
+	if info.Vendor == "" {
+		info.Vendor = normalizeWebGLVendor(vendorHint)
+	}
+	if info.Family == "" && info.Vendor != "" {
+		info.Model = device
+	}
+	return info
+}
+
+func classifyGPUDevice(device string) (vendor string, family string, model string) {
+	lower := strings.ToLower(device)
+	switch {
+	case rendererAppleMRe.MatchString(device):
+		return "apple", "Apple M", normalizeModelCase(rendererAppleMRe.FindStringSubmatch(device)[1])
+	case rendererAppleARe.MatchString(device):
+		return "apple", "Apple A", strings.ToUpper(rendererAppleARe.FindStringSubmatch(device)[1])
+	case strings.HasPrefix(lower, "apple"):
+		return "apple", "Apple GPU", ""
+	case rendererAdrenoRe.MatchString(device):
+		return "qualcomm", "Adreno", rendererAdrenoRe.FindStringSubmatch(device)[1]
+	case rendererMaliRe.MatchString(device):
+		return "arm", "Mali", strings.ToUpper(rendererMaliRe.FindStringSubmatch(device)[1])
+	case rendererPowerVRRe.MatchString(device):
+		return "imagination", "PowerVR", strings.TrimSpace(rendererPowerVRRe.FindStringSubmatch(device)[1])
+	case rendererXclipseRe.MatchString(device):
+		return "samsung", "Xclipse", rendererXclipseRe.FindStringSubmatch(device)[1]
+	case rendererGeForceRe.MatchString(device):
+		return "nvidia", "GeForce", strings.TrimSpace(rendererGeForceRe.FindStringSubmatch(device)[1])
+	case rendererQuadroRe.MatchString(device):
+		m := rendererQuadroRe.FindStringSubmatch(device)
+		return "nvidia", m[1], strings.TrimSpace(m[2])
+	case rendererNvRTXARe.MatchString(device):
+		return "nvidia", "RTX", strings.TrimSpace(rendererNvRTXARe.FindStringSubmatch(device)[1])
+	case rendererRadeonRe.MatchString(device):
+		model := strings.TrimSpace(rendererRadeonRe.FindStringSubmatch(device)[1])
+		if strings.EqualFold(model, "Graphics") {
+			model = ""
+		}
+		return "amd", "Radeon", model
+	case strings.Contains(lower, "intel") && rendererIntelArcRe.MatchString(device):
+		model := strings.TrimSpace(rendererIntelArcRe.FindStringSubmatch(device)[1])
+		return "intel", "Arc", strings.TrimSpace(strings.TrimSuffix(model, "Graphics"))
+	case rendererIntelGfxRe.MatchString(device):
+		m := rendererIntelGfxRe.FindStringSubmatch(device)
+		return "intel", m[1] + " Graphics", strings.TrimSpace(m[2])
+	case strings.Contains(lower, "swiftshader"):
+		return "google", "SwiftShader", ""
+	case strings.Contains(lower, "llvmpipe"):
+		return "mesa", "llvmpipe", ""
+	case strings.Contains(lower, "basic render driver"):
+		return "microsoft", "Basic Render Driver", ""
+	}
+	return "", "", ""
+}
+
+func rendererBackend(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.Contains(lower, "d3d11"), strings.Contains(lower, "direct3d11"):
+		return "d3d11"
+	case strings.Contains(lower, "d3d9"), strings.Contains(lower, "direct3d9"):
+		return "d3d9"
+	case strings.Contains(lower, "d3d12"), strings.Contains(lower, "direct3d12"):
+		return "d3d12"
+	case strings.Contains(lower, "metal"):
+		return "metal"
+	case strings.Contains(lower, "vulkan"):
+		return "vulkan"
+	case strings.Contains(lower, "opengl es"):
+		return "opengles"
+	case strings.Contains(lower, "opengl"):
+		return "opengl"
+	}
+	return ""
+}
+
+// unwrapANGLE returns the parenthesized body of "ANGLE (...)" plus anything
+// after the closing paren (e.g. " on Vulkan 1.1").
+func unwrapANGLE(s string) (inner string, rest string, ok bool) {
+	after, found := cutPrefixFold(s, "ANGLE (")
+	if !found {
+		return "", "", false
+	}
+	depth := 1
+	for i := 0; i < len(after); i++ {
+		switch after[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return after[:i], after[i+1:], true
+			}
+		}
+	}
+	return after, "", true
+}
+
+func splitTopLevelCommas(s string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if tail := strings.TrimSpace(s[start:]); tail != "" || len(parts) > 0 {
+		parts = append(parts, tail)
+	}
+	return parts
+}
+
+func cutPrefixFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return strings.TrimSpace(s[len(prefix):]), true
+}
+
+// normalizeModelCase turns "m2 pro" into "M2 Pro".
+func normalizeModelCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+	}
+	return strings.Join(words, " ")
+}
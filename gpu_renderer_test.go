@@ -0,0 +1,142 @@
+package main
+
+import "testing"
+
+func TestParseGPURenderer(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want GPURendererInfo
+	}{
+		// ANGLE on Windows, with and without PCI device IDs.
+		{
+			raw:  "ANGLE (NVIDIA, NVIDIA GeForce RTX 3080 Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "nvidia", Family: "GeForce", Model: "RTX 3080", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (NVIDIA, NVIDIA GeForce RTX 3060 Laptop GPU (0x00002560) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "nvidia", Family: "GeForce", Model: "RTX 3060 Laptop GPU", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (Intel, Intel(R) UHD Graphics 620 Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "intel", Family: "UHD Graphics", Model: "620", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (Intel, Intel(R) UHD Graphics 620 (0x00003EA0) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "intel", Family: "UHD Graphics", Model: "620", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (Intel, Intel(R) Iris(R) Xe Graphics (0x00009A49) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "intel", Family: "Iris Xe Graphics", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (Intel, Intel(R) Arc(TM) A770 Graphics (0x000056A0) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "intel", Family: "Arc", Model: "A770", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (AMD, AMD Radeon(TM) Graphics (0x00001638) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "amd", Family: "Radeon", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (AMD, AMD Radeon RX 6800 XT (0x000073BF) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "amd", Family: "Radeon", Model: "RX 6800 XT", Backend: "d3d11"},
+		},
+		{
+			raw:  "ANGLE (NVIDIA, NVIDIA RTX A4000 (0x000024B0) Direct3D11 vs_5_0 ps_5_0, D3D11)",
+			want: GPURendererInfo{Vendor: "nvidia", Family: "RTX", Model: "A4000", Backend: "d3d11"},
+		},
+
+		// ANGLE on macOS, Linux and Android.
+		{
+			raw:  "ANGLE (Apple, ANGLE Metal Renderer: Apple M2 Pro, Unspecified Version)",
+			want: GPURendererInfo{Vendor: "apple", Family: "Apple M", Model: "M2 Pro", Backend: "metal"},
+		},
+		{
+			raw:  "ANGLE (Intel, Mesa Intel(R) UHD Graphics 620 (KBL GT2), OpenGL 4.6)",
+			want: GPURendererInfo{Vendor: "intel", Family: "UHD Graphics", Model: "620", Backend: "opengl"},
+		},
+		{
+			raw:  "ANGLE (AMD, AMD Radeon RX 580 Series (polaris10, LLVM 15.0.7, DRM 3.49, 6.1.0-13-amd64), OpenGL 4.6)",
+			want: GPURendererInfo{Vendor: "amd", Family: "Radeon", Model: "RX 580 Series", Backend: "opengl"},
+		},
+		{
+			raw:  "ANGLE (Qualcomm, Adreno (TM) 650, OpenGL ES 3.2)",
+			want: GPURendererInfo{Vendor: "qualcomm", Family: "Adreno", Model: "650", Backend: "opengles"},
+		},
+		{
+			raw:  "ANGLE (ARM, Mali-G78 MP14, OpenGL ES 3.2)",
+			want: GPURendererInfo{Vendor: "arm", Family: "Mali", Model: "G78", Backend: "opengles"},
+		},
+		{
+			raw:  "ANGLE (Google, Vulkan 1.3.0 (SwiftShader Device (Subzero) (0x0000C0DE)), SwiftShader driver)",
+			want: GPURendererInfo{Vendor: "google", Family: "SwiftShader", Backend: "vulkan"},
+		},
+
+		// Native GL strings (Firefox, Safari, Mesa).
+		{
+			raw:  "Mesa Intel(R) UHD Graphics 620 (KBL GT2)",
+			want: GPURendererInfo{Vendor: "intel", Family: "UHD Graphics", Model: "620"},
+		},
+		{
+			raw:  "Mesa Intel(R) Xe Graphics (TGL GT2)",
+			want: GPURendererInfo{Vendor: "intel", Family: "Xe Graphics"},
+		},
+		{
+			raw:  "AMD Radeon RX 6700 XT (navi22, LLVM 15.0.6, DRM 3.49, 6.1.0)",
+			want: GPURendererInfo{Vendor: "amd", Family: "Radeon", Model: "RX 6700 XT"},
+		},
+		{
+			raw:  "llvmpipe (LLVM 15.0.7, 256 bits)",
+			want: GPURendererInfo{Vendor: "mesa", Family: "llvmpipe"},
+		},
+		{
+			raw:  "NVIDIA GeForce GTX 1060/PCIe/SSE2",
+			want: GPURendererInfo{Vendor: "nvidia", Family: "GeForce", Model: "GTX 1060"},
+		},
+		{
+			raw:  "Apple GPU",
+			want: GPURendererInfo{Vendor: "apple", Family: "Apple GPU"},
+		},
+		{
+			raw:  "Apple M1",
+			want: GPURendererInfo{Vendor: "apple", Family: "Apple M", Model: "M1"},
+		},
+		{
+			raw:  "Apple A15 GPU",
+			want: GPURendererInfo{Vendor: "apple", Family: "Apple A", Model: "A15"},
+		},
+		{
+			raw:  "Adreno (TM) 650",
+			want: GPURendererInfo{Vendor: "qualcomm", Family: "Adreno", Model: "650"},
+		},
+		{
+			raw:  "Adreno (TM) 740",
+			want: GPURendererInfo{Vendor: "qualcomm", Family: "Adreno", Model: "740"},
+		},
+		{
+			raw:  "Mali-G78 MP14",
+			want: GPURendererInfo{Vendor: "arm", Family: "Mali", Model: "G78"},
+		},
+		{
+			raw:  "Mali-T860",
+			want: GPURendererInfo{Vendor: "arm", Family: "Mali", Model: "T860"},
+		},
+		{
+			raw:  "PowerVR Rogue GE8320",
+			want: GPURendererInfo{Vendor: "imagination", Family: "PowerVR", Model: "Rogue GE8320"},
+		},
+		{
+			raw:  "Samsung Xclipse 920",
+			want: GPURendererInfo{Vendor: "samsung", Family: "Xclipse", Model: "920"},
+		},
+		{
+			raw:  "",
+			want: GPURendererInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseGPURenderer(tt.raw); got != tt.want {
+			t.Errorf("parseGPURenderer(%q)\n got  %+v\n want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
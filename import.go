@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type ImportResponse struct {
+	Accepted   int                `json:"accepted"`
+	Duplicates int                `json:"duplicates"`
+	Rejected   int                `json:"rejected"`
+	Results    []importLineResult `json:"results"`
+}
+
+type importLineResult struct {
+	Line        int       `json:"line"`
+	Status      string    `json:"status"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ReceivedAt  time.Time `json:"receivedAt,omitzero"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// importLine accepts both the /api/export line shape and a bare report.
+type importLine struct {
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Report     json.RawMessage `json:"report"`
+}
+
+// Import loads NDJSON reports (as written by Export) through the regular
+// validation, fingerprinting and merge path. Each report keeps its original
+// receivedAt; lines without one are stamped with now. Imports don't count
+// towards the submission totals, which describe live traffic.
+func (s *Store) Import(ctx context.Context, now time.Time, r io.Reader) (ImportResponse, error) {
+	resp := ImportResponse{Results: []importLineResult{}}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), int(s.cfg.MaxBodyBytes))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo += 1
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		res := s.importLine(ctx, now, line)
+		res.Line = lineNo
+		switch res.Status {
+		case "accepted":
+			resp.Accepted += 1
+		case "duplicate":
+			resp.Duplicates += 1
+		default:
+			resp.Rejected += 1
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	if resp.Accepted+resp.Duplicates > 0 {
+		s.cache.invalidate()
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d exceeds %d bytes", lineNo+1, s.cfg.MaxBodyBytes)
+		}
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (s *Store) importLine(ctx context.Context, now time.Time, line []byte) importLineResult {
+	var wrapped importLine
+	if err := json.Unmarshal(line, &wrapped); err != nil {
+		return importLineResult{Status: "rejected", Error: "invalid json: " + err.Error()}
+	}
+	body := line
+	if len(wrapped.Report) > 0 {
+		body = wrapped.Report
+	}
+
+	report, reason, err := decodeReport(body)
+	if err != nil {
+		return importLineResult{Status: "rejected", Error: reason + ": " + err.Error()}
+	}
+
+	fingerprint := extractFingerprint(report)
+	if fingerprint == "" {
+		fingerprint = fallbackFingerprint(report)
+	}
+	receivedAt := wrapped.ReceivedAt.UTC()
+	if wrapped.ReceivedAt.IsZero() {
+		receivedAt = now
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	outcome, err := s.importToBackend(ctx, receivedAt, fingerprint, report)
+	if err != nil {
+		return importLineResult{Status: "rejected", Fingerprint: fingerprint, Error: err.Error()}
+	}
+	res := importLineResult{Status: "accepted", Fingerprint: fingerprint, ReceivedAt: receivedAt}
+	if outcome == submitDuplicate {
+		res.Status = "duplicate"
+	}
+	return res
+}
+
+// importToBackend stores an imported report received at receivedAt. An older
+// line must not roll back a newer stored record: it is merged underneath
+// instead, keeping the stored receivedAt. The lookup and the write share
+// countersMu so a live submission can't land in between.
+func (s *Store) importToBackend(ctx context.Context, receivedAt time.Time, fingerprint string, report Report) (submitOutcome, error) {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	at := receivedAt
+	existing, ok, err := s.reports.Get(ctx, fingerprint)
+	if err != nil {
+		return 0, err
+	}
+	if ok && existing.ReceivedAt.After(receivedAt) {
+		report = mergeReportsPreferNew(existing.Report, report)
+		at = existing.ReceivedAt
+	}
+	outcome, _, err := s.submitToBackendLocked(ctx, at, "", fingerprint, report, nil)
+	return outcome, err
+}
+
+// adminAuthorized checks the bearer token for administrative endpoints. They
+// are disabled when no token is configured.
+func adminAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) == 1
+}
+
+func handleImport(w http.ResponseWriter, r *http.Request, store *Store) {
+	if store.cfg.AdminToken == "" {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+		return
+	}
+	if !adminAuthorized(r, store.cfg.AdminToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="hdr-detection"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, store.cfg.ImportMaxBytes)
+	defer body.Close()
+
+	resp, err := store.Import(r.Context(), time.Now(), body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "import aborted", "details": err.Error(), "partial": resp})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// runImportCommand implements `hdr-detection import`, loading a file written
+// by `hdr-detection export` (or GET /api/export?format=ndjson).
+func runImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	mongoURI := fs.String("mongo-uri", strings.TrimSpace(firstEnv("MONGO_URI", "MONGODB_URI")), "MongoDB connection string (env MONGO_URI/MONGODB_URI)")
+	storeSpec := fs.String("store", strings.TrimSpace(os.Getenv("STORE")), "report store: mongo or sqlite:<path> (env STORE; default mongo when MONGO_URI is set)")
+	maxReports := fs.Int("max-reports", 0, "prune to this many unique reports after each import line (0 = no cap; pass the server's -max-reports to match it)")
+	dedupeTTL := fs.Duration("dedupe-ttl", 24*time.Hour, "duplicate window (by fingerprint)")
+	in := fs.String("in", "", "NDJSON input file (default stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cfg := Config{
+		MaxReports:   *maxReports,
+		DedupeTTL:    *dedupeTTL,
+		MaxBodyBytes: 2 << 20,
+	}
+	reports, err := openReportStore(ctx, *storeSpec, *mongoURI, cfg)
+	if err != nil {
+		return fmt.Errorf("store init: %w", err)
+	}
+	if reports.Name() == "memory" {
+		return fmt.Errorf("importing into the in-memory store has no effect; set -store or -mongo-uri")
+	}
+	store := NewStore(cfg, reports)
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	resp, err := store.Import(context.Background(), time.Now(), r)
+	for _, res := range resp.Results {
+		if res.Status == "rejected" {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", res.Line, res.Error)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d new, %d duplicate, %d rejected\n", resp.Accepted, resp.Duplicates, resp.Rejected)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return nil
+}
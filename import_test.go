@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getHookStore runs afterGet once, after the first Get has read the store.
+type getHookStore struct {
+	ReportStore
+	afterGet func()
+}
+
+func (h *getHookStore) Get(ctx context.Context, fingerprint string) (StoredReport, bool, error) {
+	sr, ok, err := h.ReportStore.Get(ctx, fingerprint)
+	if f := h.afterGet; f != nil {
+		h.afterGet = nil
+		f()
+	}
+	return sr, ok, err
+}
+
+func importTestReport(country string) Report {
+	return Report{
+		SchemaVersion: currentReportSchemaVersion,
+		Client:        &ClientInfo{Fingerprint: &ClientFingerprint{SHA256: ptr("import-race")}},
+		Geo:           &GeoInfo{CountryCode: country},
+	}
+}
+
+// TestImportDoesNotRollBackLiveSubmit checks that a live submission landing
+// while an older import line is being merged still ends up on top.
+func TestImportDoesNotRollBackLiveSubmit(t *testing.T) {
+	cfg := Config{MaxReports: 10, DedupeTTL: time.Hour, MaxBodyBytes: 1 << 20, CleanupEvery: time.Minute}
+	reports := &getHookStore{ReportStore: newMemoryReportStore(cfg)}
+	s := NewStore(cfg, reports)
+
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	live := t0.Add(3 * time.Hour)
+	if _, err := s.SubmitRaw(t0, "", importTestReport("US"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	liveDone := make(chan error, 1)
+	reports.afterGet = func() {
+		go func() {
+			_, err := s.SubmitRaw(live, "", importTestReport("FR"), nil)
+			liveDone <- err
+		}()
+		// Give the live submission a chance to overtake the import.
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	line, err := json.Marshal(map[string]any{
+		"receivedAt": t0.Add(2 * time.Hour),
+		"report":     importTestReport("GB"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Import(context.Background(), t0.Add(4*time.Hour), strings.NewReader(string(line)+"\n"))
+	if err != nil || resp.Rejected != 0 {
+		t.Fatalf("import: %+v, %v", resp, err)
+	}
+	if err := <-liveDone; err != nil {
+		t.Fatal(err)
+	}
+
+	fp := extractFingerprint(importTestReport(""))
+	got, ok, err := reports.ReportStore.Get(context.Background(), fp)
+	if err != nil || !ok {
+		t.Fatalf("get %s: %v, %v", fp, ok, err)
+	}
+	if !got.ReceivedAt.Equal(live) {
+		t.Errorf("receivedAt = %s, want the live submission's %s", got.ReceivedAt, live)
+	}
+	if got.Report.Geo == nil || got.Report.Geo.CountryCode != "FR" {
+		t.Errorf("country = %+v, want FR from the live submission", got.Report.Geo)
+	}
+}
+
+func TestImportMergesOlderLineUnderneath(t *testing.T) {
+	cfg := Config{MaxReports: 10, DedupeTTL: time.Hour, MaxBodyBytes: 1 << 20, CleanupEvery: time.Minute}
+	s := NewStore(cfg, nil)
+
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	newer := importTestReport("FR")
+	if _, err := s.SubmitRaw(t0, "", newer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	older := importTestReport("GB")
+	older.Display = &DisplayInfo{ColorGamutP3: true}
+	line, err := json.Marshal(map[string]any{"receivedAt": t0.Add(-time.Hour), "report": older})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Import(context.Background(), t0, strings.NewReader(string(line))); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := s.reports.Get(context.Background(), extractFingerprint(newer))
+	if err != nil || !ok {
+		t.Fatalf("get: %v, %v", ok, err)
+	}
+	if !got.ReceivedAt.Equal(t0) {
+		t.Errorf("receivedAt = %s, want %s", got.ReceivedAt, t0)
+	}
+	if got.Report.Geo.CountryCode != "FR" {
+		t.Errorf("country = %q, want the newer FR", got.Report.Geo.CountryCode)
+	}
+	if got.Report.Display == nil || !got.Report.Display.ColorGamutP3 {
+		t.Errorf("display = %+v, want the section filled in from the older line", got.Report.Display)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema (draft 2020-12) needed to describe
+// the API's Go types.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 any                    `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *int64                 `json:"minimum,omitempty"`
+	Maximum              *int64                 `json:"maximum,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// jsonSchemaGenerator derives schemas from Go types the way encoding/json
+// maps them. Named structs become shared definitions referenced through
+// refPrefix; annotations, keyed by "TypeName.jsonField", add the constraints
+// reflection can't see.
+type jsonSchemaGenerator struct {
+	refPrefix   string
+	defs        map[string]*jsonSchema
+	annotations map[string]func(*jsonSchema)
+}
+
+func newJSONSchemaGenerator(refPrefix string, annotations map[string]func(*jsonSchema)) *jsonSchemaGenerator {
+	return &jsonSchemaGenerator{
+		refPrefix:   refPrefix,
+		defs:        make(map[string]*jsonSchema),
+		annotations: annotations,
+	}
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+func (g *jsonSchemaGenerator) schemaFor(t reflect.Type) *jsonSchema {
+	switch t {
+	case timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &jsonSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullableSchema(g.schemaFor(t.Elem()))
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", Format: "byte"}
+		}
+		return &jsonSchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return &jsonSchema{Ref: g.refPrefix + t.Name()}
+	default:
+		return &jsonSchema{}
+	}
+}
+
+func (g *jsonSchemaGenerator) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *jsonSchemaGenerator) addFields(s *jsonSchema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := g.schemaFor(field.Type)
+		if annotate := g.annotations[t.Name()+"."+name]; annotate != nil {
+			annotate(prop)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// nullableSchema allows null in place of s, as encoding/json does for
+// pointers.
+func nullableSchema(s *jsonSchema) *jsonSchema {
+	if typ, ok := s.Type.(string); ok && s.Ref == "" {
+		s.Type = []string{typ, "null"}
+		return s
+	}
+	return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+}
+
+// jsonFieldName is the name encoding/json uses for field ("" if skipped).
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,25 +30,48 @@ type Config struct {
 	MaxReports     int
 	DedupeTTL      time.Duration
 	MaxBodyBytes   int64
+	MaxRawBytes    int
 	RatePerMinute  float64
 	RateBurst      float64
 	CleanupEvery   time.Duration
 	LimiterIdleTTL time.Duration
+	// StatsPushdown lets backends that implement statsAggregator compute
+	// /api/stats server-side instead of loading every report.
+	StatsPushdown bool
+	// CacheTTL bounds how long /api/stats and /api/compat responses are
+	// reused between submissions (0 disables the cache).
+	CacheTTL time.Duration
+	// AdminToken is the bearer token for POST /api/import (empty disables it).
+	AdminToken     string
+	ImportMaxBytes int64
+	// BatchMaxBytes bounds a whole POST /api/reports/batch body; each item is
+	// still held to MaxBodyBytes.
+	BatchMaxBytes int64
+	// GeoIPDB is a MaxMind/DB-IP country .mmdb used for offline lookups;
+	// GeoIPHTTP additionally asks api.country.is for addresses it can't place.
+	GeoIPDB          string
+	GeoIPReloadEvery time.Duration
+	GeoIPHTTP        bool
+	// GeoChain overrides the country resolver chain (see
+	// parseCountryResolverChain); empty derives it from the GeoIP fields.
+	GeoChain string
+	// ASNDB is a MaxMind/DB-IP ASN .mmdb used to tag submissions with their
+	// network and flag datacenter traffic; DatacenterASNs extends the
+	// built-in list of hosting networks.
+	ASNDB          string
+	DatacenterASNs []uint
 }
 
 type Store struct {
 	mu sync.Mutex
 
-	cfg   Config
-	mongo *mongoStore
+	cfg     Config
+	reports ReportStore
 
 	startedAt time.Time
 
-	geo *geoResolver
-
-	reports      map[string]StoredReport // by fingerprint
-	order        []string                // insertion order
-	lastSeenByFP map[string]time.Time
+	geo CountryResolver
+	asn *asnDB
 
 	limiters map[string]*ipLimiter
 
@@ -57,13 +81,26 @@ type Store struct {
 	totalRateLimited int
 	totalRejected    int
 	lastCleanup      time.Time
+
+	// countersMu serializes backend writes with updates to the incremental
+	// stats counters.
+	countersMu sync.Mutex
+	counters   statsCounters
+
+	cache *responseCache
 }
 
 type StoredReport struct {
 	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"createdAt"`
 	ReceivedAt  time.Time `json:"receivedAt"`
 	IP          string    `json:"-"`
 	Report      Report    `json:"report"`
+	// Raw is the gzip-compressed JSON body as submitted (nil if absent or over MaxRawBytes).
+	Raw []byte `json:"-"`
+	// Migrated is set when Report was upgraded on read and differs from the
+	// stored document, or when the document's derived fields are stale.
+	Migrated bool `json:"-"`
 }
 
 type ipLimiter struct {
@@ -101,149 +138,35 @@ func minFloat(a, b float64) float64 {
 	return b
 }
 
-func NewStore(cfg Config, mongo *mongoStore) *Store {
-	s := &Store{
+func NewStore(cfg Config, reports ReportStore) *Store {
+	if reports == nil {
+		reports = newMemoryReportStore(cfg)
+	}
+	return &Store{
 		cfg:         cfg,
-		mongo:       mongo,
+		reports:     reports,
 		startedAt:   time.Now(),
-		geo:         newGeoResolver(),
+		geo:         countryResolverChain{newHeaderCountryResolver(nil)},
 		limiters:    make(map[string]*ipLimiter),
 		lastCleanup: time.Now(),
+		cache:       newResponseCache(cfg.CacheTTL),
 	}
-	if mongo == nil {
-		s.reports = make(map[string]StoredReport)
-		s.lastSeenByFP = make(map[string]time.Time)
-	}
-	return s
-}
-
-type geoResolver struct {
-	mu           sync.Mutex
-	cache        map[string]geoCacheEntry
-	ttl          time.Duration
-	negativeTTL  time.Duration
-	cleanupEvery time.Duration
-	lastCleanup  time.Time
-	httpClient   *http.Client
-}
-
-type geoCacheEntry struct {
-	countryCode string
-	expiresAt   time.Time
-}
-
-func newGeoResolver() *geoResolver {
-	return &geoResolver{
-		cache:        make(map[string]geoCacheEntry),
-		ttl:          24 * time.Hour,
-		negativeTTL:  1 * time.Hour,
-		cleanupEvery: 10 * time.Minute,
-		lastCleanup:  time.Now(),
-		httpClient: &http.Client{
-			Timeout: 1500 * time.Millisecond,
-		},
-	}
-}
-
-func (g *geoResolver) CountryCode(ctx context.Context, now time.Time, ipStr string, r *http.Request) string {
-	// Prefer trusted proxy geolocation headers when available (no outbound call).
-	if trustProxyHeadersForRequest(r) {
-		if code := countryCodeFromHeaders(r.Header); code != "" {
-			return code
-		}
-	}
-
-	ip := net.ParseIP(strings.Trim(ipStr, "[]"))
-	if ip == nil || !isPublicIP(ip) {
-		// Local dev / internal proxy case: we may only see a private/loopback address.
-		// Fall back to resolving the requester's public egress IP (api.country.is/).
-		if trustProxyHeadersForRequest(r) {
-			const selfKey = "_self"
-			if code, ok := g.getCached(now, selfKey); ok {
-				return code
-			}
-			code, err := lookupCountryCodeCountryIs(ctx, g.httpClient, "")
-			if err != nil {
-				g.setCached(now, selfKey, "")
-				return ""
-			}
-			g.setCached(now, selfKey, code)
-			return code
-		}
-		return ""
-	}
-
-	canonicalIP := ip.String()
-
-	if code, ok := g.getCached(now, canonicalIP); ok {
-		return code
-	}
-
-	code, err := lookupCountryCodeCountryIs(ctx, g.httpClient, canonicalIP)
-	if err != nil {
-		g.setCached(now, canonicalIP, "")
-		return ""
-	}
-	g.setCached(now, canonicalIP, code)
-	return code
 }
 
-func (g *geoResolver) getCached(now time.Time, ip string) (string, bool) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.cleanupLocked(now)
-
-	entry, ok := g.cache[ip]
-	if !ok || now.After(entry.expiresAt) {
-		return "", false
-	}
-	return entry.countryCode, true
+// defaultGeoHeaders are a few common proxy/CDN geo headers. Values are
+// typically ISO 3166-1 alpha-2.
+var defaultGeoHeaders = []string{
+	"CF-IPCountry",
+	"X-AppEngine-Country",
+	"X-Vercel-IP-Country",
+	"X-Cloudfront-Viewer-Country",
+	"Fastly-GeoIP-Country-Code",
+	"X-Country-Code",
+	"X-Geo-Country",
 }
 
-func (g *geoResolver) setCached(now time.Time, ip string, code string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.cleanupLocked(now)
-
-	ttl := g.ttl
-	if code == "" {
-		ttl = g.negativeTTL
-	}
-	g.cache[ip] = geoCacheEntry{
-		countryCode: code,
-		expiresAt:   now.Add(ttl),
-	}
-}
-
-func (g *geoResolver) cleanupLocked(now time.Time) {
-	if now.Sub(g.lastCleanup) < g.cleanupEvery {
-		return
-	}
-	for ip, e := range g.cache {
-		if now.After(e.expiresAt) {
-			delete(g.cache, ip)
-		}
-	}
-	g.lastCleanup = now
-}
-
-func trustProxyHeadersForRequest(r *http.Request) bool {
-	remoteIP := stripPortMaybe(r.RemoteAddr)
-	return isRender() || isPrivateOrLoopbackIP(remoteIP)
-}
-
-func countryCodeFromHeaders(h http.Header) string {
-	// A few common proxy/CDN geo headers. Values are typically ISO 3166-1 alpha-2.
-	candidates := []string{
-		"CF-IPCountry",
-		"X-AppEngine-Country",
-		"X-Vercel-IP-Country",
-		"X-Cloudfront-Viewer-Country",
-		"Fastly-GeoIP-Country-Code",
-		"X-Country-Code",
-		"X-Geo-Country",
-	}
-	for _, key := range candidates {
+func countryCodeFromHeaders(h http.Header, names []string) string {
+	for _, key := range names {
 		if code := normalizeCountryCode(h.Get(key)); code != "" {
 			return code
 		}
@@ -335,8 +258,20 @@ func mergeReportsPreferNew(next Report, prev Report) Report {
 	merged := next
 
 	// Preserve server-derived geo if the new report doesn't have it.
-	if (merged.Geo == nil || merged.Geo.CountryCode == "") && prev.Geo != nil && prev.Geo.CountryCode != "" {
-		merged.Geo = prev.Geo
+	if prev.Geo != nil {
+		geo := GeoInfo{}
+		if merged.Geo != nil {
+			geo = *merged.Geo
+		}
+		if geo.CountryCode == "" {
+			geo.CountryCode = prev.Geo.CountryCode
+		}
+		if geo.ASN == 0 {
+			geo.ASN, geo.ASOrg, geo.Datacenter = prev.Geo.ASN, prev.Geo.ASOrg, prev.Geo.Datacenter
+		}
+		if geo != (GeoInfo{}) {
+			merged.Geo = &geo
+		}
 	}
 
 	// Avoid wiping structured client info if the new report is missing it.
@@ -361,81 +296,6 @@ func mergeReportsPreferNew(next Report, prev Report) Report {
 	return merged
 }
 
-func (s *Store) submitMemory(now time.Time, ip string, fingerprint string, report Report) (submitResult, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.maybeCleanupLocked(now)
-
-	s.totalReceived += 1
-
-	if lastSeen, ok := s.lastSeenByFP[fingerprint]; ok && now.Sub(lastSeen) < s.cfg.DedupeTTL {
-		s.totalDuplicate += 1
-		s.lastSeenByFP[fingerprint] = now
-		if existing, ok := s.reports[fingerprint]; ok {
-			s.reports[fingerprint] = StoredReport{
-				Fingerprint: fingerprint,
-				ReceivedAt:  now,
-				IP:          ip,
-				Report:      mergeReportsPreferNew(report, existing.Report),
-			}
-		}
-		return submitResult{
-			Status:      "duplicate",
-			Fingerprint: fingerprint,
-			Stored:      false,
-			StoredCount: len(s.reports),
-			ReceivedAt:  now,
-			Message:     "Duplicate fingerprint within dedupe window (updated existing record).",
-		}, nil
-	}
-
-	// Accept: replace old entry if exists.
-	if _, ok := s.reports[fingerprint]; ok {
-		s.reports[fingerprint] = StoredReport{
-			Fingerprint: fingerprint,
-			ReceivedAt:  now,
-			IP:          ip,
-			Report:      report,
-		}
-		s.lastSeenByFP[fingerprint] = now
-		s.totalAccepted += 1
-		return submitResult{
-			Status:      "accepted",
-			Fingerprint: fingerprint,
-			Stored:      true,
-			StoredCount: len(s.reports),
-			ReceivedAt:  now,
-			Message:     "Updated existing fingerprint (outside dedupe window).",
-		}, nil
-	}
-
-	s.reports[fingerprint] = StoredReport{
-		Fingerprint: fingerprint,
-		ReceivedAt:  now,
-		IP:          ip,
-		Report:      report,
-	}
-	s.order = append(s.order, fingerprint)
-	s.lastSeenByFP[fingerprint] = now
-	s.totalAccepted += 1
-
-	for len(s.order) > s.cfg.MaxReports {
-		oldest := s.order[0]
-		s.order = s.order[1:]
-		delete(s.reports, oldest)
-		delete(s.lastSeenByFP, oldest)
-	}
-
-	return submitResult{
-		Status:      "accepted",
-		Fingerprint: fingerprint,
-		Stored:      true,
-		StoredCount: len(s.reports),
-		ReceivedAt:  now,
-		Message:     "Stored new fingerprint.",
-	}, nil
-}
-
 func (s *Store) Reject(now time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -465,16 +325,85 @@ func (s *Store) maybeCleanupLocked(now time.Time) {
 	s.lastCleanup = now
 }
 
-func (s *Store) SubmitRaw(now time.Time, ip string, report Report, _rawJSON []byte) (submitResult, error) {
+func (s *Store) SubmitRaw(now time.Time, ip string, report Report, rawJSON []byte) (submitResult, error) {
 	fingerprint := extractFingerprint(report)
 	if fingerprint == "" {
 		fingerprint = fallbackFingerprint(report)
 	}
 
-	if s.mongo != nil {
-		return s.submitMongo(now, ip, fingerprint, report)
+	s.mu.Lock()
+	s.maybeCleanupLocked(now)
+	s.totalReceived += 1
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	raw := compressRawReport(rawJSON, s.cfg.MaxRawBytes)
+	outcome, storedCount, err := s.submitToBackend(ctx, now, ip, fingerprint, report, raw)
+	if err != nil {
+		s.mu.Lock()
+		s.totalRejected += 1
+		s.mu.Unlock()
+		return submitResult{}, err
+	}
+
+	s.mu.Lock()
+	if outcome == submitDuplicate {
+		s.totalDuplicate += 1
+	} else {
+		s.totalAccepted += 1
+	}
+	s.mu.Unlock()
+	// Duplicates are merged into the stored record too, so both change the aggregates.
+	s.cache.invalidate()
+
+	res := submitResult{
+		Status:      "accepted",
+		Fingerprint: fingerprint,
+		Stored:      true,
+		StoredCount: storedCount,
+		ReceivedAt:  now,
+	}
+	switch outcome {
+	case submitDuplicate:
+		res.Status = "duplicate"
+		res.Stored = false
+		res.Message = "Duplicate fingerprint within dedupe window (updated existing record)."
+	case submitUpdated:
+		res.Message = "Updated existing fingerprint (outside dedupe window)."
+	default:
+		res.Message = "Stored new fingerprint."
+	}
+	return res, nil
+}
+
+func (s *Store) submitToBackend(ctx context.Context, now time.Time, ip string, fingerprint string, report Report, raw []byte) (submitOutcome, int, error) {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	return s.submitToBackendLocked(ctx, now, ip, fingerprint, report, raw)
+}
+
+// submitToBackendLocked is submitToBackend for callers that already hold
+// countersMu.
+func (s *Store) submitToBackendLocked(ctx context.Context, now time.Time, ip string, fingerprint string, report Report, raw []byte) (submitOutcome, int, error) {
+	change, err := s.reports.Submit(ctx, now, ip, fingerprint, report, raw)
+	if err != nil {
+		return 0, 0, err
+	}
+	evicted, err := s.reports.Prune(ctx)
+	if err != nil {
+		// The write landed but the eviction set is unknown; reseed on next use.
+		s.counters = statsCounters{}
+		return 0, 0, err
+	}
+	s.applySubmitLocked(change, evicted)
+
+	storedCount, err := s.reports.Count(ctx)
+	if err != nil {
+		return 0, 0, err
 	}
-	return s.submitMemory(now, ip, fingerprint, report)
+	return change.Outcome, storedCount, nil
 }
 
 type reportMeta struct {
@@ -483,15 +412,24 @@ type reportMeta struct {
 	DeviceType      string
 	CPUArch         string
 	Country         string
+	ASOrg           string
+	Datacenter      bool
+	GPUVendor       string
+	GPUArchitecture string
+	GPUFamily       string
+	GPUModel        string
+	GPUBackend      string
 	AppleSilicon    *bool
 	WebGPUAvailable bool
 	WebGL2Available bool
 	WebGL1Available bool
 	HDRDisplay      bool
+	SchemaVersion   int
 }
 
 func reportMetaFromReport(r Report) reportMeta {
 	meta := reportMeta{
+		SchemaVersion:   reportSchemaVersion(r),
 		WebGPUAvailable: r.WebGPU.Available,
 		WebGL2Available: r.WebGL2.Available,
 		WebGL1Available: r.WebGL1.Available,
@@ -510,8 +448,16 @@ func reportMetaFromReport(r Report) reportMeta {
 		}
 	}
 	meta.CPUArch = clampString(reportCPUArch(r), 64)
+	meta.GPUVendor = clampString(reportGPUVendor(r), 64)
+	meta.GPUArchitecture = clampString(reportGPUArchitecture(r), 64)
+	renderer := reportGPURenderer(r)
+	meta.GPUFamily = clampString(renderer.Family, 64)
+	meta.GPUModel = clampString(renderer.Model, 128)
+	meta.GPUBackend = clampString(renderer.Backend, 32)
 	if r.Geo != nil {
 		meta.Country = clampString(r.Geo.CountryCode, 8)
+		meta.ASOrg = clampString(r.Geo.ASOrg, 128)
+		meta.Datacenter = r.Geo.Datacenter
 	}
 
 	meta.AppleSilicon = reportIsAppleSilicon(r)
@@ -530,18 +476,26 @@ func clampString(s string, max int) string {
 }
 
 type Report struct {
-	GeneratedAt string       `json:"generatedAt,omitempty"`
-	UserAgent   string       `json:"userAgent,omitempty"`
-	Client      *ClientInfo  `json:"client,omitempty"`
-	Geo         *GeoInfo     `json:"geo,omitempty"`
-	Display     *DisplayInfo `json:"display,omitempty"`
-	WebGPU      WebGPUReport `json:"webgpu"`
-	WebGL2      WebGLReport  `json:"webgl2"`
-	WebGL1      WebGLReport  `json:"webgl1"`
+	// SchemaVersion identifies the detector build that produced the report
+	// (see currentReportSchemaVersion); 0 means an unversioned client.
+	SchemaVersion int          `json:"schemaVersion,omitempty"`
+	GeneratedAt   string       `json:"generatedAt,omitempty"`
+	UserAgent     string       `json:"userAgent,omitempty"`
+	Client        *ClientInfo  `json:"client,omitempty"`
+	Geo           *GeoInfo     `json:"geo,omitempty"`
+	Display       *DisplayInfo `json:"display,omitempty"`
+	WebGPU        WebGPUReport `json:"webgpu"`
+	WebGL2        WebGLReport  `json:"webgl2"`
+	WebGL1        WebGLReport  `json:"webgl1"`
 }
 
 type GeoInfo struct {
 	CountryCode string `json:"countryCode,omitempty"`
+	// ASN, ASOrg and Datacenter come from the -asn-db lookup at submission
+	// time.
+	ASN        uint   `json:"asn,omitempty"`
+	ASOrg      string `json:"asOrg,omitempty"`
+	Datacenter bool   `json:"datacenter,omitempty"`
 }
 
 type DisplayInfo struct {
@@ -552,6 +506,47 @@ type DisplayInfo struct {
 	ColorGamutRec2020     bool   `json:"colorGamutRec2020,omitempty"`
 	ColorGamutP3          bool   `json:"colorGamutP3,omitempty"`
 	ColorGamutSRGB        bool   `json:"colorGamutSRGB,omitempty"`
+
+	HDRVideoDecoding *HDRVideoDecoding `json:"hdrVideoDecoding,omitempty"`
+}
+
+// HDRVideoDecoding holds MediaCapabilities.decodingInfo() results for the
+// HDR (PQ, Rec.2020) codec variants probed by the detector.
+type HDRVideoDecoding struct {
+	Available bool                  `json:"available"`
+	Supported bool                  `json:"supported"`
+	Results   []HDRVideoCodecResult `json:"results,omitempty"`
+}
+
+type HDRVideoCodecResult struct {
+	Key            string `json:"key"`
+	Supported      bool   `json:"supported"`
+	Smooth         bool   `json:"smooth"`
+	PowerEfficient bool   `json:"powerEfficient"`
+}
+
+// hdrVideoCodecs lists the codec keys app.js probes, with their compat matrix rows.
+var hdrVideoCodecs = []struct {
+	Key         string
+	RowID       string
+	Label       string
+	Description string
+}{
+	{Key: "vp9-pq", RowID: "hdrVideoVp9", Label: "HDR video: VP9 PQ", Description: "VP9 Profile 2 10-bit PQ/Rec.2020 decode (MediaCapabilities)."},
+	{Key: "av1-pq", RowID: "hdrVideoAv1", Label: "HDR video: AV1 PQ", Description: "AV1 Main 10-bit PQ/Rec.2020 decode (MediaCapabilities)."},
+	{Key: "hevc-pq", RowID: "hdrVideoHevc", Label: "HDR video: HEVC PQ", Description: "HEVC Main 10 PQ/Rec.2020 decode (MediaCapabilities)."},
+}
+
+func reportHDRVideoCodec(r Report, key string) (HDRVideoCodecResult, bool) {
+	if r.Display == nil || r.Display.HDRVideoDecoding == nil || !r.Display.HDRVideoDecoding.Available {
+		return HDRVideoCodecResult{}, false
+	}
+	for _, res := range r.Display.HDRVideoDecoding.Results {
+		if strings.EqualFold(res.Key, key) {
+			return res, true
+		}
+	}
+	return HDRVideoCodecResult{}, false
 }
 
 type ClientInfo struct {
@@ -621,13 +616,22 @@ type WebGPUReport struct {
 	Available             bool           `json:"available"`
 	SecureContext         bool           `json:"secureContext"`
 	PreferredCanvasFormat string         `json:"preferredCanvasFormat,omitempty"`
+	AdapterInfo           *WebGPUAdapter `json:"adapterInfo,omitempty"`
 	AdapterFeatures       []string       `json:"adapterFeatures,omitempty"`
 	DeviceFeatures        []string       `json:"deviceFeatures,omitempty"`
 	Warnings              []string       `json:"warnings,omitempty"`
 	Errors                []string       `json:"errors,omitempty"`
+	Limits                *WebGPULimits  `json:"limits,omitempty"`
 	Formats               []WebGPUFormat `json:"formats,omitempty"`
 }
 
+type WebGPUAdapter struct {
+	Vendor       string `json:"vendor,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Device       string `json:"device,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
 type WebGPUFormat struct {
 	Format     string `json:"format"`
 	Kind       string `json:"kind,omitempty"`
@@ -716,15 +720,26 @@ func fallbackFingerprint(r Report) string {
 }
 
 func validateReport(r *Report) error {
+	var errs reportFieldErrors
+	if r.SchemaVersion < 0 || r.SchemaVersion > currentReportSchemaVersion {
+		errs = append(errs, reportFieldError{Field: "schemaVersion", Message: fmt.Sprintf("unsupported schemaVersion %d (max %d)", r.SchemaVersion, currentReportSchemaVersion)})
+	}
 	// Basic structural checks (avoid huge payloads / nonsense).
 	if r.WebGPU.Available && len(r.WebGPU.Formats) == 0 {
 		// WebGPU check usually returns a full list, but avoid rejecting older clients; treat as warning only.
-		return nil
+		return errs.errOrNil()
 	}
 	if len(r.WebGPU.Formats) > 300 {
-		return fmt.Errorf("too many WebGPU formats: %d", len(r.WebGPU.Formats))
+		errs = append(errs, reportFieldError{Field: "webgpu.formats", Message: fmt.Sprintf("too many WebGPU formats: %d", len(r.WebGPU.Formats))})
+		return errs.errOrNil()
 	}
-	return nil
+	formats, err := normalizeWebGPUFormats(r.WebGPU.Formats)
+	if err != nil {
+		errs = append(errs, asReportFieldErrors(err)...)
+		return errs.errOrNil()
+	}
+	r.WebGPU.Formats = formats
+	return errs.errOrNil()
 }
 
 type StatsResponse struct {
@@ -744,11 +759,17 @@ type Selection struct {
 }
 
 type StatsFilter struct {
-	Browser         string   `json:"browser,omitempty"`
-	OS              string   `json:"os,omitempty"`
-	Country         string   `json:"country,omitempty"`
+	Browser string `json:"browser,omitempty"`
+	OS      string `json:"os,omitempty"`
+	// Country, Region and Continent each accept several values (any of
+	// them matches); see StatsFilter.countrySet.
+	Country         []string `json:"country,omitempty"`
+	Region          []string `json:"region,omitempty"`
+	Continent       []string `json:"continent,omitempty"`
 	DeviceType      string   `json:"deviceType,omitempty"`
 	CPUArch         string   `json:"cpuArch,omitempty"`
+	GPUVendor       string   `json:"gpuVendor,omitempty"`
+	GPUArchitecture string   `json:"gpuArchitecture,omitempty"`
 	AppleSilicon    *bool    `json:"appleSilicon,omitempty"`
 	WebGPUAvailable *bool    `json:"webgpuAvailable,omitempty"`
 	WebGL2Available *bool    `json:"webgl2Available,omitempty"`
@@ -757,6 +778,25 @@ type StatsFilter struct {
 	WebGPUFeature   []string `json:"webgpuFeature,omitempty"`
 	WebGL2Ext       []string `json:"webgl2Ext,omitempty"`
 	WebGL1Ext       []string `json:"webgl1Ext,omitempty"`
+	HDRVideoCodec   []string `json:"hdrVideoCodec,omitempty"`
+	// WebGPUMinLimits requires each named adapter limit to be at least as
+	// capable as the given value: at least it for the max* limits, at most it
+	// for the min*Alignment ones (see webgpuLimitLowerIsBetter).
+	WebGPUMinLimits map[string]int64 `json:"webgpuMinLimit,omitempty"`
+	// MinSchemaVersion keeps reports produced by detector schema >= N.
+	MinSchemaVersion int `json:"minSchemaVersion,omitempty"`
+	// ExcludeDatacenter drops reports submitted from cloud/hosting networks
+	// (see asnDB).
+	ExcludeDatacenter bool `json:"excludeDatacenter,omitempty"`
+}
+
+func (f StatsFilter) isEmpty() bool {
+	return f.Browser == "" && f.OS == "" && len(f.Country) == 0 && len(f.Region) == 0 && len(f.Continent) == 0 &&
+		f.DeviceType == "" && f.CPUArch == "" &&
+		f.GPUVendor == "" && f.GPUArchitecture == "" &&
+		f.AppleSilicon == nil && f.WebGPUAvailable == nil && f.WebGL2Available == nil && f.WebGL1Available == nil && f.HDRDisplay == nil &&
+		len(f.WebGPUFeature) == 0 && len(f.WebGL2Ext) == 0 && len(f.WebGL1Ext) == 0 && len(f.HDRVideoCodec) == 0 &&
+		len(f.WebGPUMinLimits) == 0 && f.MinSchemaVersion <= 1 && !f.ExcludeDatacenter
 }
 
 type CompatResponse struct {
@@ -779,12 +819,14 @@ type CompatOptions struct {
 }
 
 type CompatColumn struct {
-	Key        string `json:"key"`
-	Browser    string `json:"browser,omitempty"`
-	OS         string `json:"os,omitempty"`
-	DeviceType string `json:"deviceType,omitempty"`
-	Matched    int    `json:"matched"`
-	TestedAny  int    `json:"testedAny"`
+	Key             string `json:"key"`
+	Browser         string `json:"browser,omitempty"`
+	OS              string `json:"os,omitempty"`
+	DeviceType      string `json:"deviceType,omitempty"`
+	GPUVendor       string `json:"gpuVendor,omitempty"`
+	GPUArchitecture string `json:"gpuArchitecture,omitempty"`
+	Matched         int    `json:"matched"`
+	TestedAny       int    `json:"testedAny"`
 }
 
 type CompatCell struct {
@@ -807,14 +849,25 @@ type Totals struct {
 	Duplicates    int `json:"duplicates"`
 	RateLimited   int `json:"rateLimited"`
 	Rejected      int `json:"rejected"`
+	// Datacenter counts stored reports submitted from cloud/hosting
+	// networks; DatacenterShare is its fraction of Stored.
+	Datacenter      int     `json:"datacenter"`
+	DatacenterShare float64 `json:"datacenterShare"`
 }
 
 type Breakdown struct {
-	Browsers    []CountItem `json:"browsers"`
-	OS          []CountItem `json:"os"`
-	Countries   []CountItem `json:"countries"`
-	DeviceTypes []CountItem `json:"deviceTypes"`
-	CPUArch     []CountItem `json:"cpuArch"`
+	Browsers  []CountItem `json:"browsers"`
+	OS        []CountItem `json:"os"`
+	Countries []CountItem `json:"countries"`
+	// Regions, Subregions and Continents roll Countries up through the
+	// UN M49 table in geo_regions.csv.
+	Regions          []CountItem `json:"regions"`
+	Subregions       []CountItem `json:"subregions"`
+	Continents       []CountItem `json:"continents"`
+	DeviceTypes      []CountItem `json:"deviceTypes"`
+	CPUArch          []CountItem `json:"cpuArch"`
+	GPUVendors       []CountItem `json:"gpuVendors"`
+	GPUArchitectures []CountItem `json:"gpuArchitectures"`
 }
 
 type CountItem struct {
@@ -823,9 +876,10 @@ type CountItem struct {
 }
 
 type WebGPUStats struct {
-	AvailableCount int          `json:"availableCount"`
-	TestedCount    int          `json:"testedCount"`
-	Formats        []FormatStat `json:"formats"`
+	AvailableCount int               `json:"availableCount"`
+	TestedCount    int               `json:"testedCount"`
+	Limits         WebGPULimitsStats `json:"limits"`
+	Formats        []FormatStat      `json:"formats"`
 }
 
 type FormatStat struct {
@@ -880,57 +934,36 @@ type WebGLAnisotropyStats struct {
 }
 
 type DisplayStats struct {
-	DynamicRangeHigh  int `json:"dynamicRangeHigh"`
-	ColorGamutRec2020 int `json:"colorGamutRec2020"`
-	ColorGamutP3      int `json:"colorGamutP3"`
+	DynamicRangeHigh  int                 `json:"dynamicRangeHigh"`
+	ColorGamutRec2020 int                 `json:"colorGamutRec2020"`
+	ColorGamutP3      int                 `json:"colorGamutP3"`
+	HDRVideoTested    int                 `json:"hdrVideoTested"`
+	HDRVideoCodecs    []HDRVideoCodecStat `json:"hdrVideoCodecs"`
 }
 
-func (s *Store) Stats(now time.Time, filter StatsFilter) (StatsResponse, error) {
-	if s.mongo != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
+type HDRVideoCodecStat struct {
+	Codec          string `json:"codec"`
+	Tested         int    `json:"tested"`
+	Supported      int    `json:"supported"`
+	Smooth         int    `json:"smooth"`
+	PowerEfficient int    `json:"powerEfficient"`
+}
 
-		storedCount, err := s.countReportsFromMongo(ctx)
-		if err != nil {
-			return StatsResponse{}, err
-		}
-		reports, err := s.loadReportsFromMongo(ctx)
-		if err != nil {
-			return StatsResponse{}, err
+func (s *Store) Stats(now time.Time, filter StatsFilter) (StatsResponse, error) {
+	if filter.isEmpty() {
+		return s.unfilteredStats(now)
+	}
+	if agg, ok := s.reports.(statsAggregator); ok && s.cfg.StatsPushdown {
+		stats, ok, err := s.aggregateStats(agg, now, filter)
+		if err != nil || ok {
+			return stats, err
 		}
-
-		s.mu.Lock()
-		totals := Totals{
-			Stored:        storedCount,
-			TotalReceived: s.totalReceived,
-			Accepted:      s.totalAccepted,
-			Duplicates:    s.totalDuplicate,
-			RateLimited:   s.totalRateLimited,
-			Rejected:      s.totalRejected,
-		}
-		startedAt := s.startedAt
-		s.mu.Unlock()
-
-		return computeStats(now, startedAt, totals, reports, filter), nil
 	}
 
-	s.mu.Lock()
-	reports := make([]Report, 0, len(s.reports))
-	for _, stored := range s.reports {
-		reports = append(reports, stored.Report)
-	}
-	storedCount := len(s.reports)
-	totals := Totals{
-		Stored:        storedCount,
-		TotalReceived: s.totalReceived,
-		Accepted:      s.totalAccepted,
-		Duplicates:    s.totalDuplicate,
-		RateLimited:   s.totalRateLimited,
-		Rejected:      s.totalRejected,
+	totals, startedAt, reports, err := s.snapshot()
+	if err != nil {
+		return StatsResponse{}, err
 	}
-	startedAt := s.startedAt
-	s.mu.Unlock()
-
 	return computeStats(now, startedAt, totals, reports, filter), nil
 }
 
@@ -942,335 +975,117 @@ func (s *Store) Compat(now time.Time, filter StatsFilter, opts CompatOptions) (C
 		opts.Limit = 40
 	}
 
-	if s.mongo != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
+	totals, startedAt, reports, err := s.snapshot()
+	if err != nil {
+		return CompatResponse{}, err
+	}
+	return computeCompat(now, startedAt, totals, reports, filter, opts), nil
+}
 
-		storedCount, err := s.countReportsFromMongo(ctx)
-		if err != nil {
-			return CompatResponse{}, err
-		}
-		reports, err := s.loadReportsFromMongo(ctx)
-		if err != nil {
-			return CompatResponse{}, err
-		}
+// aggregateStats asks the backend to compute the stats server-side. ok is
+// false when the backend can't express the filter.
+func (s *Store) aggregateStats(agg statsAggregator, now time.Time, filter StatsFilter) (StatsResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-		s.mu.Lock()
-		totals := Totals{
-			Stored:        storedCount,
-			TotalReceived: s.totalReceived,
-			Accepted:      s.totalAccepted,
-			Duplicates:    s.totalDuplicate,
-			RateLimited:   s.totalRateLimited,
-			Rejected:      s.totalRejected,
-		}
-		startedAt := s.startedAt
-		s.mu.Unlock()
+	stats, ok, err := agg.AggregateStats(ctx, filter)
+	if err != nil || !ok {
+		return StatsResponse{}, false, err
+	}
+	storedCount, err := s.reports.Count(ctx)
+	if err != nil {
+		return StatsResponse{}, false, err
+	}
+	datacenter, err := agg.CountDatacenter(ctx)
+	if err != nil {
+		return StatsResponse{}, false, err
+	}
+	totals, startedAt := s.totals(storedCount, datacenter)
+	stats.GeneratedAt = now
+	stats.UptimeSec = int64(now.Sub(startedAt).Seconds())
+	stats.Totals = totals
+	return stats, true, nil
+}
+
+// snapshot loads the stored reports from the backend together with the
+// current submission counters.
+func (s *Store) snapshot() (Totals, time.Time, []Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-		return computeCompat(now, startedAt, totals, reports, filter, opts), nil
+	storedCount, err := s.reports.Count(ctx)
+	if err != nil {
+		return Totals{}, time.Time{}, nil, err
+	}
+	stored, err := s.reports.Load(ctx)
+	if err != nil {
+		return Totals{}, time.Time{}, nil, err
+	}
+	reports := make([]Report, 0, len(stored))
+	datacenter := 0
+	for _, sr := range stored {
+		reports = append(reports, sr.Report)
+		if sr.Report.Geo != nil && sr.Report.Geo.Datacenter {
+			datacenter += 1
+		}
 	}
 
+	totals, startedAt := s.totals(storedCount, datacenter)
+	return totals, startedAt, reports, nil
+}
+
+func (s *Store) totals(storedCount int, datacenter int) (Totals, time.Time) {
 	s.mu.Lock()
-	reports := make([]Report, 0, len(s.reports))
-	for _, stored := range s.reports {
-		reports = append(reports, stored.Report)
-	}
-	totals := Totals{
-		Stored:        len(s.reports),
+	defer s.mu.Unlock()
+	t := Totals{
+		Stored:        storedCount,
 		TotalReceived: s.totalReceived,
 		Accepted:      s.totalAccepted,
 		Duplicates:    s.totalDuplicate,
 		RateLimited:   s.totalRateLimited,
 		Rejected:      s.totalRejected,
+		Datacenter:    datacenter,
 	}
-	startedAt := s.startedAt
-	s.mu.Unlock()
-
-	return computeCompat(now, startedAt, totals, reports, filter, opts), nil
-}
-
-func computeStats(now time.Time, startedAt time.Time, totals Totals, reports []Report, filter StatsFilter) StatsResponse {
-	normalizeWebGLCompressedFormat := func(s string) string {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return ""
-		}
-		switch strings.ToLower(s) {
-		case "0x8c00":
-			return "COMPRESSED_RGB_PVRTC_4BPPV1_IMG"
-		case "0x8c01":
-			return "COMPRESSED_RGB_PVRTC_2BPPV1_IMG"
-		case "0x8c02":
-			return "COMPRESSED_RGBA_PVRTC_4BPPV1_IMG"
-		case "0x8c03":
-			return "COMPRESSED_RGBA_PVRTC_2BPPV1_IMG"
-		default:
-			return s
-		}
+	if storedCount > 0 {
+		t.DatacenterShare = float64(datacenter) / float64(storedCount)
 	}
+	return t, s.startedAt
+}
 
-	browserCounts := map[string]int{}
-	osCounts := map[string]int{}
-	countryCounts := map[string]int{}
-	deviceCounts := map[string]int{}
-	cpuCounts := map[string]int{}
-
-	webgl2ExtCounts := map[string]int{}
-	webgl1ExtCounts := map[string]int{}
-	webgl2CompressedCounts := map[string]int{}
-	webgl1CompressedCounts := map[string]int{}
-
-	webgl2Limits := newWebGLLimitsCounter()
-	webgl1Limits := newWebGLLimitsCounter()
-	webgl2Tests := WebGLTextureTestStats{}
-	webgl1Tests := WebGLTextureTestStats{}
-	webgl2AnisoCounts := map[string]int{}
-	webgl1AnisoCounts := map[string]int{}
-	webgl2AnisoSupported := 0
-	webgl1AnisoSupported := 0
-
-	formatMap := map[string]*FormatStat{}
-	webgpuAvailable := 0
-	webgpuTested := 0
-	webgl2Available := 0
-	webgl1Available := 0
-
-	dynamicRangeHigh := 0
-	colorRec2020 := 0
-	colorP3 := 0
-
-	matched := 0
-	for _, r := range reports {
-		if !matchesStatsFilter(r, filter) {
-			continue
-		}
-		matched += 1
-
-		// Client breakdowns
-		if r.Client != nil && r.Client.Parsed != nil {
-			if r.Client.Parsed.Browser != nil && r.Client.Parsed.Browser.Name != "" {
-				browserCounts[r.Client.Parsed.Browser.Name] += 1
-			}
-			if r.Client.Parsed.OS != nil && r.Client.Parsed.OS.Name != "" {
-				osCounts[r.Client.Parsed.OS.Name] += 1
-			}
-			if r.Client.Parsed.Device != nil && r.Client.Parsed.Device.Type != "" {
-				deviceCounts[r.Client.Parsed.Device.Type] += 1
-			}
-
-			if arch := reportCPUArch(r); arch != "" {
-				cpuCounts[arch] += 1
-			}
-		} else if r.UserAgent != "" {
-			browserCounts["Unknown"] += 1
-			osCounts["Unknown"] += 1
-			deviceCounts["Unknown"] += 1
-			cpuCounts["Unknown"] += 1
-		}
-
-		// Geo
-		if r.Geo != nil && r.Geo.CountryCode != "" {
-			countryCounts[r.Geo.CountryCode] += 1
-		} else {
-			countryCounts["Unknown"] += 1
-		}
-
-		// Display
-		if r.Display != nil {
-			if r.Display.DynamicRangeHigh {
-				dynamicRangeHigh += 1
-			}
-			if r.Display.ColorGamutRec2020 {
-				colorRec2020 += 1
-			}
-			if r.Display.ColorGamutP3 {
-				colorP3 += 1
-			}
-		}
-
-		// WebGPU format list (may be derived from WebGL when WebGPU is blocked/unavailable).
-		if r.WebGPU.Available {
-			webgpuAvailable += 1
-		}
-		if len(r.WebGPU.Formats) > 0 {
-			webgpuTested += 1
-			for _, f := range r.WebGPU.Formats {
-				stat := formatMap[f.Format]
-				if stat == nil {
-					stat = &FormatStat{
-						Format:     f.Format,
-						Kind:       f.Kind,
-						HDR:        f.HDR,
-						Compressed: f.Compressed,
-					}
-					formatMap[f.Format] = stat
-				} else {
-					// Merge static-ish format metadata across reports. Some formats (e.g. ASTC HDR profile)
-					// can vary across devices, so treat flags as "any report says yes".
-					if stat.Kind == "" && f.Kind != "" {
-						stat.Kind = f.Kind
-					}
-					if !stat.HDR && f.HDR {
-						stat.HDR = true
-					}
-					if !stat.Compressed && f.Compressed {
-						stat.Compressed = true
-					}
-				}
-				stat.Tested += 1
-				if f.HDR {
-					stat.HDRCount += 1
-				}
-				if f.Sampled {
-					stat.Sampled += 1
-				}
-				if f.Filterable != nil && *f.Filterable {
-					stat.Filterable += 1
-				}
-				if f.Renderable {
-					stat.Renderable += 1
-				}
-				if f.Storage {
-					stat.Storage += 1
-				}
-				if f.Sampled || f.Renderable || f.Storage {
-					stat.Any += 1
-				}
-			}
-		}
-
-		if r.WebGL2.Available {
-			webgl2Available += 1
-			for k, v := range r.WebGL2.Extensions {
-				if v {
-					webgl2ExtCounts[k] += 1
-				}
-			}
-			for _, cf := range r.WebGL2.CompressedFormats {
-				if name := normalizeWebGLCompressedFormat(cf); name != "" {
-					webgl2CompressedCounts[name] += 1
-				}
-			}
-
-			if r.WebGL2.Limits != nil {
-				webgl2Limits.add(r.WebGL2.Limits)
-			}
-			if r.WebGL2.TextureTests != nil {
-				if r.WebGL2.TextureTests.FloatTexture {
-					webgl2Tests.FloatTexture += 1
-				}
-				if r.WebGL2.TextureTests.HalfFloatTexture {
-					webgl2Tests.HalfFloatTexture += 1
-				}
-				if r.WebGL2.TextureTests.FloatRenderable {
-					webgl2Tests.FloatRenderable += 1
-				}
-				if r.WebGL2.TextureTests.HalfFloatRenderable {
-					webgl2Tests.HalfFloatRenderable += 1
-				}
-			}
-			if r.WebGL2.Anisotropy != nil && r.WebGL2.Anisotropy.Supported {
-				webgl2AnisoSupported += 1
-				if r.WebGL2.Anisotropy.Max != nil {
-					webgl2AnisoCounts[fmt.Sprintf("%d", int(*r.WebGL2.Anisotropy.Max+0.5))] += 1
-				}
-			}
-		}
-		if r.WebGL1.Available {
-			webgl1Available += 1
+// webglCompressedFormatAliases maps the raw PVRTC enum values some browsers
+// report (lower-cased) onto their extension constant names.
+var webglCompressedFormatAliases = map[string]string{
+	"0x8c00": "COMPRESSED_RGB_PVRTC_4BPPV1_IMG",
+	"0x8c01": "COMPRESSED_RGB_PVRTC_2BPPV1_IMG",
+	"0x8c02": "COMPRESSED_RGBA_PVRTC_4BPPV1_IMG",
+	"0x8c03": "COMPRESSED_RGBA_PVRTC_2BPPV1_IMG",
+}
 
-			for k, v := range r.WebGL1.Extensions {
-				if v {
-					webgl1ExtCounts[k] += 1
-				}
-			}
-			for _, cf := range r.WebGL1.CompressedFormats {
-				if name := normalizeWebGLCompressedFormat(cf); name != "" {
-					webgl1CompressedCounts[name] += 1
-				}
-			}
-			if r.WebGL1.Limits != nil {
-				webgl1Limits.add(r.WebGL1.Limits)
-			}
-			if r.WebGL1.TextureTests != nil {
-				if r.WebGL1.TextureTests.FloatTexture {
-					webgl1Tests.FloatTexture += 1
-				}
-				if r.WebGL1.TextureTests.HalfFloatTexture {
-					webgl1Tests.HalfFloatTexture += 1
-				}
-				if r.WebGL1.TextureTests.FloatRenderable {
-					webgl1Tests.FloatRenderable += 1
-				}
-				if r.WebGL1.TextureTests.HalfFloatRenderable {
-					webgl1Tests.HalfFloatRenderable += 1
-				}
-			}
-			if r.WebGL1.Anisotropy != nil && r.WebGL1.Anisotropy.Supported {
-				webgl1AnisoSupported += 1
-				if r.WebGL1.Anisotropy.Max != nil {
-					webgl1AnisoCounts[fmt.Sprintf("%d", int(*r.WebGL1.Anisotropy.Max+0.5))] += 1
-				}
-			}
-		}
+// normalizeWebGLCompressedFormat trims s and resolves
+// webglCompressedFormatAliases.
+func normalizeWebGLCompressedFormat(s string) string {
+	s = strings.TrimSpace(s)
+	if name, ok := webglCompressedFormatAliases[strings.ToLower(s)]; ok {
+		return name
 	}
+	return s
+}
 
-	return StatsResponse{
-		GeneratedAt: now,
-		UptimeSec:   int64(now.Sub(startedAt).Seconds()),
-		Totals:      totals,
-		Selection: Selection{
-			Matched: matched,
-			Filter:  filter,
-		},
-		Breakdown: Breakdown{
-			Browsers:    sortCounts(browserCounts),
-			OS:          sortCounts(osCounts),
-			Countries:   sortCounts(countryCounts),
-			DeviceTypes: sortCounts(deviceCounts),
-			CPUArch:     sortCounts(cpuCounts),
-		},
-		WebGPU: WebGPUStats{
-			AvailableCount: webgpuAvailable,
-			TestedCount:    webgpuTested,
-			Formats:        sortFormatStats(formatMap),
-		},
-		WebGL: WebGLStats{
-			WebGL2: WebGLContextStats{
-				AvailableCount:    webgl2Available,
-				Extensions:        sortCounts(webgl2ExtCounts),
-				CompressedFormats: sortCounts(webgl2CompressedCounts),
-				Limits:            webgl2Limits.stats(webgl2Available),
-				TextureTests:      webgl2Tests,
-				Anisotropy: WebGLAnisotropyStats{
-					Supported: webgl2AnisoSupported,
-					Max:       sortCounts(webgl2AnisoCounts),
-				},
-			},
-			WebGL1: WebGLContextStats{
-				AvailableCount:    webgl1Available,
-				Extensions:        sortCounts(webgl1ExtCounts),
-				CompressedFormats: sortCounts(webgl1CompressedCounts),
-				Limits:            webgl1Limits.stats(webgl1Available),
-				TextureTests:      webgl1Tests,
-				Anisotropy: WebGLAnisotropyStats{
-					Supported: webgl1AnisoSupported,
-					Max:       sortCounts(webgl1AnisoCounts),
-				},
-			},
-		},
-		Display: DisplayStats{
-			DynamicRangeHigh:  dynamicRangeHigh,
-			ColorGamutRec2020: colorRec2020,
-			ColorGamutP3:      colorP3,
-		},
+func computeStats(now time.Time, startedAt time.Time, totals Totals, reports []Report, filter StatsFilter) StatsResponse {
+	acc := newStatsAccumulator()
+	for _, r := range reports {
+		if !matchesStatsFilter(r, filter) {
+			continue
+		}
+		acc.add(r, 1)
 	}
+	return acc.response(now, startedAt, totals, filter)
 }
 
 func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []Report, filter StatsFilter, opts CompatOptions) CompatResponse {
 	groupBy := strings.TrimSpace(strings.ToLower(opts.GroupBy))
 	switch groupBy {
-	case "device", "os_browser", "os", "browser", "device_type":
+	case "device", "os_browser", "os", "browser", "device_type", "gpu_vendor", "gpu_architecture":
 		// ok
 	default:
 		groupBy = "device"
@@ -1319,16 +1134,20 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 	countryCounts := map[string]int{}
 	deviceCounts := map[string]int{}
 	cpuCounts := map[string]int{}
+	gpuVendorCounts := map[string]int{}
+	gpuArchCounts := map[string]int{}
 
 	type groupState struct {
 		Col  CompatColumn
 		Rows map[string]*CompatCell
 	}
 
-	buildGroupKey := func(r Report) (key string, deviceType string, osName string, browser string, hasUnknown bool) {
+	buildGroupKey := func(r Report) (key string, deviceType string, osName string, browser string, gpuVendor string, gpuArch string, hasUnknown bool) {
 		browser = "Unknown"
 		osName = "Unknown"
 		deviceType = "Unknown"
+		gpuVendor = "Unknown"
+		gpuArch = "Unknown"
 		if r.Client != nil && r.Client.Parsed != nil {
 			if r.Client.Parsed.Browser != nil && r.Client.Parsed.Browser.Name != "" {
 				browser = r.Client.Parsed.Browser.Name
@@ -1340,6 +1159,12 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 				deviceType = r.Client.Parsed.Device.Type
 			}
 		}
+		if v := reportGPUVendor(r); v != "" {
+			gpuVendor = v
+		}
+		if v := reportGPUArchitecture(r); v != "" {
+			gpuArch = v
+		}
 
 		switch groupBy {
 		case "os_browser":
@@ -1354,20 +1179,27 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 		case "device_type":
 			key = deviceType
 			hasUnknown = deviceType == "Unknown"
+		case "gpu_vendor":
+			key = gpuVendor
+			hasUnknown = gpuVendor == "Unknown"
+		case "gpu_architecture":
+			key = gpuVendor + "|" + gpuArch
+			hasUnknown = gpuVendor == "Unknown" || gpuArch == "Unknown"
 		case "device":
 			fallthrough
 		default:
 			key = deviceType + "|" + osName + "|" + browser
 			hasUnknown = deviceType == "Unknown" || osName == "Unknown" || browser == "Unknown"
 		}
-		return key, deviceType, osName, browser, hasUnknown
+		return key, deviceType, osName, browser, gpuVendor, gpuArch, hasUnknown
 	}
 
-	rowDefs := []struct {
+	type rowDef struct {
 		ID          string
 		Label       string
 		Description string
-	}{
+	}
+	rowDefs := []rowDef{
 		{ID: "astc", Label: "ASTC", Description: "ASTC block-compressed textures (common on mobile)."},
 		{ID: "astcHdr", Label: "ASTC HDR Profile", Description: "ASTC HDR decoding profile allowed (conditional on ASTC support)."},
 		{ID: "etc2", Label: "ETC2/EAC", Description: "ETC2/EAC block compression (common on Android/WebGL2)."},
@@ -1378,6 +1210,9 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 		{ID: "bc6h", Label: "BC6H (HDR)", Description: "BC6H HDR compression (BPTC)."},
 		{ID: "bc7", Label: "BC7 (BPTC)", Description: "BC7 high-quality compression (BPTC)."},
 	}
+	for _, c := range hdrVideoCodecs {
+		rowDefs = append(rowDefs, rowDef{ID: c.RowID, Label: c.Label, Description: c.Description})
+	}
 
 	groups := map[string]*groupState{}
 	overall := map[string]*CompatCell{}
@@ -1388,7 +1223,7 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 			continue
 		}
 
-		key, deviceType, osName, browser, hasUnknown := buildGroupKey(r)
+		key, deviceType, osName, browser, gpuVendor, gpuArch, hasUnknown := buildGroupKey(r)
 		if opts.ExcludeUnknown && hasUnknown {
 			continue
 		}
@@ -1422,6 +1257,8 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 		} else {
 			cpuCounts["Unknown"] += 1
 		}
+		gpuVendorCounts[gpuVendor] += 1
+		gpuArchCounts[gpuArch] += 1
 
 		// Geo
 		if r.Geo != nil && r.Geo.CountryCode != "" {
@@ -1443,6 +1280,11 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 				col.Browser = browser
 			case "device_type":
 				col.DeviceType = deviceType
+			case "gpu_vendor":
+				col.GPUVendor = gpuVendor
+			case "gpu_architecture":
+				col.GPUVendor = gpuVendor
+				col.GPUArchitecture = gpuArch
 			case "device":
 				fallthrough
 			default:
@@ -1566,6 +1408,13 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 		inc("rgtc", rgtcTested, rgtcSupported)
 		inc("bc6h", bc6hTested, bc6hSupported)
 		inc("bc7", bc7Tested, bc7Supported)
+
+		// HDR video decode rows ignore the texture usage metric.
+		for _, c := range hdrVideoCodecs {
+			if res, ok := reportHDRVideoCodec(r, c.Key); ok {
+				inc(c.RowID, true, res.Supported)
+			}
+		}
 	}
 
 	columns := make([]CompatColumn, 0, len(groups))
@@ -1619,13 +1468,15 @@ func computeCompat(now time.Time, startedAt time.Time, totals Totals, reports []
 			Matched: matched,
 			Filter:  filter,
 		},
-		Breakdown: Breakdown{
-			Browsers:    sortCounts(browserCounts),
-			OS:          sortCounts(osCounts),
-			Countries:   sortCounts(countryCounts),
-			DeviceTypes: sortCounts(deviceCounts),
-			CPUArch:     sortCounts(cpuCounts),
-		},
+		Breakdown: withGeoRollups(Breakdown{
+			Browsers:         sortCounts(browserCounts),
+			OS:               sortCounts(osCounts),
+			Countries:        sortCounts(countryCounts),
+			DeviceTypes:      sortCounts(deviceCounts),
+			CPUArch:          sortCounts(cpuCounts),
+			GPUVendors:       sortCounts(gpuVendorCounts),
+			GPUArchitectures: sortCounts(gpuArchCounts),
+		}),
 		Options: CompatOptions{
 			GroupBy:        groupBy,
 			Usage:          usage,
@@ -1662,21 +1513,21 @@ func newWebGLLimitsCounter() webglLimitsCounter {
 	}
 }
 
-func (c *webglLimitsCounter) add(l *WebGLLimits) {
+func (c *webglLimitsCounter) add(l *WebGLLimits, delta int) {
 	if l == nil {
 		return
 	}
-	incInt(c.maxTextureSize, l.MaxTextureSize)
-	incInt(c.maxCubeMapTextureSize, l.MaxCubeMapTextureSize)
-	incInt(c.maxRenderbufferSize, l.MaxRenderbufferSize)
-	incInt(c.maxTextureImageUnits, l.MaxTextureImageUnits)
-	incInt(c.maxVertexTextureImageUnits, l.MaxVertexTextureImageUnits)
-	incInt(c.maxCombinedTextureImageUnits, l.MaxCombinedTextureImageUnits)
+	incInt(c.maxTextureSize, l.MaxTextureSize, delta)
+	incInt(c.maxCubeMapTextureSize, l.MaxCubeMapTextureSize, delta)
+	incInt(c.maxRenderbufferSize, l.MaxRenderbufferSize, delta)
+	incInt(c.maxTextureImageUnits, l.MaxTextureImageUnits, delta)
+	incInt(c.maxVertexTextureImageUnits, l.MaxVertexTextureImageUnits, delta)
+	incInt(c.maxCombinedTextureImageUnits, l.MaxCombinedTextureImageUnits, delta)
 	if l.Max3DTextureSize != nil {
-		incInt(c.max3DTextureSize, *l.Max3DTextureSize)
+		incInt(c.max3DTextureSize, *l.Max3DTextureSize, delta)
 	}
 	if l.MaxArrayTextureLayers != nil {
-		incInt(c.maxArrayTextureLayers, *l.MaxArrayTextureLayers)
+		incInt(c.maxArrayTextureLayers, *l.MaxArrayTextureLayers, delta)
 	}
 }
 
@@ -1694,11 +1545,21 @@ func (c *webglLimitsCounter) stats(availableCount int) WebGLLimitsStats {
 	}
 }
 
-func incInt(m map[string]int, v int) {
+func incInt(m map[string]int, v int, delta int) {
 	if v <= 0 {
 		return
 	}
-	m[fmt.Sprintf("%d", v)] += 1
+	addCount(m, fmt.Sprintf("%d", v), delta)
+}
+
+// addCount adjusts a breakdown counter and drops keys that fall back to zero,
+// so counters maintained incrementally render like freshly computed ones.
+func addCount(m map[string]int, key string, delta int) {
+	if n := m[key] + delta; n != 0 {
+		m[key] = n
+	} else {
+		delete(m, key)
+	}
 }
 
 func sumCounts(m map[string]int) int {
@@ -1710,6 +1571,12 @@ func sumCounts(m map[string]int) int {
 }
 
 func matchesStatsFilter(r Report, f StatsFilter) bool {
+	if f.MinSchemaVersion > 1 && reportSchemaVersion(r) < f.MinSchemaVersion {
+		return false
+	}
+	if f.ExcludeDatacenter && r.Geo != nil && r.Geo.Datacenter {
+		return false
+	}
 	if f.Browser != "" {
 		if r.Client == nil || r.Client.Parsed == nil || r.Client.Parsed.Browser == nil || !strings.EqualFold(r.Client.Parsed.Browser.Name, f.Browser) {
 			return false
@@ -1720,8 +1587,8 @@ func matchesStatsFilter(r Report, f StatsFilter) bool {
 			return false
 		}
 	}
-	if f.Country != "" {
-		if r.Geo == nil || !strings.EqualFold(r.Geo.CountryCode, f.Country) {
+	if countries, ok := f.countrySet(); ok {
+		if r.Geo == nil || !countries[strings.ToUpper(r.Geo.CountryCode)] {
 			return false
 		}
 	}
@@ -1735,6 +1602,16 @@ func matchesStatsFilter(r Report, f StatsFilter) bool {
 			return false
 		}
 	}
+	if f.GPUVendor != "" {
+		if !strings.EqualFold(reportGPUVendor(r), f.GPUVendor) {
+			return false
+		}
+	}
+	if f.GPUArchitecture != "" {
+		if !strings.EqualFold(reportGPUArchitecture(r), f.GPUArchitecture) {
+			return false
+		}
+	}
 	if f.AppleSilicon != nil {
 		v := reportIsAppleSilicon(r)
 		if v == nil || *v != *f.AppleSilicon {
@@ -1772,6 +1649,19 @@ func matchesStatsFilter(r Report, f StatsFilter) bool {
 			}
 		}
 	}
+	if !reportMeetsWebGPUMinLimits(r, f.WebGPUMinLimits) {
+		return false
+	}
+	if len(f.HDRVideoCodec) > 0 {
+		for _, codec := range f.HDRVideoCodec {
+			if codec == "" {
+				continue
+			}
+			if res, ok := reportHDRVideoCodec(r, codec); !ok || !res.Supported {
+				return false
+			}
+		}
+	}
 	if len(f.WebGL2Ext) > 0 {
 		for _, ext := range f.WebGL2Ext {
 			if ext == "" {
@@ -1849,6 +1739,90 @@ func reportCPUArch(r Report) string {
 	return ""
 }
 
+// reportGPUVendor prefers the WebGPU adapter vendor and falls back to the parsed
+// renderer string, then the WebGL unmasked vendor string. Values are
+// lower-cased to match GPUAdapterInfo and clamped like the gpuVendor meta
+// field, so in-process and MongoDB stats group by the same key.
+func reportGPUVendor(r Report) string {
+	if r.WebGPU.AdapterInfo != nil {
+		if v := strings.ToLower(strings.TrimSpace(r.WebGPU.AdapterInfo.Vendor)); v != "" {
+			return clampString(v, 64)
+		}
+	}
+	if v := reportGPURenderer(r).Vendor; v != "" {
+		return clampString(v, 64)
+	}
+	for _, gl := range []WebGLReport{r.WebGL2, r.WebGL1} {
+		if gl.DebugInfo == nil {
+			continue
+		}
+		if v := normalizeWebGLVendor(gl.DebugInfo.UnmaskedVendor); v != "" {
+			return clampString(v, 64)
+		}
+	}
+	return ""
+}
+
+// reportGPURenderer parses the first available renderer string: WebGL2, then
+// WebGL1 UNMASKED_RENDERER_WEBGL, then the WebGPU adapter description.
+func reportGPURenderer(r Report) GPURendererInfo {
+	for _, gl := range []WebGLReport{r.WebGL2, r.WebGL1} {
+		if gl.DebugInfo == nil || strings.TrimSpace(gl.DebugInfo.UnmaskedRenderer) == "" {
+			continue
+		}
+		if info := parseGPURenderer(gl.DebugInfo.UnmaskedRenderer); info.Vendor != "" || info.Family != "" {
+			return info
+		}
+	}
+	if r.WebGPU.AdapterInfo != nil && r.WebGPU.AdapterInfo.Description != "" {
+		return parseGPURenderer(r.WebGPU.AdapterInfo.Description)
+	}
+	return GPURendererInfo{}
+}
+
+func reportGPUArchitecture(r Report) string {
+	if r.WebGPU.AdapterInfo == nil {
+		return ""
+	}
+	return clampString(strings.ToLower(r.WebGPU.AdapterInfo.Architecture), 64)
+}
+
+// normalizeWebGLVendor maps UNMASKED_VENDOR_WEBGL values such as
+// "Google Inc. (NVIDIA)" or "Intel Inc." onto GPUAdapterInfo-style vendor ids.
+func normalizeWebGLVendor(raw string) string {
+	s := strings.TrimSpace(raw)
+	if open := strings.LastIndex(s, "("); open >= 0 && strings.HasSuffix(s, ")") {
+		// ANGLE wraps the real vendor: "Google Inc. (Apple)".
+		s = s[open+1 : len(s)-1]
+	}
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "":
+		return ""
+	case strings.Contains(s, "nvidia"):
+		return "nvidia"
+	case strings.Contains(s, "intel"):
+		return "intel"
+	case strings.Contains(s, "amd"), strings.Contains(s, "ati technologies"):
+		return "amd"
+	case strings.Contains(s, "apple"):
+		return "apple"
+	case strings.Contains(s, "qualcomm"):
+		return "qualcomm"
+	case s == "arm" || strings.HasPrefix(s, "arm "):
+		return "arm"
+	case strings.Contains(s, "imagination"):
+		return "imagination"
+	case strings.Contains(s, "microsoft"):
+		return "microsoft"
+	case s == "unknown", strings.Contains(s, "mozilla"), strings.Contains(s, "webkit"):
+		// Masked vendor strings tell us nothing about the GPU.
+		return ""
+	default:
+		return s
+	}
+}
+
 func reportIsAppleSilicon(r Report) *bool {
 	if r.Client == nil {
 		return nil
@@ -1866,9 +1840,13 @@ func parseStatsFilter(q url.Values) StatsFilter {
 	f := StatsFilter{
 		Browser:         strings.TrimSpace(q.Get("browser")),
 		OS:              strings.TrimSpace(q.Get("os")),
-		Country:         strings.TrimSpace(q.Get("country")),
+		Country:         splitCountryParams(q["country"]),
+		Region:          splitCSVParams(q["region"]),
+		Continent:       splitCSVParams(q["continent"]),
 		DeviceType:      strings.TrimSpace(q.Get("deviceType")),
 		CPUArch:         strings.TrimSpace(q.Get("cpuArch")),
+		GPUVendor:       strings.TrimSpace(q.Get("gpuVendor")),
+		GPUArchitecture: strings.TrimSpace(q.Get("gpuArchitecture")),
 		AppleSilicon:    parseBoolPtr(q.Get("appleSilicon")),
 		WebGPUAvailable: parseBoolPtr(q.Get("webgpuAvailable")),
 		WebGL2Available: parseBoolPtr(q.Get("webgl2Available")),
@@ -1877,6 +1855,14 @@ func parseStatsFilter(q url.Values) StatsFilter {
 		WebGPUFeature:   splitCSVParams(q["webgpuFeature"]),
 		WebGL2Ext:       splitCSVParams(q["webgl2Ext"]),
 		WebGL1Ext:       splitCSVParams(q["webgl1Ext"]),
+		HDRVideoCodec:   splitCSVParams(q["hdrVideoCodec"]),
+		WebGPUMinLimits: parseWebGPUMinLimits(q["webgpuMinLimit"]),
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(q.Get("minSchemaVersion"))); err == nil && v > 1 {
+		f.MinSchemaVersion = v
+	}
+	if v := parseBoolPtr(q.Get("excludeDatacenter")); v != nil && *v {
+		f.ExcludeDatacenter = true
 	}
 	return f
 }
@@ -1959,6 +1945,19 @@ func splitCSVParams(values []string) []string {
 	return out
 }
 
+// splitCountryParams is splitCSVParams for ISO country codes; invalid codes
+// are dropped.
+func splitCountryParams(values []string) []string {
+	var out []string
+	for _, v := range splitCSVParams(values) {
+		if code := normalizeCountryCode(v); code != "" && !slices.Contains(out, code) {
+			out = append(out, code)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 func sortCounts(m map[string]int) []CountItem {
 	items := make([]CountItem, 0, len(m))
 	for k, v := range m {
@@ -1988,6 +1987,11 @@ func isRender() bool {
 	return strings.EqualFold(strings.TrimSpace(os.Getenv("RENDER")), "true")
 }
 
+func envBool(key string) bool {
+	v := parseBoolPtr(os.Getenv(key))
+	return v != nil && *v
+}
+
 func defaultListenAddr() string {
 	if p := strings.TrimSpace(os.Getenv("PORT")); p != "" {
 		if _, err := strconv.Atoi(p); err == nil {
@@ -2004,37 +2008,113 @@ func defaultListenAddr() string {
 func main() {
 	loadDotEnvNoOverwrite(".env")
 
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "export":
+			run = runExportCommand
+		case "import":
+			run = runImportCommand
+		case "migrate":
+			run = runMigrateCommand
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	addr := flag.String("addr", defaultListenAddr(), "listen address")
 	mongoURI := flag.String("mongo-uri", strings.TrimSpace(firstEnv("MONGO_URI", "MONGODB_URI")), "MongoDB connection string (env MONGO_URI/MONGODB_URI)")
+	storeSpec := flag.String("store", strings.TrimSpace(os.Getenv("STORE")), "report store: memory, mongo or sqlite:<path> (env STORE; default mongo when MONGO_URI is set, else memory)")
 	maxReports := flag.Int("max-reports", 2000, "max unique reports stored (memory or MongoDB)")
 	dedupeTTL := flag.Duration("dedupe-ttl", 24*time.Hour, "duplicate window (by fingerprint)")
 	ratePerMin := flag.Float64("rate-per-minute", 30, "rate limit for POST /api/report per IP (per minute)")
 	burst := flag.Float64("rate-burst", 60, "rate limit burst size per IP")
+	maxRawBytes := flag.Int("max-raw-bytes", 256<<10, "max compressed size of the raw report JSON kept per fingerprint (0 disables)")
+	cacheTTL := flag.Duration("cache-ttl", 30*time.Second, "max age of cached /api/stats and /api/compat responses; submissions invalidate them earlier (0 disables)")
+	statsCheckEvery := flag.Duration("stats-check-every", time.Hour, "how often to verify the incremental stats counters against a full recompute (0 disables)")
+	batchMaxBytes := flag.Int64("batch-max-bytes", 16<<20, "max body size of POST /api/reports/batch")
+	adminToken := flag.String("admin-token", strings.TrimSpace(os.Getenv("ADMIN_TOKEN")), "bearer token for POST /api/import (env ADMIN_TOKEN; empty disables the endpoint)")
+	geoipDB := flag.String("geoip-db", strings.TrimSpace(os.Getenv("GEOIP_DB")), "MaxMind/DB-IP country .mmdb for offline geo lookups (env GEOIP_DB)")
+	geoipReloadEvery := flag.Duration("geoip-reload-every", time.Minute, "how often to check -geoip-db and -asn-db for a new version (0 disables)")
+	geoChain := flag.String("geo-chain", strings.TrimSpace(os.Getenv("GEO_CHAIN")), "country resolvers in order, e.g. \"headers,mmdb,csv:ranges.csv,http;ttl=24h;negative-ttl=1h\" (env GEO_CHAIN; default headers, then mmdb/http per -geoip-db/-geoip-http)")
+	geoipHTTP := flag.Bool("geoip-http", envBool("GEOIP_HTTP"), "fall back to api.country.is for addresses the local database can't place; sends client IPs to a third party (env GEOIP_HTTP)")
+	asnDB := flag.String("asn-db", strings.TrimSpace(os.Getenv("ASN_DB")), "MaxMind/DB-IP ASN .mmdb used to tag submissions with their network and flag datacenter traffic (env ASN_DB)")
+	datacenterASNs := flag.String("datacenter-asns", strings.TrimSpace(os.Getenv("DATACENTER_ASNS")), "extra comma-separated AS numbers to treat as datacenter traffic (env DATACENTER_ASNS)")
+	trustedProxiesSpec := flag.String("trusted-proxies", strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")), "proxies whose forwarding headers are trusted: comma-separated cidr[=Header], e.g. \"10.0.0.0/8,173.245.48.0/20=CF-Connecting-IP\", or none (env TRUSTED_PROXIES; default private ranges, plus any peer on Render)")
+	statsPushdown := flag.Bool("stats-pushdown", true, "compute /api/stats with MongoDB aggregation pipelines (false uses the in-process reference path)")
 	flag.Parse()
 
+	extraASNs, err := parseASNList(*datacenterASNs)
+	if err != nil {
+		log.Fatalf("-datacenter-asns: %v", err)
+	}
+	proxies, err := parseProxyTrust(*trustedProxiesSpec, isRender())
+	if err != nil {
+		log.Fatal(err)
+	}
+	trustedProxies = proxies
+	log.Printf("Trusted proxies: %s", proxies.describe())
+
 	cfg := Config{
 		MaxReports:     *maxReports,
 		DedupeTTL:      *dedupeTTL,
 		MaxBodyBytes:   2 << 20, // 2 MiB
+		MaxRawBytes:    *maxRawBytes,
 		RatePerMinute:  *ratePerMin,
 		RateBurst:      *burst,
 		CleanupEvery:   30 * time.Second,
 		LimiterIdleTTL: 30 * time.Minute,
+		StatsPushdown:  *statsPushdown,
+		CacheTTL:       *cacheTTL,
+		AdminToken:     *adminToken,
+		ImportMaxBytes: 512 << 20, // 512 MiB
+		BatchMaxBytes:  *batchMaxBytes,
+
+		GeoIPDB:          strings.TrimSpace(*geoipDB),
+		GeoIPReloadEvery: *geoipReloadEvery,
+		GeoIPHTTP:        *geoipHTTP,
+		GeoChain:         strings.TrimSpace(*geoChain),
+		ASNDB:            strings.TrimSpace(*asnDB),
+		DatacenterASNs:   extraASNs,
 	}
 
-	if isRender() && strings.TrimSpace(mongoURIFromEnv()) == "" && strings.TrimSpace(*mongoURI) == "" {
+	if isRender() && strings.TrimSpace(*storeSpec) == "" && strings.TrimSpace(mongoURIFromEnv()) == "" && strings.TrimSpace(*mongoURI) == "" {
 		log.Fatal("MONGO_URI is required on Render (set it from your MongoDB Atlas connection string).")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	mongo, err := openAndInitMongo(ctx, *mongoURI)
+	reports, err := openReportStore(ctx, *storeSpec, *mongoURI, cfg)
 	if err != nil {
-		log.Fatalf("Mongo init failed: %v", err)
+		log.Fatalf("Store init failed: %v", err)
 	}
 
-	store := NewStore(cfg, mongo)
+	store := NewStore(cfg, reports)
+	geo, err := buildCountryResolver(cfg)
+	if err != nil {
+		log.Fatalf("Geo init failed: %v", err)
+	}
+	log.Printf("Geo: %s", describeCountryResolver(geo))
+	store.geo = geo
+	if cfg.ASNDB != "" {
+		asn, err := openASNDB(cfg.ASNDB, cfg.DatacenterASNs)
+		if err != nil {
+			log.Fatalf("ASN init failed: %v", err)
+		}
+		if cfg.GeoIPReloadEvery > 0 {
+			go asn.reloadEvery(cfg.GeoIPReloadEvery)
+		}
+		log.Printf("ASN: %s [%s]", asn.path, asn.description())
+		store.asn = asn
+	}
+	if *statsCheckEvery > 0 {
+		go store.checkStatsCountersEvery(*statsCheckEvery)
+	}
 	mux := http.NewServeMux()
 
 	mux.Handle("/healthz", healthHandler(store))
@@ -2060,7 +2140,7 @@ func serverListenHint(addr string) string {
 }
 
 func staticHandler() http.Handler {
-	fsHandler := http.FileServer(http.FS(staticFS))
+	fsHandler := withCompression(http.FileServer(http.FS(staticFS)))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet && r.Method != http.MethodHead {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -2118,10 +2198,11 @@ func healthHandler(store *Store) http.Handler {
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 
+		persistent := store.reports.Name() != "memory"
 		dbOK := false
 		dbMsg := "disabled"
-		if store.mongo != nil {
-			dbOK = store.mongoPing(ctx) == nil
+		if persistent {
+			dbOK = store.reports.Ping(ctx) == nil
 			if dbOK {
 				dbMsg = "ok"
 			} else {
@@ -2134,19 +2215,25 @@ func healthHandler(store *Store) http.Handler {
 		store.mu.Unlock()
 
 		status := http.StatusOK
-		if store.mongo != nil && !dbOK {
+		if persistent && !dbOK {
 			status = http.StatusServiceUnavailable
 		}
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		writeJSON(w, status, map[string]any{
-			"ok":        status == http.StatusOK,
-			"db":        dbMsg,
-			"uptimeSec": uptimeSec,
+		writeJSON(w, status, healthResponse{
+			OK:        status == http.StatusOK,
+			DB:        dbMsg,
+			UptimeSec: uptimeSec,
 		})
 	})
 }
 
+type healthResponse struct {
+	OK        bool   `json:"ok"`
+	DB        string `json:"db"`
+	UptimeSec int64  `json:"uptimeSec"`
+}
+
 func apiHandler(store *Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-store")
@@ -2159,27 +2246,134 @@ func apiHandler(store *Store) http.Handler {
 				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
 				return
 			}
+			now := time.Now()
 			filter := parseStatsFilter(r.URL.Query())
-			stats, err := store.Stats(time.Now(), filter)
+			resp, err := store.cache.get(now, responseCacheKey("stats", filter), func() (any, error) {
+				return store.Stats(now, filter)
+			})
 			if err != nil {
 				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "stats unavailable", "details": err.Error()})
 				return
 			}
-			writeJSON(w, http.StatusOK, stats)
+			writeCachedJSON(w, r, resp)
 			return
 		case "/api/compat":
 			if r.Method != http.MethodGet && r.Method != http.MethodHead {
 				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
 				return
 			}
+			now := time.Now()
 			filter := parseStatsFilter(r.URL.Query())
 			opts := parseCompatOptions(r.URL.Query())
-			compat, err := store.Compat(time.Now(), filter, opts)
+			resp, err := store.cache.get(now, responseCacheKey("compat", filter, opts), func() (any, error) {
+				return store.Compat(now, filter, opts)
+			})
 			if err != nil {
 				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "compat unavailable", "details": err.Error()})
 				return
 			}
-			writeJSON(w, http.StatusOK, compat)
+			writeCachedJSON(w, r, resp)
+			return
+		case "/api/trends":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			now := time.Now()
+			filter := parseStatsFilter(r.URL.Query())
+			opts, err := parseTrendsOptions(r.URL.Query(), now)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid trends query", "details": err.Error()})
+				return
+			}
+			trends, err := store.Trends(now, filter, opts)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "trends unavailable", "details": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, trends)
+			return
+		case "/api/reports":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			filter := parseStatsFilter(r.URL.Query())
+			cursor, limit, err := parseReportsQuery(r.URL.Query())
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid query", "details": err.Error()})
+				return
+			}
+			page, err := store.ListReports(time.Now(), filter, cursor, limit)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "reports unavailable", "details": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, page)
+			return
+		case "/api/export":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			format, err := parseExportFormat(r.URL.Query().Get("format"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid query", "details": err.Error()})
+				return
+			}
+			filter := parseStatsFilter(r.URL.Query())
+			w.Header().Set("Content-Type", exportContentType(format))
+			w.Header().Set("Content-Disposition", `attachment; filename="`+exportFileName(time.Now(), format)+`"`)
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			// The status line is committed with the first buffered chunk, so
+			// later failures can only truncate the body.
+			ew := &exportResponseWriter{w: w}
+			if n, err := store.Export(r.Context(), ew, format, filter); err != nil {
+				log.Printf("export failed after %d reports: %v", n, err)
+				if !ew.wrote {
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.Header().Del("Content-Disposition")
+					writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "export unavailable", "details": err.Error()})
+				}
+			}
+			return
+		case "/api/reports/batch":
+			if r.Method != http.MethodPost {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			handleReportBatch(w, r, store)
+			return
+		case "/api/import":
+			if r.Method != http.MethodPost {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			handleImport(w, r, store)
+			return
+		case "/api/schema/report.json":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			handleReportSchema(w, r)
+			return
+		case "/api/openapi.json":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			handleOpenAPI(w, r)
+			return
+		case "/api/validate":
+			if r.Method != http.MethodPost {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				return
+			}
+			handleValidate(w, r, store)
 			return
 		case "/api/report":
 			if r.Method != http.MethodPost {
@@ -2189,6 +2383,23 @@ func apiHandler(store *Store) http.Handler {
 			handleReport(w, r, store)
 			return
 		default:
+			if fingerprint, ok := strings.CutPrefix(r.URL.Path, "/api/reports/"); ok && fingerprint != "" {
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+					return
+				}
+				stored, found, err := store.GetReport(fingerprint)
+				if err != nil {
+					writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "report unavailable", "details": err.Error()})
+					return
+				}
+				if !found {
+					writeJSON(w, http.StatusNotFound, map[string]any{"error": "report not found"})
+					return
+				}
+				writeJSON(w, http.StatusOK, stored)
+				return
+			}
 			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
 			return
 		}
@@ -2218,52 +2429,38 @@ func handleReport(w http.ResponseWriter, r *http.Request, store *Store) {
 		return
 	}
 
-	body := http.MaxBytesReader(w, r.Body, store.cfg.MaxBodyBytes)
-	defer body.Close()
-
-	raw, err := io.ReadAll(body)
+	body, err := decodedRequestBody(w, r, store.cfg.MaxBodyBytes)
 	if err != nil {
 		store.Reject(now)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body", "details": err.Error()})
+		writeRequestBodyError(w, err)
 		return
 	}
+	defer body.Close()
 
-	var report Report
-	dec := json.NewDecoder(bytes.NewReader(raw))
-	dec.UseNumber()
-	if err := dec.Decode(&report); err != nil {
-		store.Reject(now)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json", "details": err.Error()})
-		return
-	}
-	if err := ensureEOF(dec); err != nil {
+	raw, err := io.ReadAll(body)
+	if err != nil {
 		store.Reject(now)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json", "details": err.Error()})
+		writeRequestBodyError(w, err)
 		return
 	}
-	if err := validateReport(&report); err != nil {
+
+	report, reason, err := decodeReport(raw)
+	if err != nil {
 		store.Reject(now)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid report", "details": err.Error()})
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": reason, "details": err.Error()})
 		return
 	}
 
-	countryCode := ""
-	if store.geo != nil {
-		countryCode = store.geo.CountryCode(r.Context(), now, ip, r)
-	}
-	if countryCode != "" {
-		report.Geo = &GeoInfo{CountryCode: countryCode}
-	} else {
-		// Never trust client-provided geo fields; keep server-derived only.
-		report.Geo = nil
-	}
+	report.Geo = store.serverGeo(r.Context(), now, ip, r)
 
 	res, err := store.SubmitRaw(now, ip, report, raw)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "failed to store report", "details": err.Error()})
 		return
 	}
-	res.CountryCode = countryCode
+	if report.Geo != nil {
+		res.CountryCode = report.Geo.CountryCode
+	}
 	status := http.StatusOK
 	if res.Status == "duplicate" {
 		status = http.StatusOK
@@ -2271,6 +2468,25 @@ func handleReport(w http.ResponseWriter, r *http.Request, store *Store) {
 	writeJSON(w, status, res)
 }
 
+// decodeReport parses and validates a single submitted report. reason is the
+// client-facing error label ("invalid json" or "invalid report").
+func decodeReport(raw []byte) (report Report, reason string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&report); err != nil {
+		return Report{}, "invalid json", err
+	}
+	if err := ensureEOF(dec); err != nil {
+		return Report{}, "invalid json", err
+	}
+	if err := validateReport(&report); err != nil {
+		return Report{}, "invalid report", err
+	}
+	report.SchemaVersion = reportSchemaVersion(report)
+	migrateReport(&report)
+	return report, "", nil
+}
+
 func ensureEOF(dec *json.Decoder) error {
 	// After a single Decode, there should be only whitespace until EOF.
 	// Attempting another decode should yield io.EOF.
@@ -2290,37 +2506,6 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = enc.Encode(v)
 }
 
-func clientIP(r *http.Request) string {
-	remoteIP := stripPortMaybe(r.RemoteAddr)
-
-	// Only trust X-Forwarded-For when we're likely behind a reverse proxy (Render or private hop).
-	// This prevents trivial spoofing when running the server directly on the public internet.
-	trustProxyHeaders := isRender() || isPrivateOrLoopbackIP(remoteIP)
-
-	if trustProxyHeaders {
-		if fwd := strings.TrimSpace(r.Header.Get("Forwarded")); fwd != "" {
-			if ip := firstClientIPFromForwarded(fwd); ip != "" {
-				return ip
-			}
-		}
-		if xff := strings.TrimSpace(r.Header.Get("X-Forwarded-For")); xff != "" {
-			if ip := firstClientIPFromXFF(xff); ip != "" {
-				return ip
-			}
-		}
-		if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); xrip != "" {
-			if ip := stripPortMaybe(xrip); ip != "" {
-				return ip
-			}
-		}
-	}
-
-	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && host != "" {
-		return host
-	}
-	return remoteIP
-}
-
 func stripPortMaybe(hostport string) string {
 	host := strings.TrimSpace(hostport)
 	if host == "" {
@@ -2332,93 +2517,6 @@ func stripPortMaybe(hostport string) string {
 	return host
 }
 
-func firstClientIPFromXFF(xff string) string {
-	parts := strings.Split(xff, ",")
-	firstValid := ""
-
-	for _, part := range parts {
-		raw := strings.TrimSpace(part)
-		if raw == "" || strings.EqualFold(raw, "unknown") {
-			continue
-		}
-		ipStr := strings.Trim(stripPortMaybe(raw), "[]")
-		if ipStr == "" {
-			continue
-		}
-		ip := net.ParseIP(ipStr)
-		if ip == nil {
-			continue
-		}
-		if firstValid == "" {
-			firstValid = ipStr
-		}
-		if isPublicIP(ip) {
-			return ipStr
-		}
-	}
-	return firstValid
-}
-
-func firstClientIPFromForwarded(forwarded string) string {
-	// RFC 7239 Forwarded header.
-	// Example:
-	//   Forwarded: for=192.0.2.60;proto=https;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
-	elements := strings.Split(forwarded, ",")
-	firstValid := ""
-
-	for _, el := range elements {
-		params := strings.Split(el, ";")
-		for _, param := range params {
-			p := strings.TrimSpace(param)
-			if len(p) < 5 {
-				continue
-			}
-			if !strings.HasPrefix(strings.ToLower(p), "for=") {
-				continue
-			}
-
-			val := strings.TrimSpace(p[4:])
-			if val == "" {
-				continue
-			}
-			if strings.HasPrefix(val, "\"") && strings.HasSuffix(val, "\"") && len(val) >= 2 {
-				val = strings.Trim(val, "\"")
-			}
-			if val == "" || strings.EqualFold(val, "unknown") || strings.HasPrefix(val, "_") {
-				// Obfuscated identifier or unknown.
-				continue
-			}
-
-			ipStr := strings.Trim(stripPortMaybe(val), "[]")
-			if ipStr == "" {
-				continue
-			}
-			ip := net.ParseIP(ipStr)
-			if ip == nil {
-				continue
-			}
-			if firstValid == "" {
-				firstValid = ipStr
-			}
-			if isPublicIP(ip) {
-				return ipStr
-			}
-		}
-	}
-	return firstValid
-}
-
-func isPrivateOrLoopbackIP(ipStr string) bool {
-	ip := net.ParseIP(strings.Trim(ipStr, "[]"))
-	if ip == nil {
-		return false
-	}
-	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
-		return true
-	}
-	return isCGNAT(ip)
-}
-
 func isPublicIP(ip net.IP) bool {
 	if ip == nil {
 		return false
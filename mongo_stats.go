@@ -0,0 +1,608 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// AggregateStats computes the /api/stats aggregates with a single $match +
+// $facet pipeline instead of loading every report into the process.
+// computeStats stays the reference implementation: every facet below mirrors
+// one of its counters over the migrated report, and anything the pipeline
+// cannot express exactly (anisotropy rounding, limit names, the format
+// catalog) is folded in Go from grouped raw values.
+//
+// The filter is applied to the indexed meta fields written by Submit. GPU
+// vendor/architecture are only available as meta fields, so reports stored
+// before those fields existed (or parsed by an older build) are grouped by the
+// stale values until `hdr-detection migrate` rewrites them.
+// Prune keeps the collection within MaxReports, so no explicit limit is applied.
+func (m *mongoStore) AggregateStats(ctx context.Context, filter StatsFilter) (StatsResponse, bool, error) {
+	match, ok := mongoStatsMatch(filter)
+	if !ok {
+		return StatsResponse{}, false, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: mongoStatsFacets()}},
+	}
+	cur, err := m.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return StatsResponse{}, false, fmt.Errorf("aggregate stats: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var rows []mongoStatsResult
+	if err := cur.All(ctx, &rows); err != nil {
+		return StatsResponse{}, false, fmt.Errorf("decode stats: %w", err)
+	}
+	if len(rows) == 0 {
+		return StatsResponse{}, false, fmt.Errorf("aggregate stats: empty result")
+	}
+	return rows[0].stats(filter), true, nil
+}
+
+// mongoStatsMatch translates a StatsFilter into a $match document. ok is false
+// when a filter value cannot be used as a field path (e.g. a WebGL extension
+// name containing '.' or '$').
+func mongoStatsMatch(f StatsFilter) (bson.D, bool) {
+	match := bson.D{}
+	and := bson.A{}
+
+	eqFold := func(field string, v string) {
+		if v != "" {
+			match = append(match, bson.E{Key: field, Value: mongoEqualFold(v)})
+		}
+	}
+	eqFold("browser", f.Browser)
+	eqFold("os", f.OS)
+	eqFold("deviceType", f.DeviceType)
+	eqFold("cpuArch", f.CPUArch)
+	eqFold("gpuVendor", f.GPUVendor)
+	eqFold("gpuArchitecture", f.GPUArchitecture)
+
+	if countries, ok := f.countrySet(); ok {
+		match = append(match, bson.E{Key: "country", Value: bson.M{"$in": slices.Sorted(maps.Keys(countries))}})
+	}
+
+	if f.MinSchemaVersion > 1 {
+		match = append(match, bson.E{Key: "schemaVersion", Value: bson.M{"$gte": f.MinSchemaVersion}})
+	}
+	if f.ExcludeDatacenter {
+		match = append(match, bson.E{Key: "datacenter", Value: bson.M{"$ne": true}})
+	}
+
+	if f.AppleSilicon != nil {
+		match = append(match, bson.E{Key: "appleSilicon", Value: *f.AppleSilicon})
+	}
+	if f.WebGPUAvailable != nil {
+		match = append(match, bson.E{Key: "webgpuAvailable", Value: *f.WebGPUAvailable})
+	}
+	if f.WebGL2Available != nil {
+		match = append(match, bson.E{Key: "webgl2Available", Value: *f.WebGL2Available})
+	}
+	if f.WebGL1Available != nil {
+		match = append(match, bson.E{Key: "webgl1Available", Value: *f.WebGL1Available})
+	}
+	if f.HDRDisplay != nil {
+		match = append(match, bson.E{Key: "hdrDisplay", Value: *f.HDRDisplay})
+	}
+
+	for _, feature := range f.WebGPUFeature {
+		if feature == "" {
+			continue
+		}
+		and = append(and, bson.M{
+			"report.webgpu.available": true,
+			"$or": bson.A{
+				bson.M{"report.webgpu.devicefeatures": feature},
+				bson.M{"report.webgpu.adapterfeatures": feature},
+			},
+		})
+	}
+	for name, bound := range f.WebGPUMinLimits {
+		// Unreported limits are stored as 0 and never match.
+		cond := bson.M{"$gte": max(bound, 1)}
+		if webgpuLimitLowerIsBetter(name) {
+			cond = bson.M{"$gte": int64(1), "$lte": bound}
+		}
+		and = append(and, bson.M{
+			"report.webgpu.available":                       true,
+			"report.webgpu.limits." + strings.ToLower(name): cond,
+		})
+	}
+	for _, codec := range f.HDRVideoCodec {
+		if codec == "" {
+			continue
+		}
+		and = append(and, bson.M{
+			"report.display.hdrvideodecoding.available": true,
+			"report.display.hdrvideodecoding.results": bson.M{"$elemMatch": bson.M{
+				"key":       mongoEqualFold(codec),
+				"supported": true,
+			}},
+		})
+	}
+	for _, gl := range []struct {
+		path string
+		exts []string
+	}{
+		{path: "report.webgl2", exts: f.WebGL2Ext},
+		{path: "report.webgl1", exts: f.WebGL1Ext},
+	} {
+		for _, ext := range gl.exts {
+			if ext == "" {
+				continue
+			}
+			if strings.ContainsAny(ext, ".$") {
+				return nil, false
+			}
+			and = append(and, bson.M{
+				gl.path + ".available":         true,
+				gl.path + ".extensions." + ext: true,
+			})
+		}
+	}
+
+	if len(and) > 0 {
+		match = append(match, bson.E{Key: "$and", Value: and})
+	}
+	return match, true
+}
+
+// mongoEqualFold matches strings.EqualFold semantics for ASCII values.
+func mongoEqualFold(v string) bson.Regex {
+	return bson.Regex{Pattern: "^" + regexp.QuoteMeta(v) + "$", Options: "i"}
+}
+
+func mongoStatsFacets() bson.D {
+	isTrue := func(path string) bson.M { return bson.M{"$eq": bson.A{path, true}} }
+	nonEmpty := func(path string) bson.M { return bson.M{"$gt": bson.A{path, ""}} }
+	and := func(conds ...any) bson.M { return bson.M{"$and": bson.A(conds)} }
+	sumIf := func(cond any) bson.M { return bson.M{"$sum": bson.M{"$cond": bson.A{cond, 1, 0}}} }
+	countBy := func(key any) bson.A {
+		return bson.A{bson.M{"$group": bson.M{"_id": key, "n": bson.M{"$sum": 1}}}}
+	}
+	orUnknown := func(path string) bson.M {
+		return bson.M{"$cond": bson.A{nonEmpty(path), path, "Unknown"}}
+	}
+
+	// Client breakdowns only count parsed values; reports without parsed client
+	// info but with a User-Agent count as "Unknown" (see computeStats).
+	hasParsed := bson.M{"$eq": bson.A{bson.M{"$type": "$report.client.parsed"}, "object"}}
+	unknownClient := and(bson.M{"$not": bson.A{hasParsed}}, nonEmpty("$report.useragent"))
+	clientKey := func(parsed any) bson.M {
+		return bson.M{"$cond": bson.A{
+			hasParsed,
+			parsed,
+			bson.M{"$cond": bson.A{unknownClient, "Unknown", ""}},
+		}}
+	}
+	cpuArch := bson.M{"$cond": bson.A{
+		nonEmpty("$report.client.cpu.architecture"),
+		"$report.client.cpu.architecture",
+		bson.M{"$ifNull": bson.A{"$report.client.platform.cpu.architecture", ""}},
+	}}
+
+	formatPath := "$report.webgpu.formats"
+	catalogFormats := make(bson.A, len(textureFormats))
+	for i, f := range textureFormats {
+		catalogFormats[i] = f.Name
+	}
+	compressedFormat := func(path string) bson.M {
+		trimmed := bson.M{"$trim": bson.M{"input": path}}
+		aliases := make(bson.A, 0, len(webglCompressedFormatAliases))
+		for _, raw := range slices.Sorted(maps.Keys(webglCompressedFormatAliases)) {
+			aliases = append(aliases, bson.M{
+				"case": bson.M{"$eq": bson.A{bson.M{"$toLower": trimmed}, raw}},
+				"then": webglCompressedFormatAliases[raw],
+			})
+		}
+		return bson.M{"$switch": bson.M{"branches": aliases, "default": trimmed}}
+	}
+
+	limitsFacet := func(path string, availablePath string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{availablePath: true, path: bson.M{"$type": "object"}}},
+			bson.M{"$project": bson.M{"l": bson.M{"$objectToArray": "$" + path}}},
+			bson.M{"$unwind": "$l"},
+			bson.M{"$match": bson.M{"l.v": bson.M{"$gt": 0}}},
+			bson.M{"$group": bson.M{"_id": bson.M{"k": "$l.k", "v": "$l.v"}, "n": bson.M{"$sum": 1}}},
+		}
+	}
+
+	// Formats outside the catalog are dropped by migrateWebGPUFormatsToCatalog.
+	catalogFormatCount := bson.M{"$size": bson.M{"$setIntersection": bson.A{
+		bson.M{"$ifNull": bson.A{formatPath + ".format", bson.A{}}},
+		catalogFormats,
+	}}}
+
+	flags := bson.M{
+		"_id":               nil,
+		"webgpuAvailable":   sumIf(isTrue("$report.webgpu.available")),
+		"webgpuTested":      sumIf(bson.M{"$gt": bson.A{catalogFormatCount, 0}}),
+		"dynamicRangeHigh":  sumIf(isTrue("$report.display.dynamicrangehigh")),
+		"colorGamutRec2020": sumIf(isTrue("$report.display.colorgamutrec2020")),
+		"colorGamutP3":      sumIf(isTrue("$report.display.colorgamutp3")),
+		"hdrVideoTested":    sumIf(isTrue("$report.display.hdrvideodecoding.available")),
+	}
+
+	facets := bson.D{
+		{Key: "matched", Value: bson.A{bson.M{"$count": "n"}}},
+		{Key: "browsers", Value: countBy(clientKey("$report.client.parsed.browser.name"))},
+		{Key: "os", Value: countBy(clientKey("$report.client.parsed.os.name"))},
+		{Key: "deviceTypes", Value: countBy(clientKey("$report.client.parsed.device.type"))},
+		{Key: "cpuArch", Value: countBy(clientKey(cpuArch))},
+		{Key: "countries", Value: countBy(orUnknown("$report.geo.countrycode"))},
+		{Key: "gpuVendors", Value: countBy(orUnknown("$gpuVendor"))},
+		{Key: "gpuArchitectures", Value: countBy(orUnknown("$gpuArchitecture"))},
+		// Formats are grouped by their raw flags; stats() runs each group
+		// through the same normalization and accumulator as computeStats.
+		{Key: "formats", Value: bson.A{
+			bson.M{"$unwind": formatPath},
+			bson.M{"$match": bson.M{"report.webgpu.formats.format": bson.M{"$in": catalogFormats}}},
+			// One entry per report and format; the first wins, as in the
+			// catalog migration.
+			bson.M{"$group": bson.M{
+				"_id":    bson.M{"doc": "$_id", "format": formatPath + ".format"},
+				"f":      bson.M{"$first": formatPath},
+				"legacy": bson.M{"$first": bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$report.schemaversion", 0}}, 3}}},
+			}},
+			bson.M{"$group": bson.M{
+				"_id": bson.M{
+					"format":     "$_id.format",
+					"legacy":     "$legacy",
+					"hdr":        isTrue("$f.hdr"),
+					"sampled":    isTrue("$f.sampled"),
+					"filterable": "$f.filterable",
+					"renderable": isTrue("$f.renderable"),
+					"storage":    isTrue("$f.storage"),
+				},
+				"n": bson.M{"$sum": 1},
+			}},
+		}},
+		{Key: "webgpuLimits", Value: limitsFacet("report.webgpu.limits", "report.webgpu.available")},
+		{Key: "hdrVideo", Value: bson.A{
+			bson.M{"$match": bson.M{"report.display.hdrvideodecoding.available": true}},
+			bson.M{"$unwind": "$report.display.hdrvideodecoding.results"},
+			// reportHDRVideoCodec uses the first result for each key.
+			bson.M{"$group": bson.M{
+				"_id": bson.M{"doc": "$_id", "key": bson.M{"$toLower": "$report.display.hdrvideodecoding.results.key"}},
+				"res": bson.M{"$first": "$report.display.hdrvideodecoding.results"},
+			}},
+			bson.M{"$group": bson.M{
+				"_id":            "$_id.key",
+				"tested":         bson.M{"$sum": 1},
+				"supported":      sumIf(isTrue("$res.supported")),
+				"smooth":         sumIf(isTrue("$res.smooth")),
+				"powerEfficient": sumIf(isTrue("$res.powerefficient")),
+			}},
+		}},
+	}
+
+	for _, gl := range []string{"webgl2", "webgl1"} {
+		path := "report." + gl
+		available := isTrue("$" + path + ".available")
+		flags[gl+"Available"] = sumIf(available)
+		for _, test := range []string{"floattexture", "halffloattexture", "floatrenderable", "halffloatrenderable"} {
+			flags[gl+"_"+test] = sumIf(and(available, isTrue("$"+path+".texturetests."+test)))
+		}
+		flags[gl+"AnisoSupported"] = sumIf(and(available, isTrue("$"+path+".anisotropy.supported")))
+
+		facets = append(facets,
+			bson.E{Key: gl + "Extensions", Value: bson.A{
+				bson.M{"$match": bson.M{path + ".available": true, path + ".extensions": bson.M{"$type": "object"}}},
+				bson.M{"$project": bson.M{"e": bson.M{"$objectToArray": "$" + path + ".extensions"}}},
+				bson.M{"$unwind": "$e"},
+				bson.M{"$match": bson.M{"e.v": true}},
+				bson.M{"$group": bson.M{"_id": "$e.k", "n": bson.M{"$sum": 1}}},
+			}},
+			// Each normalized name counts once per report.
+			bson.E{Key: gl + "Compressed", Value: bson.A{
+				bson.M{"$match": bson.M{path + ".available": true, path + ".compressedformats": bson.M{"$type": "array"}}},
+				bson.M{"$project": bson.M{"c": bson.M{"$setUnion": bson.A{bson.M{"$map": bson.M{
+					"input": "$" + path + ".compressedformats",
+					"in":    compressedFormat("$$this"),
+				}}}}}},
+				bson.M{"$unwind": "$c"},
+				bson.M{"$group": bson.M{"_id": "$c", "n": bson.M{"$sum": 1}}},
+			}},
+			bson.E{Key: gl + "Limits", Value: limitsFacet(path+".limits", path+".available")},
+			bson.E{Key: gl + "Aniso", Value: bson.A{
+				bson.M{"$match": bson.M{
+					path + ".available":            true,
+					path + ".anisotropy.supported": true,
+					path + ".anisotropy.max":       bson.M{"$type": "number"},
+				}},
+				bson.M{"$group": bson.M{"_id": "$" + path + ".anisotropy.max", "n": bson.M{"$sum": 1}}},
+			}},
+		)
+	}
+	facets = append(facets, bson.E{Key: "flags", Value: bson.A{bson.M{"$group": flags}}})
+	return facets
+}
+
+type mongoCount struct {
+	ID string `bson:"_id"`
+	N  int    `bson:"n"`
+}
+
+type mongoFloatCount struct {
+	ID float64 `bson:"_id"`
+	N  int     `bson:"n"`
+}
+
+type mongoLimitCount struct {
+	ID struct {
+		K string        `bson:"k"`
+		V bson.RawValue `bson:"v"`
+	} `bson:"_id"`
+	N int `bson:"n"`
+}
+
+type mongoFormatCount struct {
+	ID struct {
+		Format string `bson:"format"`
+		// Legacy entries predate the catalog and are normalized like
+		// migrateWebGPUFormatsToCatalog does on read.
+		Legacy     bool  `bson:"legacy"`
+		HDR        bool  `bson:"hdr"`
+		Sampled    bool  `bson:"sampled"`
+		Filterable *bool `bson:"filterable"`
+		Renderable bool  `bson:"renderable"`
+		Storage    bool  `bson:"storage"`
+	} `bson:"_id"`
+	N int `bson:"n"`
+}
+
+type mongoHDRVideoCount struct {
+	Key            string `bson:"_id"`
+	Tested         int    `bson:"tested"`
+	Supported      int    `bson:"supported"`
+	Smooth         int    `bson:"smooth"`
+	PowerEfficient int    `bson:"powerEfficient"`
+}
+
+type mongoStatsFlags struct {
+	WebGPUAvailable   int `bson:"webgpuAvailable"`
+	WebGPUTested      int `bson:"webgpuTested"`
+	DynamicRangeHigh  int `bson:"dynamicRangeHigh"`
+	ColorGamutRec2020 int `bson:"colorGamutRec2020"`
+	ColorGamutP3      int `bson:"colorGamutP3"`
+	HDRVideoTested    int `bson:"hdrVideoTested"`
+
+	WebGL2Available           int `bson:"webgl2Available"`
+	WebGL2FloatTexture        int `bson:"webgl2_floattexture"`
+	WebGL2HalfFloatTexture    int `bson:"webgl2_halffloattexture"`
+	WebGL2FloatRenderable     int `bson:"webgl2_floatrenderable"`
+	WebGL2HalfFloatRenderable int `bson:"webgl2_halffloatrenderable"`
+	WebGL2AnisoSupported      int `bson:"webgl2AnisoSupported"`
+
+	WebGL1Available           int `bson:"webgl1Available"`
+	WebGL1FloatTexture        int `bson:"webgl1_floattexture"`
+	WebGL1HalfFloatTexture    int `bson:"webgl1_halffloattexture"`
+	WebGL1FloatRenderable     int `bson:"webgl1_floatrenderable"`
+	WebGL1HalfFloatRenderable int `bson:"webgl1_halffloatrenderable"`
+	WebGL1AnisoSupported      int `bson:"webgl1AnisoSupported"`
+}
+
+type mongoStatsResult struct {
+	Matched          []struct{ N int } `bson:"matched"`
+	Browsers         []mongoCount      `bson:"browsers"`
+	OS               []mongoCount      `bson:"os"`
+	DeviceTypes      []mongoCount      `bson:"deviceTypes"`
+	CPUArch          []mongoCount      `bson:"cpuArch"`
+	Countries        []mongoCount      `bson:"countries"`
+	GPUVendors       []mongoCount      `bson:"gpuVendors"`
+	GPUArchitectures []mongoCount      `bson:"gpuArchitectures"`
+
+	Formats      []mongoFormatCount   `bson:"formats"`
+	WebGPULimits []mongoLimitCount    `bson:"webgpuLimits"`
+	HDRVideo     []mongoHDRVideoCount `bson:"hdrVideo"`
+
+	WebGL2Extensions []mongoCount      `bson:"webgl2Extensions"`
+	WebGL2Compressed []mongoCount      `bson:"webgl2Compressed"`
+	WebGL2Limits     []mongoLimitCount `bson:"webgl2Limits"`
+	WebGL2Aniso      []mongoFloatCount `bson:"webgl2Aniso"`
+	WebGL1Extensions []mongoCount      `bson:"webgl1Extensions"`
+	WebGL1Compressed []mongoCount      `bson:"webgl1Compressed"`
+	WebGL1Limits     []mongoLimitCount `bson:"webgl1Limits"`
+	WebGL1Aniso      []mongoFloatCount `bson:"webgl1Aniso"`
+
+	Flags []mongoStatsFlags `bson:"flags"`
+}
+
+// stats assembles the facet output into the same shape computeStats returns.
+// GeneratedAt, UptimeSec and Totals are filled in by Store.
+func (res mongoStatsResult) stats(filter StatsFilter) StatsResponse {
+	var flags mongoStatsFlags
+	if len(res.Flags) > 0 {
+		flags = res.Flags[0]
+	}
+	matched := 0
+	if len(res.Matched) > 0 {
+		matched = res.Matched[0].N
+	}
+
+	formats := &statsAccumulator{formats: map[string]*formatAccumulator{}}
+	for _, row := range res.Formats {
+		f := WebGPUFormat{
+			Format:     row.ID.Format,
+			HDR:        row.ID.HDR,
+			Sampled:    row.ID.Sampled,
+			Filterable: row.ID.Filterable,
+			Renderable: row.ID.Renderable,
+			Storage:    row.ID.Storage,
+		}
+		if row.ID.Legacy {
+			normalized, err := normalizeWebGPUFormats([]WebGPUFormat{f})
+			if err != nil {
+				continue
+			}
+			f = normalized[0]
+		}
+		formats.addFormat(f, row.N)
+	}
+
+	webgpuLimits := newWebGPULimitsCounter()
+	for _, row := range res.WebGPULimits {
+		name, ok := webgpuLimitNameFold(row.ID.K)
+		v, isInt := row.ID.V.AsInt64OK()
+		if !ok || !isInt || v <= 0 {
+			continue
+		}
+		webgpuLimits.counts[name][strconv.FormatInt(v, 10)] += row.N
+	}
+
+	webgl2Limits := mongoWebGLLimits(res.WebGL2Limits)
+	webgl1Limits := mongoWebGLLimits(res.WebGL1Limits)
+
+	hdrVideoStats := make([]HDRVideoCodecStat, len(hdrVideoCodecs))
+	for i, c := range hdrVideoCodecs {
+		hdrVideoStats[i].Codec = c.Key
+		for _, row := range res.HDRVideo {
+			if strings.EqualFold(row.Key, c.Key) {
+				hdrVideoStats[i].Tested += row.Tested
+				hdrVideoStats[i].Supported += row.Supported
+				hdrVideoStats[i].Smooth += row.Smooth
+				hdrVideoStats[i].PowerEfficient += row.PowerEfficient
+			}
+		}
+	}
+
+	return StatsResponse{
+		Selection: Selection{
+			Matched: matched,
+			Filter:  filter,
+		},
+		Breakdown: withGeoRollups(Breakdown{
+			Browsers:         sortCounts(mongoCountMap(res.Browsers)),
+			OS:               sortCounts(mongoCountMap(res.OS)),
+			Countries:        sortCounts(mongoCountMap(res.Countries)),
+			DeviceTypes:      sortCounts(mongoCountMap(res.DeviceTypes)),
+			CPUArch:          sortCounts(mongoCountMap(res.CPUArch)),
+			GPUVendors:       sortCounts(mongoCountMap(res.GPUVendors)),
+			GPUArchitectures: sortCounts(mongoCountMap(res.GPUArchitectures)),
+		}),
+		WebGPU: WebGPUStats{
+			AvailableCount: flags.WebGPUAvailable,
+			TestedCount:    flags.WebGPUTested,
+			Limits:         webgpuLimits.stats(),
+			Formats:        formats.formatStats(),
+		},
+		WebGL: WebGLStats{
+			WebGL2: WebGLContextStats{
+				AvailableCount:    flags.WebGL2Available,
+				Extensions:        sortCounts(mongoCountMap(res.WebGL2Extensions)),
+				CompressedFormats: sortCounts(mongoCountMap(res.WebGL2Compressed)),
+				Limits:            webgl2Limits.stats(flags.WebGL2Available),
+				TextureTests: WebGLTextureTestStats{
+					FloatTexture:        flags.WebGL2FloatTexture,
+					HalfFloatTexture:    flags.WebGL2HalfFloatTexture,
+					FloatRenderable:     flags.WebGL2FloatRenderable,
+					HalfFloatRenderable: flags.WebGL2HalfFloatRenderable,
+				},
+				Anisotropy: WebGLAnisotropyStats{
+					Supported: flags.WebGL2AnisoSupported,
+					Max:       sortCounts(mongoAnisoCounts(res.WebGL2Aniso)),
+				},
+			},
+			WebGL1: WebGLContextStats{
+				AvailableCount:    flags.WebGL1Available,
+				Extensions:        sortCounts(mongoCountMap(res.WebGL1Extensions)),
+				CompressedFormats: sortCounts(mongoCountMap(res.WebGL1Compressed)),
+				Limits:            webgl1Limits.stats(flags.WebGL1Available),
+				TextureTests: WebGLTextureTestStats{
+					FloatTexture:        flags.WebGL1FloatTexture,
+					HalfFloatTexture:    flags.WebGL1HalfFloatTexture,
+					FloatRenderable:     flags.WebGL1FloatRenderable,
+					HalfFloatRenderable: flags.WebGL1HalfFloatRenderable,
+				},
+				Anisotropy: WebGLAnisotropyStats{
+					Supported: flags.WebGL1AnisoSupported,
+					Max:       sortCounts(mongoAnisoCounts(res.WebGL1Aniso)),
+				},
+			},
+		},
+		Display: DisplayStats{
+			DynamicRangeHigh:  flags.DynamicRangeHigh,
+			ColorGamutRec2020: flags.ColorGamutRec2020,
+			ColorGamutP3:      flags.ColorGamutP3,
+			HDRVideoTested:    flags.HDRVideoTested,
+			HDRVideoCodecs:    hdrVideoStats,
+		},
+	}
+}
+
+// mongoCountMap turns grouped rows into a count map, dropping empty keys.
+func mongoCountMap(rows []mongoCount) map[string]int {
+	out := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if row.ID == "" {
+			continue
+		}
+		out[row.ID] += row.N
+	}
+	return out
+}
+
+func mongoAnisoCounts(rows []mongoFloatCount) map[string]int {
+	out := make(map[string]int, len(rows))
+	for _, row := range rows {
+		out[fmt.Sprintf("%d", int(row.ID+0.5))] += row.N
+	}
+	return out
+}
+
+func mongoWebGLLimits(rows []mongoLimitCount) webglLimitsCounter {
+	c := newWebGLLimitsCounter()
+	for _, row := range rows {
+		v, ok := row.ID.V.AsInt64OK()
+		if !ok || v <= 0 {
+			continue
+		}
+		var m map[string]int
+		switch row.ID.K {
+		case "maxtexturesize":
+			m = c.maxTextureSize
+		case "maxcubemaptexturesize":
+			m = c.maxCubeMapTextureSize
+		case "maxrenderbuffersize":
+			m = c.maxRenderbufferSize
+		case "maxtextureimageunits":
+			m = c.maxTextureImageUnits
+		case "maxvertextextureimageunits":
+			m = c.maxVertexTextureImageUnits
+		case "maxcombinedtextureimageunits":
+			m = c.maxCombinedTextureImageUnits
+		case "max3dtexturesize":
+			m = c.max3DTextureSize
+		case "maxarraytexturelayers":
+			m = c.maxArrayTextureLayers
+		default:
+			continue
+		}
+		m[strconv.FormatInt(v, 10)] += row.N
+	}
+	return c
+}
+
+// webgpuLimitNameFold maps a stored (lower-cased) field name back to its
+// GPUSupportedLimits name.
+func webgpuLimitNameFold(key string) (string, bool) {
+	for _, name := range webgpuLimitNames {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// mongoStatsFixtures cover each filtered field and aggregated section,
+// including the edge cases where the meta fields and the nested report could
+// disagree (features or extensions listed on an unavailable API, codec results
+// with decoding unavailable, datacenter submissions) and legacy reports that
+// are only upgraded on read (duplicate, aliased or unknown formats).
+func mongoStatsFixtures() []Report {
+	return []Report{
+		{
+			SchemaVersion: 3,
+			Client: &ClientInfo{
+				Parsed: &ClientParsed{
+					Browser: &NameVersion{Name: "Chrome"},
+					OS:      &NameVersion{Name: "Windows"},
+					Device:  &DeviceInfo{Type: "desktop"},
+				},
+				CPU: &ClientCPU{Architecture: "x86", IsAppleSilicon: ptr(false)},
+			},
+			Geo: &GeoInfo{CountryCode: "US", ASN: 7922, ASOrg: "Comcast"},
+			Display: &DisplayInfo{
+				HDRCapable:       true,
+				DynamicRangeHigh: true,
+				ColorGamutP3:     true,
+				HDRVideoDecoding: &HDRVideoDecoding{Available: true, Supported: true, Results: []HDRVideoCodecResult{
+					{Key: "hevc-pq", Supported: true, Smooth: true, PowerEfficient: true},
+					{Key: "vp9-pq", Supported: false},
+				}},
+			},
+			WebGPU: WebGPUReport{
+				Available:       true,
+				AdapterInfo:     &WebGPUAdapter{Vendor: "nvidia", Architecture: "ampere"},
+				AdapterFeatures: []string{"timestamp-query"},
+				DeviceFeatures:  []string{"shader-f16"},
+				Limits: &WebGPULimits{
+					MaxTextureDimension2D:           16384,
+					MaxBufferSize:                   4294967296,
+					MinUniformBufferOffsetAlignment: 256,
+					MinStorageBufferOffsetAlignment: 32,
+				},
+				Formats: []WebGPUFormat{
+					{Format: "rgba16float", Kind: "float", HDR: true, Sampled: true, Filterable: ptr(true), Renderable: true, Storage: true},
+					{Format: "astc-4x4-unorm", Kind: "compressed-astc", HDR: true, Compressed: true, Sampled: true, Filterable: ptr(true)},
+					{Format: "bc7-rgba-unorm", Kind: "compressed-bc", Compressed: true},
+				},
+			},
+			WebGL2: WebGLReport{
+				Available:         true,
+				Extensions:        map[string]bool{"EXT_color_buffer_float": true, "OES_texture_float_linear": false},
+				CompressedFormats: []string{"COMPRESSED_RGBA_S3TC_DXT5_EXT"},
+				Limits:            &WebGLLimits{MaxTextureSize: 16384, MaxRenderbufferSize: 16384, Max3DTextureSize: ptr(2048)},
+				Anisotropy:        &WebGLAnisotropy{Supported: true, Max: ptr(16.0)},
+				TextureTests:      &WebGLTextureTests{FloatTexture: true, HalfFloatRenderable: true},
+			},
+			WebGL1: WebGLReport{Available: true, Extensions: map[string]bool{"OES_texture_float": true}},
+		},
+		{
+			SchemaVersion: 2,
+			Client: &ClientInfo{
+				Parsed: &ClientParsed{
+					Browser: &NameVersion{Name: "Safari"},
+					OS:      &NameVersion{Name: "macOS"},
+					Device:  &DeviceInfo{Type: "desktop"},
+				},
+				Platform: &ClientPlatform{CPU: &ClientCPU{Architecture: "arm", IsAppleSilicon: ptr(true)}},
+			},
+			Geo:     &GeoInfo{CountryCode: "DE"},
+			Display: &DisplayInfo{DynamicRangeHigh: true},
+			WebGPU: WebGPUReport{
+				Available:      false,
+				AdapterInfo:    &WebGPUAdapter{Vendor: "apple", Architecture: "metal-3"},
+				DeviceFeatures: []string{"shader-f16"},
+				Limits:         &WebGPULimits{MaxTextureDimension2D: 16384, MinUniformBufferOffsetAlignment: 256},
+				// Only formats outside the catalog: untested once upgraded.
+				Formats: []WebGPUFormat{{Format: "rgba8unorm-bogus", Sampled: true}},
+			},
+			WebGL2: WebGLReport{
+				Available:         true,
+				Extensions:        map[string]bool{"EXT_color_buffer_float": true},
+				CompressedFormats: []string{"0x8C02", "COMPRESSED_RGBA_PVRTC_4BPPV1_IMG", " COMPRESSED_RGBA_S3TC_DXT5_EXT"},
+				Limits:            &WebGLLimits{MaxTextureSize: 8192, MaxTextureImageUnits: 16},
+				Anisotropy:        &WebGLAnisotropy{Supported: true, Max: ptr(15.7)},
+				TextureTests:      &WebGLTextureTests{HalfFloatTexture: true},
+			},
+			WebGL1: WebGLReport{Available: false, Extensions: map[string]bool{"OES_texture_float": true}, CompressedFormats: []string{"0x8c00"}},
+		},
+		{
+			Client: &ClientInfo{
+				Parsed: &ClientParsed{
+					Browser: &NameVersion{Name: "Firefox"},
+					OS:      &NameVersion{Name: "Linux"},
+				},
+			},
+			Geo: &GeoInfo{CountryCode: "FR", ASN: 16509, ASOrg: "Amazon", Datacenter: true},
+			Display: &DisplayInfo{
+				ColorGamutRec2020: true,
+				HDRVideoDecoding: &HDRVideoDecoding{Available: true, Results: []HDRVideoCodecResult{
+					{Key: "vp9-pq", Supported: true, Smooth: true},
+					{Key: "VP9-PQ", Supported: false},
+					{Key: "av1-pq", Supported: false, PowerEfficient: true},
+				}},
+			},
+			WebGPU: WebGPUReport{
+				Available:   true,
+				AdapterInfo: &WebGPUAdapter{Vendor: "google", Architecture: "swiftshader"},
+				// Stored before the catalog: stale metadata, a duplicate, an
+				// unknown format and usages the format can never have.
+				Formats: []WebGPUFormat{
+					{Format: "rgba16float", Kind: "bogus", Sampled: true, Renderable: true},
+					{Format: "rgba16float", Storage: true},
+					{Format: "not-a-format", Sampled: true},
+					{Format: "bc7-rgba-unorm", Kind: "float", Renderable: true, Storage: true, Filterable: ptr(true)},
+					{Format: "astc-4x4-unorm", HDR: true, Sampled: true},
+				},
+			},
+			WebGL2: WebGLReport{Available: true, TextureTests: &WebGLTextureTests{FloatTexture: true, FloatRenderable: true}},
+			WebGL1: WebGLReport{
+				Available:         true,
+				CompressedFormats: []string{"0x8c00", "COMPRESSED_RGB_PVRTC_4BPPV1_IMG", "", "COMPRESSED_RGB_S3TC_DXT1_EXT"},
+				Limits:            &WebGLLimits{MaxTextureSize: 4096, MaxCombinedTextureImageUnits: 32},
+				Anisotropy:        &WebGLAnisotropy{Supported: true},
+			},
+		},
+		{
+			SchemaVersion: 3,
+			Client: &ClientInfo{
+				Parsed: &ClientParsed{
+					Browser: &NameVersion{Name: "Chrome"},
+					OS:      &NameVersion{Name: "Android"},
+					Device:  &DeviceInfo{Type: "mobile"},
+				},
+				CPU: &ClientCPU{Architecture: "arm", IsAppleSilicon: ptr(false)},
+			},
+			Geo: &GeoInfo{CountryCode: "BR"},
+			Display: &DisplayInfo{
+				HDRVideoDecoding: &HDRVideoDecoding{Available: false, Results: []HDRVideoCodecResult{
+					{Key: "hevc-pq", Supported: true},
+				}},
+			},
+			WebGPU: WebGPUReport{
+				Available:       true,
+				AdapterInfo:     &WebGPUAdapter{Vendor: "qualcomm", Architecture: "adreno-7xx"},
+				AdapterFeatures: []string{"shader-f16", "texture-compression-astc"},
+				Limits: &WebGPULimits{
+					MaxTextureDimension2D:           8192,
+					MaxBufferSize:                   268435456,
+					MinUniformBufferOffsetAlignment: 64,
+					MinStorageBufferOffsetAlignment: 64,
+				},
+			},
+			WebGL2: WebGLReport{Available: true, Extensions: map[string]bool{"OES_texture_float_linear": true}},
+		},
+		{
+			SchemaVersion: 3,
+			Client: &ClientInfo{
+				Parsed: &ClientParsed{Browser: &NameVersion{Name: "Edge"}},
+			},
+			Geo: &GeoInfo{CountryCode: "JP", ASN: 24940, ASOrg: "Hetzner Online GmbH", Datacenter: true},
+			Display: &DisplayInfo{
+				HDRCapable: true,
+				HDRVideoDecoding: &HDRVideoDecoding{Available: true, Results: []HDRVideoCodecResult{
+					{Key: "HEVC-PQ", Supported: false},
+					{Key: "av1-pq", Supported: true},
+				}},
+			},
+			// A vendor longer than the gpuVendor meta field.
+			WebGPU: WebGPUReport{Available: true, AdapterInfo: &WebGPUAdapter{Vendor: strings.Repeat("v", 80)}, Limits: &WebGPULimits{}},
+			WebGL2: WebGLReport{Available: false},
+			WebGL1: WebGLReport{Available: true, Extensions: map[string]bool{"OES_texture_float": false}},
+		},
+		{UserAgent: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{},
+	}
+}
+
+// mongoStatsFilterCases exercise every StatsFilter field (checked by
+// TestMongoStatsFilterCasesCoverEveryField).
+var mongoStatsFilterCases = []StatsFilter{
+	{},
+	{Browser: "chrome"},
+	{Browser: "Safari"},
+	{OS: "WINDOWS"},
+	{OS: "android"},
+	{DeviceType: "desktop"},
+	{DeviceType: "Mobile"},
+	{CPUArch: "ARM"},
+	{CPUArch: "x86"},
+	{GPUVendor: "NVIDIA"},
+	{GPUVendor: "apple"},
+	{GPUArchitecture: "Ampere"},
+	{Country: []string{"US"}},
+	{Country: []string{"de", "fr"}},
+	{Region: []string{"EU"}},
+	{Continent: []string{"Americas"}},
+	{Region: []string{"EU"}, Country: []string{"DE", "US"}},
+	{AppleSilicon: ptr(true)},
+	{AppleSilicon: ptr(false)},
+	{WebGPUAvailable: ptr(true)},
+	{WebGPUAvailable: ptr(false)},
+	{WebGL2Available: ptr(true)},
+	{WebGL2Available: ptr(false)},
+	{WebGL1Available: ptr(true)},
+	{WebGL1Available: ptr(false)},
+	{HDRDisplay: ptr(true)},
+	{HDRDisplay: ptr(false)},
+	{WebGPUFeature: []string{"shader-f16"}},
+	{WebGPUFeature: []string{"timestamp-query", "shader-f16"}},
+	{WebGPUFeature: []string{"texture-compression-astc"}},
+	{WebGL2Ext: []string{"EXT_color_buffer_float"}},
+	{WebGL2Ext: []string{"OES_texture_float_linear"}},
+	{WebGL1Ext: []string{"OES_texture_float"}},
+	{HDRVideoCodec: []string{"hevc-pq"}},
+	{HDRVideoCodec: []string{"AV1-PQ"}},
+	{HDRVideoCodec: []string{"vp9-pq"}},
+	{WebGPUMinLimits: map[string]int64{"maxTextureDimension2D": 16384}},
+	{WebGPUMinLimits: map[string]int64{"maxTextureDimension2D": 8192, "maxBufferSize": 1 << 30}},
+	{WebGPUMinLimits: map[string]int64{"maxTextureDimension2D": 0}},
+	{WebGPUMinLimits: map[string]int64{"minUniformBufferOffsetAlignment": 64}},
+	{WebGPUMinLimits: map[string]int64{"minUniformBufferOffsetAlignment": 256, "minStorageBufferOffsetAlignment": 32}},
+	{WebGPUMinLimits: map[string]int64{"minStorageBufferOffsetAlignment": 0}},
+	{MinSchemaVersion: 2},
+	{MinSchemaVersion: 3},
+	{ExcludeDatacenter: true},
+	{ExcludeDatacenter: true, Country: []string{"FR", "US"}},
+	{Browser: "chrome", WebGPUAvailable: ptr(true), HDRDisplay: ptr(true)},
+}
+
+// TestMongoStatsMatchEquivalence checks the $match pushed down to Mongo
+// against matchesStatsFilter, evaluating it over the documents Submit would
+// insert for each fixture.
+func TestMongoStatsMatchEquivalence(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fixtures := mongoStatsFixtures()
+	docs := make([]map[string]any, len(fixtures))
+	for i, r := range fixtures {
+		raw, err := bson.Marshal(newReportDoc(now, fmt.Sprint(i), r, nil))
+		if err != nil {
+			t.Fatalf("marshal fixture %d: %v", i, err)
+		}
+		var m bson.M
+		if err := bson.Unmarshal(raw, &m); err != nil {
+			t.Fatalf("unmarshal fixture %d: %v", i, err)
+		}
+		docs[i] = normalizeBSON(m).(map[string]any)
+	}
+
+	for _, f := range mongoStatsFilterCases {
+		match, ok := mongoStatsMatch(f)
+		if !ok {
+			t.Errorf("mongoStatsMatch(%+v): not expressible", f)
+			continue
+		}
+		for i, r := range fixtures {
+			want := matchesStatsFilter(r, f)
+			if got := evalMongoMatch(docs[i], match); got != want {
+				t.Errorf("filter %+v, fixture %d: $match %v = %v, matchesStatsFilter = %v", f, i, match, got, want)
+			}
+		}
+	}
+}
+
+// TestMongoAggregateStatsMatchesComputeStats runs the full $match + $facet
+// pipeline against a live MongoDB (MONGO_TEST_URI) and compares the result
+// with computeStats over the same reports, as the store loads them.
+func TestMongoAggregateStatsMatchesComputeStats(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	t.Setenv("MONGO_COLLECTION", fmt.Sprintf("stats_test_%d", time.Now().UnixNano()))
+
+	ctx := context.Background()
+	m, err := openAndInitMongo(ctx, uri, Config{DedupeTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = m.coll.Drop(context.Background())
+		_ = m.client.Disconnect(context.Background())
+	})
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, r := range mongoStatsFixtures() {
+		if _, err := m.Submit(ctx, now.Add(time.Duration(i)*time.Second), "", fmt.Sprintf("fp-%d", i), r, nil); err != nil {
+			t.Fatalf("submit fixture %d: %v", i, err)
+		}
+	}
+	stored, err := m.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := make([]Report, len(stored))
+	for i, sr := range stored {
+		reports[i] = sr.Report
+	}
+
+	for _, f := range mongoStatsFilterCases {
+		got, ok, err := m.AggregateStats(ctx, f)
+		if err != nil {
+			t.Fatalf("AggregateStats(%+v): %v", f, err)
+		}
+		if !ok {
+			t.Errorf("AggregateStats(%+v): not expressible", f)
+			continue
+		}
+		want := computeStats(now, now, Totals{}, reports, f)
+		got.GeneratedAt, got.UptimeSec, got.Totals = want.GeneratedAt, want.UptimeSec, want.Totals
+
+		gotJSON, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantJSON, err := json.MarshalIndent(want, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotJSON, wantJSON) {
+			t.Errorf("filter %+v:\nAggregateStats: %s\ncomputeStats: %s", f, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestMongoStatsMatchRejectsFieldPaths(t *testing.T) {
+	for _, f := range []StatsFilter{
+		{WebGL2Ext: []string{"a.b"}},
+		{WebGL1Ext: []string{"$where"}},
+	} {
+		if _, ok := mongoStatsMatch(f); ok {
+			t.Errorf("mongoStatsMatch(%+v): ok, want fallback", f)
+		}
+	}
+}
+
+func TestMongoStatsFilterCasesCoverEveryField(t *testing.T) {
+	typ := reflect.TypeOf(StatsFilter{})
+	for i := 0; i < typ.NumField(); i++ {
+		covered := false
+		for _, f := range mongoStatsFilterCases {
+			if !reflect.ValueOf(f).Field(i).IsZero() {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("StatsFilter.%s is not exercised by mongoStatsFilterCases", typ.Field(i).Name)
+		}
+	}
+}
+
+// normalizeBSON turns decoded documents into plain maps and slices, with
+// every number as float64.
+func normalizeBSON(v any) any {
+	switch v := v.(type) {
+	case bson.M:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = normalizeBSON(e)
+		}
+		return out
+	case bson.D:
+		out := make(map[string]any, len(v))
+		for _, e := range v {
+			out[e.Key] = normalizeBSON(e.Value)
+		}
+		return out
+	case bson.A:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = normalizeBSON(e)
+		}
+		return out
+	case []string:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = e
+		}
+		return out
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return v
+}
+
+// evalMongoMatch is a small $match evaluator covering the operators
+// mongoStatsMatch emits. Like Mongo, a path through an array visits each
+// element, and equality against an array field matches any element.
+func evalMongoMatch(doc map[string]any, query any) bool {
+	for key, cond := range normalizeBSON(query).(map[string]any) {
+		switch key {
+		case "$and":
+			for _, sub := range cond.([]any) {
+				if !evalMongoMatch(doc, sub) {
+					return false
+				}
+			}
+		case "$or":
+			matched := false
+			for _, sub := range cond.([]any) {
+				if evalMongoMatch(doc, sub) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			if !evalMongoCond(mongoPathValues(doc, key), cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func mongoPathValues(doc map[string]any, path string) []any {
+	values := []any{doc}
+	for _, part := range splitMongoPath(path) {
+		var next []any
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]any:
+				if e, ok := v[part]; ok {
+					next = append(next, e)
+				}
+			case []any:
+				for _, el := range v {
+					if m, ok := el.(map[string]any); ok {
+						if e, ok := m[part]; ok {
+							next = append(next, e)
+						}
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func splitMongoPath(path string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] == '.' {
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, path[start:])
+}
+
+// mongoLeaves flattens one level of arrays, as Mongo does for equality and
+// comparison operators.
+func mongoLeaves(values []any) []any {
+	var out []any
+	for _, v := range values {
+		if arr, ok := v.([]any); ok {
+			out = append(out, arr...)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func evalMongoCond(values []any, cond any) bool {
+	switch c := cond.(type) {
+	case bson.Regex:
+		re := regexp.MustCompile("(?" + c.Options + ")" + c.Pattern)
+		for _, v := range mongoLeaves(values) {
+			if s, ok := v.(string); ok && re.MatchString(s) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		for op, arg := range c {
+			if !evalMongoOperator(values, op, arg) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range mongoLeaves(values) {
+		if reflect.DeepEqual(v, cond) {
+			return true
+		}
+	}
+	return false
+}
+
+func evalMongoOperator(values []any, op string, arg any) bool {
+	leaves := mongoLeaves(values)
+	switch op {
+	case "$ne":
+		return !evalMongoCond(values, arg)
+	case "$in":
+		for _, want := range arg.([]any) {
+			if evalMongoCond(values, want) {
+				return true
+			}
+		}
+		return false
+	case "$gte", "$lte":
+		for _, v := range leaves {
+			n, ok := v.(float64)
+			if !ok {
+				continue
+			}
+			if (op == "$gte" && n >= arg.(float64)) || (op == "$lte" && n <= arg.(float64)) {
+				return true
+			}
+		}
+		return false
+	case "$elemMatch":
+		for _, v := range values {
+			arr, ok := v.([]any)
+			if !ok {
+				continue
+			}
+			for _, el := range arr {
+				if m, ok := el.(map[string]any); ok && evalMongoMatch(m, arg) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	panic("evalMongoMatch: unsupported operator " + op)
+}
@@ -18,6 +18,9 @@ type mongoStore struct {
 	client *mongo.Client
 	db     *mongo.Database
 	coll   *mongo.Collection
+
+	maxReports int
+	dedupeTTL  time.Duration
 }
 
 type reportDoc struct {
@@ -29,12 +32,21 @@ type reportDoc struct {
 	DeviceType      string    `bson:"deviceType,omitempty"`
 	CPUArch         string    `bson:"cpuArch,omitempty"`
 	Country         string    `bson:"country,omitempty"`
+	ASOrg           string    `bson:"asOrg,omitempty"`
+	Datacenter      bool      `bson:"datacenter"`
+	GPUVendor       string    `bson:"gpuVendor,omitempty"`
+	GPUArchitecture string    `bson:"gpuArchitecture,omitempty"`
+	GPUFamily       string    `bson:"gpuFamily,omitempty"`
+	GPUModel        string    `bson:"gpuModel,omitempty"`
+	GPUBackend      string    `bson:"gpuBackend,omitempty"`
 	AppleSilicon    *bool     `bson:"appleSilicon,omitempty"`
 	WebGPUAvailable bool      `bson:"webgpuAvailable"`
 	WebGL2Available bool      `bson:"webgl2Available"`
 	WebGL1Available bool      `bson:"webgl1Available"`
 	HDRDisplay      bool      `bson:"hdrDisplay"`
+	SchemaVersion   int       `bson:"schemaVersion,omitempty"`
 	Report          Report    `bson:"report"`
+	RawReport       []byte    `bson:"rawReport,omitempty"`
 }
 
 func firstEnv(keys ...string) string {
@@ -95,7 +107,7 @@ func mongoDBNameFromURI(uri string) string {
 	return strings.Trim(u.Path, "/")
 }
 
-func openAndInitMongo(ctx context.Context, mongoURI string) (*mongoStore, error) {
+func openAndInitMongo(ctx context.Context, mongoURI string, cfg Config) (*mongoStore, error) {
 	uri := strings.TrimSpace(mongoURI)
 	if uri == "" {
 		uri = mongoURIFromEnv()
@@ -142,7 +154,13 @@ func openAndInitMongo(ctx context.Context, mongoURI string) (*mongoStore, error)
 		return nil, err
 	}
 
-	return &mongoStore{client: client, db: db, coll: coll}, nil
+	return &mongoStore{
+		client:     client,
+		db:         db,
+		coll:       coll,
+		maxReports: cfg.MaxReports,
+		dedupeTTL:  cfg.DedupeTTL,
+	}, nil
 }
 
 func ensureMongoIndexes(ctx context.Context, coll *mongo.Collection) error {
@@ -155,6 +173,10 @@ func ensureMongoIndexes(ctx context.Context, coll *mongo.Collection) error {
 			Keys:    bson.D{{Key: "createdAt", Value: -1}},
 			Options: options.Index().SetName("created_at_desc"),
 		},
+		{
+			Keys:    bson.D{{Key: "receivedAt", Value: -1}, {Key: "fingerprint", Value: -1}},
+			Options: options.Index().SetName("received_at_fingerprint_desc"),
+		},
 		{
 			Keys:    bson.D{{Key: "browser", Value: 1}},
 			Options: options.Index().SetName("browser"),
@@ -167,6 +189,10 @@ func ensureMongoIndexes(ctx context.Context, coll *mongo.Collection) error {
 			Keys:    bson.D{{Key: "country", Value: 1}},
 			Options: options.Index().SetName("country"),
 		},
+		{
+			Keys:    bson.D{{Key: "datacenter", Value: 1}},
+			Options: options.Index().SetName("datacenter"),
+		},
 		{
 			Keys:    bson.D{{Key: "deviceType", Value: 1}},
 			Options: options.Index().SetName("deviceType"),
@@ -175,6 +201,18 @@ func ensureMongoIndexes(ctx context.Context, coll *mongo.Collection) error {
 			Keys:    bson.D{{Key: "cpuArch", Value: 1}},
 			Options: options.Index().SetName("cpuArch"),
 		},
+		{
+			Keys:    bson.D{{Key: "gpuVendor", Value: 1}},
+			Options: options.Index().SetName("gpuVendor"),
+		},
+		{
+			Keys:    bson.D{{Key: "gpuArchitecture", Value: 1}},
+			Options: options.Index().SetName("gpuArchitecture"),
+		},
+		{
+			Keys:    bson.D{{Key: "gpuFamily", Value: 1}},
+			Options: options.Index().SetName("gpuFamily"),
+		},
 		{
 			Keys:    bson.D{{Key: "appleSilicon", Value: 1}},
 			Options: options.Index().SetName("appleSilicon"),
@@ -202,49 +240,109 @@ func ensureMongoIndexes(ctx context.Context, coll *mongo.Collection) error {
 	return nil
 }
 
-func (s *Store) mongoPing(ctx context.Context) error {
-	if s.mongo == nil || s.mongo.client == nil {
+func (m *mongoStore) Name() string { return "mongo" }
+
+func (m *mongoStore) Ping(ctx context.Context) error {
+	if m.client == nil {
 		return nil
 	}
-	return s.mongo.client.Ping(ctx, readpref.Primary())
+	return m.client.Ping(ctx, readpref.Primary())
 }
 
-func (s *Store) countReportsFromMongo(ctx context.Context) (int, error) {
-	if s.mongo == nil || s.mongo.coll == nil {
-		return 0, nil
-	}
-	n, err := s.mongo.coll.CountDocuments(ctx, bson.D{})
+func (m *mongoStore) Count(ctx context.Context) (int, error) {
+	n, err := m.coll.CountDocuments(ctx, bson.D{})
 	if err != nil {
 		return 0, fmt.Errorf("count reports: %w", err)
 	}
 	return int(n), nil
 }
 
-func (s *Store) loadReportsFromMongo(ctx context.Context) ([]Report, error) {
-	if s.mongo == nil || s.mongo.coll == nil {
-		return nil, nil
+func (m *mongoStore) CountDatacenter(ctx context.Context) (int, error) {
+	n, err := m.coll.CountDocuments(ctx, bson.M{"datacenter": true})
+	if err != nil {
+		return 0, fmt.Errorf("count datacenter reports: %w", err)
 	}
+	return int(n), nil
+}
 
+func (m *mongoStore) Load(ctx context.Context) ([]StoredReport, error) {
 	findOpts := options.Find().
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-		SetProjection(bson.D{{Key: "report", Value: 1}}).
-		SetLimit(int64(s.cfg.MaxReports))
+		SetProjection(bson.D{
+			{Key: "fingerprint", Value: 1},
+			{Key: "createdAt", Value: 1},
+			{Key: "receivedAt", Value: 1},
+			{Key: "report", Value: 1},
+		}).
+		SetLimit(int64(m.maxReports))
 
-	cur, err := s.mongo.coll.Find(ctx, bson.D{}, findOpts)
+	cur, err := m.coll.Find(ctx, bson.D{}, findOpts)
 	if err != nil {
 		return nil, fmt.Errorf("load reports: %w", err)
 	}
 	defer cur.Close(ctx)
 
-	reports := make([]Report, 0, 256)
+	reports := make([]StoredReport, 0, 256)
 	for cur.Next(ctx) {
-		var doc struct {
-			Report Report `bson:"report"`
+		var doc reportDoc
+		if err := cur.Decode(&doc); err != nil {
+			continue
+		}
+		reports = append(reports, doc.storedReport())
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (m *mongoStore) List(ctx context.Context, filter StatsFilter, after *reportCursor, limit int) ([]StoredReport, error) {
+	match, ok := mongoStatsMatch(filter)
+	if !ok {
+		stored, err := m.Load(ctx)
+		if err != nil {
+			return nil, err
 		}
+		return listStoredReports(stored, filter, after, limit), nil
+	}
+	if after != nil {
+		match = append(match, bson.E{Key: "$or", Value: bson.A{
+			bson.M{"receivedAt": bson.M{"$lt": after.ReceivedAt}},
+			bson.M{"receivedAt": after.ReceivedAt, "fingerprint": bson.M{"$lt": after.Fingerprint}},
+		}})
+	}
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "receivedAt", Value: -1}, {Key: "fingerprint", Value: -1}}).
+		SetProjection(bson.D{
+			{Key: "fingerprint", Value: 1},
+			{Key: "createdAt", Value: 1},
+			{Key: "receivedAt", Value: 1},
+			{Key: "gpuVendor", Value: 1},
+			{Key: "gpuArchitecture", Value: 1},
+			{Key: "report", Value: 1},
+		}).
+		SetLimit(int64(limit))
+
+	cur, err := m.coll.Find(ctx, match, findOpts)
+	if err != nil {
+		return nil, fmt.Errorf("list reports: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	reports := make([]StoredReport, 0, limit)
+	for cur.Next(ctx) {
+		var doc reportDoc
 		if err := cur.Decode(&doc); err != nil {
 			continue
 		}
-		reports = append(reports, doc.Report)
+		stored := doc.storedReport()
+		// GPU meta fields derived by an older parser (or before they
+		// existed) also need a rewrite, since AggregateStats groups by them.
+		if doc.GPUVendor != reportGPUVendor(stored.Report) || doc.GPUArchitecture != reportGPUArchitecture(stored.Report) {
+			stored.Migrated = true
+		}
+		reports = append(reports, stored)
 	}
 	if err := cur.Err(); err != nil {
 		return nil, fmt.Errorf("iterate reports: %w", err)
@@ -252,58 +350,165 @@ func (s *Store) loadReportsFromMongo(ctx context.Context) ([]Report, error) {
 	return reports, nil
 }
 
-func (s *Store) pruneMongo(ctx context.Context) error {
-	if s.mongo == nil || s.mongo.coll == nil {
-		return nil
+// Export walks a single cursor over the matching documents. Filters that
+// can't be expressed as a match (see mongoStatsMatch) are applied in Go.
+func (m *mongoStore) Export(ctx context.Context, filter StatsFilter, fn func(StoredReport) error) error {
+	match, pushed := mongoStatsMatch(filter)
+	if !pushed {
+		match = bson.D{}
 	}
-	if s.cfg.MaxReports <= 0 {
-		return nil
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "receivedAt", Value: -1}, {Key: "fingerprint", Value: -1}}).
+		SetProjection(bson.D{
+			{Key: "fingerprint", Value: 1},
+			{Key: "createdAt", Value: 1},
+			{Key: "receivedAt", Value: 1},
+			{Key: "report", Value: 1},
+		}).
+		SetBatchSize(256)
+
+	cur, err := m.coll.Find(ctx, match, findOpts)
+	if err != nil {
+		return fmt.Errorf("export reports: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var doc reportDoc
+		if err := cur.Decode(&doc); err != nil {
+			continue
+		}
+		stored := doc.storedReport()
+		if !pushed && !matchesStatsFilter(stored.Report, filter) {
+			continue
+		}
+		if err := fn(stored); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("iterate reports: %w", err)
+	}
+	return nil
+}
+
+func (m *mongoStore) Replace(ctx context.Context, fingerprint string, report Report) error {
+	meta := reportMetaFromReport(report)
+	set := bson.M{
+		"browser":         meta.Browser,
+		"os":              meta.OS,
+		"deviceType":      meta.DeviceType,
+		"cpuArch":         meta.CPUArch,
+		"country":         meta.Country,
+		"asOrg":           meta.ASOrg,
+		"datacenter":      meta.Datacenter,
+		"gpuVendor":       meta.GPUVendor,
+		"gpuArchitecture": meta.GPUArchitecture,
+		"gpuFamily":       meta.GPUFamily,
+		"gpuModel":        meta.GPUModel,
+		"gpuBackend":      meta.GPUBackend,
+		"webgpuAvailable": meta.WebGPUAvailable,
+		"webgl2Available": meta.WebGL2Available,
+		"webgl1Available": meta.WebGL1Available,
+		"hdrDisplay":      meta.HDRDisplay,
+		"schemaVersion":   meta.SchemaVersion,
+		"report":          report,
+	}
+	update := bson.M{"$set": set}
+	if meta.AppleSilicon != nil {
+		set["appleSilicon"] = *meta.AppleSilicon
+	} else {
+		update["$unset"] = bson.M{"appleSilicon": ""}
+	}
+	res, err := m.coll.UpdateOne(ctx, bson.M{"fingerprint": fingerprint}, update)
+	if err != nil {
+		return fmt.Errorf("replace report: %w", err)
 	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("replace report: %s not found", fingerprint)
+	}
+	return nil
+}
 
-	count, err := s.mongo.coll.CountDocuments(ctx, bson.D{})
+func (m *mongoStore) Get(ctx context.Context, fingerprint string) (StoredReport, bool, error) {
+	var doc reportDoc
+	err := m.coll.FindOne(ctx, bson.M{"fingerprint": fingerprint}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return StoredReport{}, false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("count reports: %w", err)
+		return StoredReport{}, false, fmt.Errorf("get report: %w", err)
 	}
-	max := int64(s.cfg.MaxReports)
+	return doc.storedReport(), true, nil
+}
+
+// storedReport upgrades the document's report to the current schema.
+func (d reportDoc) storedReport() StoredReport {
+	migrated := migrateReport(&d.Report)
+	return StoredReport{
+		Fingerprint: d.Fingerprint,
+		CreatedAt:   d.CreatedAt,
+		ReceivedAt:  d.ReceivedAt,
+		Report:      d.Report,
+		Raw:         d.RawReport,
+		Migrated:    migrated,
+	}
+}
+
+func (m *mongoStore) Prune(ctx context.Context) ([]Report, error) {
+	if m.maxReports <= 0 {
+		return nil, nil
+	}
+
+	count, err := m.coll.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("count reports: %w", err)
+	}
+	max := int64(m.maxReports)
 	if count <= max {
-		return nil
+		return nil, nil
 	}
 	toDelete := count - max
 
 	findOpts := options.Find().
 		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
 		SetLimit(toDelete).
-		SetProjection(bson.D{{Key: "_id", Value: 1}})
+		SetProjection(bson.D{{Key: "_id", Value: 1}, {Key: "report", Value: 1}})
 
-	cur, err := s.mongo.coll.Find(ctx, bson.D{}, findOpts)
+	cur, err := m.coll.Find(ctx, bson.D{}, findOpts)
 	if err != nil {
-		return fmt.Errorf("prune find: %w", err)
+		return nil, fmt.Errorf("prune find: %w", err)
 	}
 	defer cur.Close(ctx)
 
 	capHint := int(minInt64(toDelete, 2048))
 	ids := make([]bson.ObjectID, 0, capHint)
+	evicted := make([]Report, 0, capHint)
 	for cur.Next(ctx) {
 		var row struct {
-			ID bson.ObjectID `bson:"_id"`
+			ID     bson.ObjectID `bson:"_id"`
+			Report Report        `bson:"report"`
 		}
 		if err := cur.Decode(&row); err != nil {
 			continue
 		}
+		migrateReport(&row.Report)
 		ids = append(ids, row.ID)
+		evicted = append(evicted, row.Report)
 	}
 	if err := cur.Err(); err != nil {
-		return fmt.Errorf("prune iterate: %w", err)
+		return nil, fmt.Errorf("prune iterate: %w", err)
 	}
 	if len(ids) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	_, err = s.mongo.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	_, err = m.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
-		return fmt.Errorf("prune delete: %w", err)
+		return nil, fmt.Errorf("prune delete: %w", err)
 	}
-	return nil
+	return evicted, nil
 }
 
 func minInt64(a int64, b int64) int64 {
@@ -313,17 +518,11 @@ func minInt64(a int64, b int64) int64 {
 	return b
 }
 
-func (s *Store) submitMongo(now time.Time, _ip string, fingerprint string, report Report) (submitResult, error) {
-	s.mu.Lock()
-	s.maybeCleanupLocked(now)
-	s.totalReceived += 1
-	s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-	defer cancel()
-
+// newReportDoc is the document Submit inserts: the report plus the indexed
+// meta fields mongoStatsMatch filters on.
+func newReportDoc(now time.Time, fingerprint string, report Report, raw []byte) reportDoc {
 	meta := reportMetaFromReport(report)
-	doc := reportDoc{
+	return reportDoc{
 		Fingerprint:     fingerprint,
 		CreatedAt:       now,
 		ReceivedAt:      now,
@@ -332,115 +531,113 @@ func (s *Store) submitMongo(now time.Time, _ip string, fingerprint string, repor
 		DeviceType:      meta.DeviceType,
 		CPUArch:         meta.CPUArch,
 		Country:         meta.Country,
+		ASOrg:           meta.ASOrg,
+		Datacenter:      meta.Datacenter,
+		GPUVendor:       meta.GPUVendor,
+		GPUArchitecture: meta.GPUArchitecture,
+		GPUFamily:       meta.GPUFamily,
+		GPUModel:        meta.GPUModel,
+		GPUBackend:      meta.GPUBackend,
 		AppleSilicon:    meta.AppleSilicon,
 		WebGPUAvailable: meta.WebGPUAvailable,
 		WebGL2Available: meta.WebGL2Available,
 		WebGL1Available: meta.WebGL1Available,
 		HDRDisplay:      meta.HDRDisplay,
+		SchemaVersion:   meta.SchemaVersion,
 		Report:          report,
+		RawReport:       raw,
 	}
+}
 
-	status := "accepted"
-	stored := true
-	message := "Stored new fingerprint."
-
-	if _, err := s.mongo.coll.InsertOne(ctx, doc); err != nil {
-		if !mongo.IsDuplicateKeyError(err) {
-			s.mu.Lock()
-			s.totalRejected += 1
-			s.mu.Unlock()
-			return submitResult{}, fmt.Errorf("insert report: %w", err)
-		}
-
-		// Existing fingerprint: enforce dedupe window (but still update the record).
-		var existing struct {
-			ReceivedAt time.Time `bson:"receivedAt"`
-			Report     Report    `bson:"report"`
-		}
-		if err := s.mongo.coll.FindOne(ctx, bson.M{"fingerprint": fingerprint}, options.FindOne().SetProjection(bson.D{
-			{Key: "receivedAt", Value: 1},
-			{Key: "report", Value: 1},
-		})).Decode(&existing); err != nil {
-			s.mu.Lock()
-			s.totalRejected += 1
-			s.mu.Unlock()
-			return submitResult{}, fmt.Errorf("select receivedAt: %w", err)
-		}
+func (m *mongoStore) Submit(ctx context.Context, now time.Time, _ip string, fingerprint string, report Report, raw []byte) (submitChange, error) {
+	doc := newReportDoc(now, fingerprint, report, raw)
 
-		merged := mergeReportsPreferNew(report, existing.Report)
-		meta = reportMetaFromReport(merged)
+	_, err := m.coll.InsertOne(ctx, doc)
+	if err == nil {
+		return submitChange{Outcome: submitNew, Stored: report}, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return submitChange{}, fmt.Errorf("insert report: %w", err)
+	}
 
-		set := bson.M{
-			"receivedAt":      now,
-			"webgpuAvailable": meta.WebGPUAvailable,
-			"webgl2Available": meta.WebGL2Available,
-			"webgl1Available": meta.WebGL1Available,
-			"hdrDisplay":      meta.HDRDisplay,
-			"report":          merged,
-		}
-		if meta.Browser != "" {
-			set["browser"] = meta.Browser
-		}
-		if meta.OS != "" {
-			set["os"] = meta.OS
-		}
-		if meta.DeviceType != "" {
-			set["deviceType"] = meta.DeviceType
-		}
-		if meta.CPUArch != "" {
-			set["cpuArch"] = meta.CPUArch
-		}
-		if meta.Country != "" {
-			set["country"] = meta.Country
-		}
-		if meta.AppleSilicon != nil {
-			set["appleSilicon"] = *meta.AppleSilicon
-		}
+	// Existing fingerprint: enforce dedupe window (but still update the record).
+	var existing struct {
+		ReceivedAt time.Time `bson:"receivedAt"`
+		Report     Report    `bson:"report"`
+	}
+	if err := m.coll.FindOne(ctx, bson.M{"fingerprint": fingerprint}, options.FindOne().SetProjection(bson.D{
+		{Key: "receivedAt", Value: 1},
+		{Key: "report", Value: 1},
+	})).Decode(&existing); err != nil {
+		return submitChange{}, fmt.Errorf("select receivedAt: %w", err)
+	}
+	// Upgrade before merging: Previous is subtracted from the stats counters,
+	// which only ever counted migrated reports.
+	migrateReport(&existing.Report)
 
-		if _, err := s.mongo.coll.UpdateOne(ctx, bson.M{"fingerprint": fingerprint}, bson.M{"$set": set}); err != nil {
-			s.mu.Lock()
-			s.totalRejected += 1
-			s.mu.Unlock()
-			return submitResult{}, fmt.Errorf("update report: %w", err)
-		}
+	merged := mergeReportsPreferNew(report, existing.Report)
+	meta := reportMetaFromReport(merged)
 
-		if now.Sub(existing.ReceivedAt) < s.cfg.DedupeTTL {
-			status = "duplicate"
-			stored = false
-			message = "Duplicate fingerprint within dedupe window (updated existing record)."
-		} else {
-			message = "Updated existing fingerprint (outside dedupe window)."
-		}
+	set := bson.M{
+		"receivedAt":      now,
+		"webgpuAvailable": meta.WebGPUAvailable,
+		"webgl2Available": meta.WebGL2Available,
+		"webgl1Available": meta.WebGL1Available,
+		"hdrDisplay":      meta.HDRDisplay,
+		"schemaVersion":   meta.SchemaVersion,
+		"datacenter":      meta.Datacenter,
+		"report":          merged,
 	}
-
-	if err := s.pruneMongo(ctx); err != nil {
-		s.mu.Lock()
-		s.totalRejected += 1
-		s.mu.Unlock()
-		return submitResult{}, err
+	if meta.Browser != "" {
+		set["browser"] = meta.Browser
 	}
-	storedCount, err := s.countReportsFromMongo(ctx)
-	if err != nil {
-		s.mu.Lock()
-		s.totalRejected += 1
-		s.mu.Unlock()
-		return submitResult{}, err
+	if meta.OS != "" {
+		set["os"] = meta.OS
 	}
-
-	s.mu.Lock()
-	if status == "duplicate" {
-		s.totalDuplicate += 1
+	if meta.DeviceType != "" {
+		set["deviceType"] = meta.DeviceType
+	}
+	if meta.CPUArch != "" {
+		set["cpuArch"] = meta.CPUArch
+	}
+	if meta.Country != "" {
+		set["country"] = meta.Country
+	}
+	if meta.ASOrg != "" {
+		set["asOrg"] = meta.ASOrg
+	}
+	if meta.GPUVendor != "" {
+		set["gpuVendor"] = meta.GPUVendor
+	}
+	if meta.GPUArchitecture != "" {
+		set["gpuArchitecture"] = meta.GPUArchitecture
+	}
+	if meta.GPUFamily != "" {
+		set["gpuFamily"] = meta.GPUFamily
+	}
+	if meta.GPUModel != "" {
+		set["gpuModel"] = meta.GPUModel
+	}
+	if meta.GPUBackend != "" {
+		set["gpuBackend"] = meta.GPUBackend
+	}
+	if meta.AppleSilicon != nil {
+		set["appleSilicon"] = *meta.AppleSilicon
+	}
+	update := bson.M{"$set": set}
+	if len(raw) > 0 {
+		set["rawReport"] = raw
 	} else {
-		s.totalAccepted += 1
+		update["$unset"] = bson.M{"rawReport": ""}
 	}
-	s.mu.Unlock()
 
-	return submitResult{
-		Status:      status,
-		Fingerprint: fingerprint,
-		Stored:      stored,
-		StoredCount: storedCount,
-		ReceivedAt:  now,
-		Message:     message,
-	}, nil
+	if _, err := m.coll.UpdateOne(ctx, bson.M{"fingerprint": fingerprint}, update); err != nil {
+		return submitChange{}, fmt.Errorf("update report: %w", err)
+	}
+
+	change := submitChange{Outcome: submitUpdated, Previous: &existing.Report, Stored: merged}
+	if now.Sub(existing.ReceivedAt) < m.dedupeTTL {
+		change.Outcome = submitDuplicate
+	}
+	return change, nil
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       openAPIInfo                `json:"info"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components openAPIComponents          `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIPathItem map[string]*openAPIOperation
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Explode     *bool       `json:"explode,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*jsonSchema         `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityType `json:"securitySchemes"`
+}
+
+type openAPISecurityType struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+var openAPIParamDescriptions = map[string]string{
+	"browser":           "Browser name, e.g. Chrome.",
+	"os":                "OS name, e.g. macOS.",
+	"country":           "ISO 3166-1 alpha-2 country codes (repeat or comma-separate).",
+	"region":            "UN M49 regions, sub-regions or intermediate regions (e.g. Western Europe), or EU, APAC, LATAM.",
+	"continent":         "Continents: Africa, Antarctica, Asia, Europe, North America, Oceania, South America.",
+	"excludeDatacenter": "Drop reports submitted from cloud/hosting networks (needs -asn-db).",
+	"deviceType":        "desktop, mobile or tablet.",
+	"cpuArch":           "CPU architecture, e.g. arm64.",
+	"gpuVendor":         "Normalized GPU vendor.",
+	"gpuArchitecture":   "WebGPU adapter architecture.",
+	"appleSilicon":      "Only reports from (or not from) Apple Silicon Macs.",
+	"webgpuAvailable":   "WebGPU adapter availability.",
+	"webgl2Available":   "WebGL 2 context availability.",
+	"webgl1Available":   "WebGL 1 context availability.",
+	"hdrDisplay":        "HDR-capable display.",
+	"webgpuFeature":     "Required WebGPU features (repeat or comma-separate).",
+	"webgl2Ext":         "Required WebGL 2 extensions (repeat or comma-separate).",
+	"webgl1Ext":         "Required WebGL 1 extensions (repeat or comma-separate).",
+	"hdrVideoCodec":     "Required HDR video codecs (vp9-pq, av1-pq, hevc-pq).",
+	"webgpuMinLimit":    "Required WebGPU limits as name:value pairs, e.g. maxTextureDimension2D:16384. max* limits must be at least the value; minUniformBufferOffsetAlignment and minStorageBufferOffsetAlignment at most it.",
+	"minSchemaVersion":  "Only reports from detector schema version N or later.",
+	"groupBy":           "Matrix columns.",
+	"usage":             "Which format usage counts as supported.",
+	"limit":             "Maximum number of items.",
+	"minTested":         "Hide columns with fewer tested reports.",
+	"excludeUnknown":    "Hide the Unknown column.",
+	"metric":            "webgpuAvailable, webgl2Available, webgl1Available, hdrDisplay, a texture family (astc, astcHdr, etc2, etc1, pvrtc, bc13, rgtc, bc6h, bc7), format:<name>, webgpuFeature:<name> or hdrVideo:<codec>.",
+	"bucket":            "Bucket width.",
+	"by":                "Which timestamp places a report in a bucket.",
+	"from":              "Range start (RFC 3339 or YYYY-MM-DD); defaults to 90 days before to.",
+	"to":                "Range end (RFC 3339 or YYYY-MM-DD); defaults to now.",
+	"cursor":            "nextCursor from the previous page.",
+	"format":            "Output format.",
+}
+
+var openAPIParamEnums = map[string][]string{
+	"groupBy": {"device", "os_browser", "os", "browser", "device_type", "gpu_vendor", "gpu_architecture"},
+	"usage":   {"any", "sampled", "renderable", "storage", "filterable"},
+	"bucket":  {"day", "week", "month"},
+	"by":      {"createdAt", "receivedAt"},
+	"format":  {"ndjson", "csv"},
+}
+
+// queryParameters lists one query parameter per json field of t.
+func queryParameters(t reflect.Type) []openAPIParameter {
+	var params []openAPIParameter
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if name := jsonFieldName(field); name != "" {
+			params = append(params, queryParameter(name, field.Type))
+		}
+	}
+	return params
+}
+
+func queryParameter(name string, t reflect.Type) openAPIParameter {
+	p := openAPIParameter{Name: name, In: "query", Description: openAPIParamDescriptions[name]}
+	switch {
+	case t == timeType:
+		p.Schema = &jsonSchema{Type: "string"}
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Bool, t.Kind() == reflect.Bool:
+		p.Schema = &jsonSchema{Type: "boolean"}
+	case t.Kind() == reflect.Int:
+		p.Schema = &jsonSchema{Type: "integer"}
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Map:
+		p.Schema = &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}
+		p.Explode = ptr(true)
+	default:
+		p.Schema = &jsonSchema{Type: "string", Enum: openAPIParamEnums[name]}
+	}
+	return p
+}
+
+func openAPIJSON(s *jsonSchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: s}}
+}
+
+func openAPIError(description string) openAPIResponse {
+	return openAPIResponse{Description: description, Content: openAPIJSON(&jsonSchema{Ref: "#/components/schemas/ErrorResponse"})}
+}
+
+// ErrorResponse documents the {"error", "details"} objects written by the
+// handlers; it is not used in code.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// openAPISpec builds the document from the same Go types the handlers use.
+// Query parameters come from the json tags of StatsFilter, CompatOptions and
+// TrendsOptions, which match the names parseStatsFilter and friends read, so
+// a new option shows up without touching this file; only its entry in
+// openAPIParamDescriptions needs adding.
+func openAPISpec() *openAPIDocument {
+	g := newJSONSchemaGenerator("#/components/schemas/", reportSchemaAnnotations)
+	ref := func(v any) *jsonSchema { return g.schemaFor(reflect.TypeOf(v)) }
+	ok := func(description string, v any) openAPIResponse {
+		return openAPIResponse{Description: description, Content: openAPIJSON(ref(v))}
+	}
+	ref(ErrorResponse{})
+
+	filter := queryParameters(reflect.TypeFor[StatsFilter]())
+	get := func(id, summary string, params []openAPIParameter, responses map[string]openAPIResponse) openAPIPathItem {
+		responses["405"] = openAPIError("Method not allowed.")
+		return openAPIPathItem{"get": {OperationID: id, Summary: summary, Parameters: params, Responses: responses}}
+	}
+	post := func(op *openAPIOperation) openAPIPathItem {
+		op.Responses["405"] = openAPIError("Method not allowed.")
+		return openAPIPathItem{"post": op}
+	}
+	reportBody := &openAPIRequestBody{Required: true, Content: openAPIJSON(ref(Report{}))}
+
+	paths := map[string]openAPIPathItem{
+		"/api/stats": get("getStats", "Aggregated capability statistics", filter, map[string]openAPIResponse{
+			"200": ok("Statistics for the matching reports.", StatsResponse{}),
+			"304": {Description: "Not modified (If-None-Match)."},
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/compat": get("getCompat", "Format support matrix", slices.Concat(filter, queryParameters(reflect.TypeFor[CompatOptions]())), map[string]openAPIResponse{
+			"200": ok("Format support by group.", CompatResponse{}),
+			"304": {Description: "Not modified (If-None-Match)."},
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/trends": get("getTrends", "Support over time", slices.Concat(filter, queryParameters(reflect.TypeFor[TrendsOptions]())), map[string]openAPIResponse{
+			"200": ok("One bucket per period.", TrendsResponse{}),
+			"400": openAPIError("Invalid metric, bucket or range."),
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/reports": get("listReports", "Stored reports, newest first", slices.Concat(filter, []openAPIParameter{
+			queryParameter("limit", reflect.TypeFor[int]()),
+			queryParameter("cursor", reflect.TypeFor[string]()),
+		}), map[string]openAPIResponse{
+			"200": ok("One page of reports.", ReportsPage{}),
+			"400": openAPIError("Invalid limit or cursor."),
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/reports/{fingerprint}": get("getReport", "One stored report", []openAPIParameter{
+			{Name: "fingerprint", In: "path", Required: true, Schema: &jsonSchema{Type: "string"}},
+		}, map[string]openAPIResponse{
+			"200": ok("The report.", StoredReport{}),
+			"404": openAPIError("Report not found."),
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/export": get("exportReports", "Stream matching reports as NDJSON or CSV", slices.Concat(filter, []openAPIParameter{
+			queryParameter("format", reflect.TypeFor[string]()),
+		}), map[string]openAPIResponse{
+			"200": {Description: "One StoredReport per line (ndjson) or one row per report (csv).", Content: map[string]openAPIMediaType{
+				"application/x-ndjson": {Schema: ref(StoredReport{})},
+				"text/csv":             {Schema: &jsonSchema{Type: "string"}},
+			}},
+			"400": openAPIError("Invalid format."),
+			"500": openAPIError("Store unavailable."),
+		}),
+		"/api/report": post(&openAPIOperation{
+			OperationID: "submitReport",
+			Summary:     "Submit a report",
+			Description: "Bodies may be gzip or br encoded. Reports are deduplicated by fingerprint.",
+			RequestBody: reportBody,
+			Responses: map[string]openAPIResponse{
+				"200": ok("Accepted or duplicate.", submitResult{}),
+				"400": openAPIError("Invalid JSON or report."),
+				"403": openAPIError("Forbidden origin."),
+				"413": openAPIError("Body too large."),
+				"415": openAPIError("Unsupported content type or encoding."),
+				"429": openAPIError("Rate limited."),
+				"500": openAPIError("Store unavailable."),
+			},
+		}),
+		"/api/reports/batch": post(&openAPIOperation{
+			OperationID: "submitReportBatch",
+			Summary:     "Submit several reports",
+			Description: "A JSON array or NDJSON; each item is validated, rate limited and stored on its own.",
+			RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"application/json":     {Schema: &jsonSchema{Type: "array", Items: ref(Report{})}},
+				"application/x-ndjson": {Schema: ref(Report{})},
+			}},
+			Responses: map[string]openAPIResponse{
+				"200": ok("Per-item results.", BatchResponse{}),
+				"400": openAPIError("Malformed or empty batch."),
+				"403": openAPIError("Forbidden origin."),
+				"413": openAPIError("Body too large."),
+				"415": openAPIError("Unsupported content type or encoding."),
+				"429": ok("Every item was rate limited.", BatchResponse{}),
+			},
+		}),
+		"/api/import": post(&openAPIOperation{
+			OperationID: "importReports",
+			Summary:     "Import an NDJSON export",
+			Description: "Disabled (404) unless the server has an admin token.",
+			RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"application/x-ndjson": {Schema: ref(importLine{})},
+			}},
+			Responses: map[string]openAPIResponse{
+				"200": ok("Per-line results.", ImportResponse{}),
+				"400": openAPIError("Import aborted."),
+				"401": openAPIError("Missing or wrong admin token."),
+				"404": openAPIError("Import disabled."),
+			},
+			Security: []map[string][]string{{"adminToken": {}}},
+		}),
+		"/api/validate": post(&openAPIOperation{
+			OperationID: "validateReport",
+			Summary:     "Validate a report without storing it",
+			RequestBody: reportBody,
+			Responses: map[string]openAPIResponse{
+				"200": ok("Field-level errors, or the report as it would be stored.", ValidateResponse{}),
+				"413": openAPIError("Body too large."),
+				"415": openAPIError("Unsupported content type or encoding."),
+				"429": openAPIError("Rate limited."),
+			},
+		}),
+		"/api/schema/report.json": get("getReportSchema", "JSON Schema of the report body", nil, map[string]openAPIResponse{
+			"200": {Description: "JSON Schema (draft 2020-12).", Content: map[string]openAPIMediaType{
+				"application/schema+json": {Schema: &jsonSchema{Type: "object"}},
+			}},
+			"304": {Description: "Not modified (If-None-Match)."},
+		}),
+		"/api/openapi.json": get("getOpenAPI", "This document", nil, map[string]openAPIResponse{
+			"200": {Description: "OpenAPI 3.1 document.", Content: openAPIJSON(&jsonSchema{Type: "object"})},
+			"304": {Description: "Not modified (If-None-Match)."},
+		}),
+		"/healthz": get("getHealth", "Liveness and store health", nil, map[string]openAPIResponse{
+			"200": ok("Healthy.", healthResponse{}),
+			"503": ok("Store unavailable.", healthResponse{}),
+		}),
+	}
+
+	return &openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info: openAPIInfo{
+			Title:       "hdr-detection",
+			Version:     fmt.Sprintf("report schema %d", currentReportSchemaVersion),
+			Description: "HDR, WebGPU and WebGL capability reports and their aggregates.",
+		},
+		Paths: paths,
+		Components: openAPIComponents{
+			Schemas:         g.defs,
+			SecuritySchemes: map[string]openAPISecurityType{"adminToken": {Type: "http", Scheme: "bearer"}},
+		},
+	}
+}
+
+var openAPIResponseBody = sync.OnceValues(func() (cachedResponse, error) {
+	return encodeCachedResponse(time.Now(), func() (any, error) {
+		return openAPISpec(), nil
+	})
+})
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	resp, err := openAPIResponseBody()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "openapi unavailable", "details": err.Error()})
+		return
+	}
+	writeCachedJSON(w, r, resp)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIQueryParameters checks the documented query parameters of each
+// /api path against the keys its handler actually reads. The handler side is
+// found in the source: the apiHandler case for the path, followed into every
+// package function it calls, collecting q.Get("key"), q["key"] and
+// r.URL.Query().Get("key").
+func TestOpenAPIQueryParameters(t *testing.T) {
+	funcs := parsePackageFuncs(t)
+	handler := funcs["apiHandler"]
+	if handler == nil {
+		t.Fatal("apiHandler not found")
+	}
+
+	read := map[string][]string{}
+	ast.Inspect(handler, func(n ast.Node) bool {
+		clause, ok := n.(*ast.CaseClause)
+		if !ok {
+			return true
+		}
+		for _, e := range clause.List {
+			lit, ok := e.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				continue
+			}
+			path, _ := strconv.Unquote(lit.Value)
+			keys := map[string]bool{}
+			for _, stmt := range clause.Body {
+				collectQueryKeys(stmt, funcs, keys, map[string]bool{})
+			}
+			read[path] = sortedKeys(keys)
+		}
+		return true
+	})
+	if len(read["/api/stats"]) == 0 {
+		t.Fatal("found no query keys for /api/stats; has the handler moved?")
+	}
+
+	spec := openAPISpec()
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			var documented []string
+			for _, p := range op.Parameters {
+				if p.In != "query" {
+					continue
+				}
+				documented = append(documented, p.Name)
+				if p.Description == "" {
+					t.Errorf("%s %s: query parameter %q has no description in openAPIParamDescriptions", method, path, p.Name)
+				}
+			}
+			slices.Sort(documented)
+			got := read[path]
+			if missing := without(got, documented); len(missing) > 0 {
+				t.Errorf("%s %s: handler reads undocumented query parameters %v", method, path, missing)
+			}
+			if extra := without(documented, got); len(extra) > 0 {
+				t.Errorf("%s %s: spec documents query parameters the handler never reads: %v", method, path, extra)
+			}
+		}
+	}
+	for path, keys := range read {
+		if _, ok := spec.Paths[path]; !ok && len(keys) > 0 {
+			t.Errorf("%s reads %v but is missing from the spec", path, keys)
+		}
+	}
+}
+
+func parsePackageFuncs(t *testing.T) map[string]*ast.FuncDecl {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	funcs := map[string]*ast.FuncDecl{}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				funcs[fn.Name.Name] = fn
+			}
+		}
+	}
+	return funcs
+}
+
+// collectQueryKeys adds the query keys read under n to keys, descending into
+// calls to package-level functions.
+func collectQueryKeys(n ast.Node, funcs map[string]*ast.FuncDecl, keys map[string]bool, visited map[string]bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			if isQueryValues(n.X) {
+				if key, ok := stringLit(n.Index); ok {
+					keys[key] = true
+				}
+			}
+		case *ast.CallExpr:
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Get" && isQueryValues(sel.X) && len(n.Args) == 1 {
+				if key, ok := stringLit(n.Args[0]); ok {
+					keys[key] = true
+				}
+			}
+			if id, ok := n.Fun.(*ast.Ident); ok && funcs[id.Name] != nil && !visited[id.Name] {
+				visited[id.Name] = true
+				collectQueryKeys(funcs[id.Name].Body, funcs, keys, visited)
+			}
+		}
+		return true
+	})
+}
+
+// isQueryValues matches the url.Values expressions the handlers use: a
+// variable named q or a r.URL.Query() call.
+func isQueryValues(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name == "q"
+	case *ast.CallExpr:
+		sel, ok := e.Fun.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Query" && len(e.Args) == 0
+	}
+	return false
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// without returns the elements of a that are not in b.
+func without(a, b []string) []string {
+	var out []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/textproto"
+	"strings"
+)
+
+// trustedProxy is a -trusted-proxies entry: a peer inside prefix is a proxy
+// we operate or rent, whose header carries the address it received the
+// request from. Geo headers (CF-IPCountry etc.) are only read from requests
+// whose peer is a trusted proxy.
+type trustedProxy struct {
+	prefix netip.Prefix
+	header string
+}
+
+// proxyTrust decides which forwarding headers to believe.
+type proxyTrust struct {
+	proxies []trustedProxy
+	// anyPeer trusts the direct peer whatever its address (the Render
+	// default, where the load balancer's addresses aren't published); hops
+	// listed in its header still have to match proxies.
+	anyPeer bool
+}
+
+// privateProxyRanges are loopback, RFC 1918, CGNAT and link-local ranges:
+// the default when -trusted-proxies isn't set, which covers a reverse proxy
+// on the same host or private network.
+var privateProxyRanges = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "169.254.0.0/16",
+	"::1/128", "fc00::/7", "fe80::/10",
+}
+
+// trustedProxies is set from -trusted-proxies at startup.
+var trustedProxies = defaultProxyTrust(false)
+
+func defaultProxyTrust(render bool) proxyTrust {
+	t := proxyTrust{anyPeer: render}
+	for _, cidr := range privateProxyRanges {
+		t.proxies = append(t.proxies, trustedProxy{prefix: netip.MustParsePrefix(cidr), header: "X-Forwarded-For"})
+	}
+	return t
+}
+
+// parseProxyTrust reads a -trusted-proxies list:
+//
+//	10.0.0.0/8                     X-Forwarded-For (the default header)
+//	173.245.48.0/20=CF-Connecting-IP
+//	192.0.2.10=Forwarded           a bare address is a single host
+//	none                           trust no forwarding headers
+//
+// X-Forwarded-For and Forwarded are walked right to left; any other header
+// names a single client address set by that proxy (X-Real-IP,
+// CF-Connecting-IP, True-Client-IP, ...). An empty spec keeps the private
+// ranges (plus any peer on Render).
+func parseProxyTrust(spec string, render bool) (proxyTrust, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return defaultProxyTrust(render), nil
+	}
+	if strings.EqualFold(spec, "none") {
+		return proxyTrust{}, nil
+	}
+
+	var t proxyTrust
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cidr, header, _ := strings.Cut(entry, "=")
+		cidr = strings.TrimSpace(cidr)
+		header = strings.TrimSpace(header)
+		if header == "" {
+			header = "X-Forwarded-For"
+		}
+		if strings.ContainsAny(header, " \t:;") {
+			return proxyTrust{}, fmt.Errorf("trusted proxies: invalid header %q", header)
+		}
+
+		var prefix netip.Prefix
+		var err error
+		if strings.Contains(cidr, "/") {
+			prefix, err = netip.ParsePrefix(cidr)
+		} else {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(cidr); err == nil {
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+		}
+		if err != nil {
+			return proxyTrust{}, fmt.Errorf("trusted proxies: invalid range %q", cidr)
+		}
+		t.proxies = append(t.proxies, trustedProxy{
+			prefix: prefix.Masked(),
+			header: textproto.CanonicalMIMEHeaderKey(header),
+		})
+	}
+	return t, nil
+}
+
+// lookup returns the most specific entry containing addr.
+func (t proxyTrust) lookup(addr netip.Addr) (trustedProxy, bool) {
+	addr = addr.Unmap()
+	best, found := trustedProxy{}, false
+	for _, p := range t.proxies {
+		if p.prefix.Contains(addr) && (!found || p.prefix.Bits() > best.prefix.Bits()) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// clientIP walks back from the peer: while the current hop is a trusted
+// proxy, the next hop is taken from the header that proxy is trusted for,
+// consuming list headers from the right. The first untrusted hop is the
+// client; entries further left were supplied by the client and are ignored.
+func (t proxyTrust) clientIP(r *http.Request) string {
+	peer := stripPortMaybe(r.RemoteAddr)
+	hop, err := netip.ParseAddr(strings.Trim(peer, "[]"))
+	if err != nil {
+		return peer
+	}
+
+	lists := map[string][]netip.Addr{}
+	for first := true; ; first = false {
+		p, ok := t.lookup(hop)
+		if !ok && first && t.anyPeer {
+			p, ok = trustedProxy{header: "X-Forwarded-For"}, true
+		}
+		if !ok {
+			return hop.Unmap().String()
+		}
+
+		switch p.header {
+		case "X-Forwarded-For", "Forwarded":
+			addrs, parsed := lists[p.header]
+			if !parsed {
+				addrs = forwardingHeaderAddrs(r.Header, p.header)
+			}
+			if len(addrs) == 0 {
+				return hop.Unmap().String()
+			}
+			hop = addrs[len(addrs)-1]
+			lists[p.header] = addrs[:len(addrs)-1]
+		default:
+			// A single-address header is the proxy's own verdict.
+			addr, err := netip.ParseAddr(strings.Trim(stripPortMaybe(r.Header.Get(p.header)), "[]"))
+			if err != nil {
+				return hop.Unmap().String()
+			}
+			return addr.Unmap().String()
+		}
+	}
+}
+
+// peerTrusted reports whether the request came straight from a trusted proxy.
+func (t proxyTrust) peerTrusted(r *http.Request) bool {
+	if t.anyPeer {
+		return true
+	}
+	hop, err := netip.ParseAddr(strings.Trim(stripPortMaybe(r.RemoteAddr), "[]"))
+	if err != nil {
+		return false
+	}
+	_, ok := t.lookup(hop)
+	return ok
+}
+
+// forwardingHeaderAddrs lists the addresses in every X-Forwarded-For or
+// Forwarded (RFC 7239 for=) line of h, left to right. The list stops at the
+// last element that isn't an address ("unknown", obfuscated identifiers,
+// garbage): nothing to the left of it can be attributed to a proxy.
+func forwardingHeaderAddrs(h http.Header, name string) []netip.Addr {
+	var addrs []netip.Addr
+	for _, line := range h.Values(name) {
+		for _, el := range strings.Split(line, ",") {
+			val := strings.TrimSpace(el)
+			if name == "Forwarded" {
+				val = ""
+				for _, param := range strings.Split(el, ";") {
+					k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+					if strings.EqualFold(k, "for") {
+						val = strings.Trim(strings.TrimSpace(v), "\"")
+					}
+				}
+			}
+			addr, err := netip.ParseAddr(strings.Trim(stripPortMaybe(val), "[]"))
+			if err != nil {
+				addrs = addrs[:0]
+				continue
+			}
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs
+}
+
+func clientIP(r *http.Request) string {
+	return trustedProxies.clientIP(r)
+}
+
+func trustProxyHeadersForRequest(r *http.Request) bool {
+	return trustedProxies.peerTrusted(r)
+}
+
+// describe is the startup log line.
+func (t proxyTrust) describe() string {
+	if len(t.proxies) == 0 && !t.anyPeer {
+		return "none"
+	}
+	parts := make([]string, 0, len(t.proxies)+1)
+	if t.anyPeer {
+		parts = append(parts, "any peer (RENDER=true)")
+	}
+	for _, p := range t.proxies {
+		parts = append(parts, p.prefix.String()+"="+p.header)
+	}
+	return strings.Join(parts, ", ")
+}
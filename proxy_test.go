@@ -0,0 +1,278 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProxyTrustClientIP(t *testing.T) {
+	const spec = "10.0.0.0/8, 173.245.48.0/20=CF-Connecting-IP, 192.0.2.10=Forwarded, 10.1.2.3=X-Real-IP"
+
+	tests := []struct {
+		name   string
+		spec   string
+		render bool
+		peer   string
+		header http.Header
+		want   string
+	}{
+		{
+			name:   "client-prepended entries are ignored",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "trusted hops are skipped",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8, 10.0.0.7"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "multiple header lines form one list",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8", "10.0.0.7"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "only trusted hops: the leftmost one",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"10.0.0.9"}},
+			want:   "10.0.0.9",
+		},
+		{
+			name:   "garbage cuts off everything to its left",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8, unknown, 10.0.0.9"}},
+			want:   "10.0.0.9",
+		},
+		{
+			name:   "IPv4-mapped peer",
+			spec:   spec,
+			peer:   "[::ffff:10.0.0.1]:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "untrusted peer",
+			spec:   spec,
+			peer:   "8.8.4.4:5000",
+			header: http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			want:   "8.8.4.4",
+		},
+		{
+			name:   "Forwarded with quoted IPv6 and port",
+			spec:   spec,
+			peer:   "192.0.2.10:443",
+			header: http.Header{"Forwarded": {`for=6.6.6.6, for="[2001:db8:cafe::17]:4711";proto=https`}},
+			want:   "2001:db8:cafe::17",
+		},
+		{
+			name:   "Forwarded with IPv4 and port",
+			spec:   spec,
+			peer:   "192.0.2.10:443",
+			header: http.Header{"Forwarded": {`proto=http;for="198.51.100.7:1234";by=192.0.2.10`}},
+			want:   "198.51.100.7",
+		},
+		{
+			name:   "Forwarded obfuscated identifier",
+			spec:   spec,
+			peer:   "192.0.2.10:443",
+			header: http.Header{"Forwarded": {"for=_hidden"}},
+			want:   "192.0.2.10",
+		},
+		{
+			name:   "Forwarded proxy ignores X-Forwarded-For",
+			spec:   spec,
+			peer:   "192.0.2.10:443",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8"}},
+			want:   "192.0.2.10",
+		},
+		{
+			name:   "single-address header from its proxy",
+			spec:   spec,
+			peer:   "173.245.48.5:443",
+			header: http.Header{"Cf-Connecting-Ip": {"1.2.3.4"}, "X-Forwarded-For": {"6.6.6.6"}},
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "single-address header from an untrusted peer",
+			spec:   spec,
+			peer:   "8.8.4.4:443",
+			header: http.Header{"Cf-Connecting-Ip": {"1.2.3.4"}},
+			want:   "8.8.4.4",
+		},
+		{
+			name:   "single-address header reached through a trusted hop",
+			spec:   spec,
+			peer:   "10.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"173.245.48.5"}, "Cf-Connecting-Ip": {"1.2.3.4"}},
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "single-address header missing",
+			spec:   spec,
+			peer:   "173.245.48.5:443",
+			header: http.Header{},
+			want:   "173.245.48.5",
+		},
+		{
+			name:   "longest prefix picks the header",
+			spec:   spec,
+			peer:   "10.1.2.3:5000",
+			header: http.Header{"X-Real-Ip": {"5.6.7.8"}, "X-Forwarded-For": {"9.9.9.9"}},
+			want:   "5.6.7.8",
+		},
+		{
+			name:   "longest prefix regardless of order",
+			spec:   "10.1.2.3=X-Real-IP, 10.0.0.0/8",
+			peer:   "10.1.2.3:5000",
+			header: http.Header{"X-Real-Ip": {"5.6.7.8"}, "X-Forwarded-For": {"9.9.9.9"}},
+			want:   "5.6.7.8",
+		},
+		{
+			name:   "default trusts private ranges",
+			peer:   "127.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "default ignores public peers",
+			peer:   "35.1.1.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8"}},
+			want:   "35.1.1.1",
+		},
+		{
+			name:   "Render trusts the first peer",
+			render: true,
+			peer:   "35.1.1.1:5000",
+			header: http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "Render trusts only the first peer",
+			render: true,
+			peer:   "35.1.1.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8, 35.2.2.2"}},
+			want:   "35.2.2.2",
+		},
+		{
+			name:   "Render skips private hops",
+			render: true,
+			peer:   "35.1.1.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8, 10.0.0.7"}},
+			want:   "8.8.8.8",
+		},
+		{
+			name:   "none",
+			spec:   "none",
+			peer:   "127.0.0.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8"}, "X-Real-Ip": {"8.8.8.8"}},
+			want:   "127.0.0.1",
+		},
+		{
+			name:   "none on Render",
+			spec:   "none",
+			render: true,
+			peer:   "35.1.1.1:5000",
+			header: http.Header{"X-Forwarded-For": {"8.8.8.8"}},
+			want:   "35.1.1.1",
+		},
+		{
+			name: "unparseable peer is returned as is",
+			spec: spec,
+			peer: "@unix",
+			want: "@unix",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trust, err := parseProxyTrust(tt.spec, tt.render)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/api/report", nil)
+			r.RemoteAddr = tt.peer
+			r.Header = tt.header
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			if got := trust.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyTrustPeerTrusted(t *testing.T) {
+	tests := []struct {
+		spec   string
+		render bool
+		peer   string
+		want   bool
+	}{
+		{"", false, "127.0.0.1:1", true},
+		{"", false, "[fd00::1]:1", true},
+		{"", false, "8.8.8.8:1", false},
+		{"", true, "8.8.8.8:1", true},
+		{"none", false, "127.0.0.1:1", false},
+		{"none", true, "8.8.8.8:1", false},
+		{"173.245.48.0/20=CF-Connecting-IP", false, "173.245.50.1:1", true},
+		{"173.245.48.0/20=CF-Connecting-IP", false, "127.0.0.1:1", false},
+		{"173.245.48.0/20", false, "garbage", false},
+	}
+	for _, tt := range tests {
+		trust, err := parseProxyTrust(tt.spec, tt.render)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.peer
+		if got := trust.peerTrusted(r); got != tt.want {
+			t.Errorf("parseProxyTrust(%q, %v).peerTrusted(%s) = %v, want %v", tt.spec, tt.render, tt.peer, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxyTrust(t *testing.T) {
+	tests := []struct {
+		spec   string
+		render bool
+		want   string
+	}{
+		{"none", true, "none"},
+		{" NONE ", false, "none"},
+		{"10.0.0.0/8", false, "10.0.0.0/8=X-Forwarded-For"},
+		{"10.1.2.3/8, 192.0.2.10=forwarded", false, "10.0.0.0/8=X-Forwarded-For, 192.0.2.10/32=Forwarded"},
+		{"2001:db8::1=cf-connecting-ip,,", false, "2001:db8::1/128=Cf-Connecting-Ip"},
+		{"10.0.0.0/8", true, "10.0.0.0/8=X-Forwarded-For"},
+	}
+	for _, tt := range tests {
+		trust, err := parseProxyTrust(tt.spec, tt.render)
+		if err != nil {
+			t.Errorf("parseProxyTrust(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := trust.describe(); got != tt.want {
+			t.Errorf("parseProxyTrust(%q).describe() = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+
+	if got := defaultProxyTrust(true).describe(); !strings.HasPrefix(got, "any peer (RENDER=true), 127.0.0.0/8=X-Forwarded-For") {
+		t.Errorf("default Render trust = %q", got)
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "example.com", "10.0.0.1=X Real IP", "10.0.0.1=Host:"} {
+		if _, err := parseProxyTrust(bad, false); err == nil {
+			t.Errorf("parseProxyTrust(%q): want error", bad)
+		}
+	}
+}
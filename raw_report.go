@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressRawReport gzips the submitted JSON body so fields the Report struct
+// does not model yet can be backfilled later. Payloads whose compressed size
+// exceeds maxBytes are dropped (nil) rather than truncated.
+func compressRawReport(raw []byte, maxBytes int) []byte {
+	if len(raw) == 0 || maxBytes <= 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return nil
+	}
+	if err := zw.Close(); err != nil {
+		return nil
+	}
+	if buf.Len() > maxBytes {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// decompressRawReport reverses compressRawReport.
+func decompressRawReport(compressed []byte) ([]byte, error) {
+	if len(compressed) == 0 {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("raw report: %w", err)
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("raw report: %w", err)
+	}
+	return raw, nil
+}
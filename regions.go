@@ -0,0 +1,159 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//go:embed geo_regions.csv
+var geoRegionsCSV string
+
+// countryRegion is a country's place in the UN M49 hierarchy.
+type countryRegion struct {
+	Region             string
+	Subregion          string
+	IntermediateRegion string
+	Continent          string
+}
+
+// countryRegions is keyed by ISO 3166-1 alpha-2 code.
+var countryRegions = parseCountryRegions(geoRegionsCSV)
+
+func parseCountryRegions(data string) map[string]countryRegion {
+	cr := csv.NewReader(strings.NewReader(data))
+	cr.Comment = '#'
+	rows, err := cr.ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("geo_regions.csv: %v", err))
+	}
+	out := make(map[string]countryRegion, len(rows))
+	for _, row := range rows[1:] {
+		out[row[0]] = countryRegion{
+			Region:             row[1],
+			Subregion:          row[2],
+			IntermediateRegion: row[3],
+			Continent:          row[4],
+		}
+	}
+	return out
+}
+
+// euMemberStates are the 27 EU members, for the "EU" market region.
+var euMemberStates = []string{
+	"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+	"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+}
+
+// marketRegions are the non-M49 groupings accepted by the region filter.
+var marketRegions = map[string]func(code string, cr countryRegion) bool{
+	"EU": func(code string, _ countryRegion) bool { return slices.Contains(euMemberStates, code) },
+	"APAC": func(_ string, cr countryRegion) bool {
+		switch cr.Subregion {
+		case "Eastern Asia", "South-eastern Asia", "Southern Asia":
+			return true
+		}
+		return cr.Region == "Oceania"
+	},
+	"LATAM": func(_ string, cr countryRegion) bool { return cr.Subregion == "Latin America and the Caribbean" },
+}
+
+// regionCountries lists the countries in an M49 region, sub-region or
+// intermediate region, or a market region (EU, APAC, LATAM); names are
+// matched case-insensitively.
+func regionCountries(name string) []string {
+	var out []string
+	for market, in := range marketRegions {
+		if strings.EqualFold(market, name) {
+			for code, cr := range countryRegions {
+				if in(code, cr) {
+					out = append(out, code)
+				}
+			}
+			return out
+		}
+	}
+	for code, cr := range countryRegions {
+		if strings.EqualFold(cr.Region, name) || strings.EqualFold(cr.Subregion, name) || strings.EqualFold(cr.IntermediateRegion, name) {
+			out = append(out, code)
+		}
+	}
+	return out
+}
+
+func continentCountries(name string) []string {
+	var out []string
+	for code, cr := range countryRegions {
+		if strings.EqualFold(cr.Continent, name) {
+			out = append(out, code)
+		}
+	}
+	return out
+}
+
+// countrySet resolves the country, region and continent filters into the
+// set of matching country codes; ok is false when none of them is set. Each
+// list is a union and the three are intersected.
+func (f StatsFilter) countrySet() (set map[string]bool, ok bool) {
+	if len(f.Country) == 0 && len(f.Region) == 0 && len(f.Continent) == 0 {
+		return nil, false
+	}
+	var lists [][]string
+	if len(f.Country) > 0 {
+		lists = append(lists, f.Country)
+	}
+	if len(f.Region) > 0 {
+		var codes []string
+		for _, name := range f.Region {
+			codes = append(codes, regionCountries(name)...)
+		}
+		lists = append(lists, codes)
+	}
+	if len(f.Continent) > 0 {
+		var codes []string
+		for _, name := range f.Continent {
+			codes = append(codes, continentCountries(name)...)
+		}
+		lists = append(lists, codes)
+	}
+
+	set = make(map[string]bool)
+	for _, code := range lists[0] {
+		set[code] = true
+	}
+	for _, list := range lists[1:] {
+		for code := range set {
+			if !slices.Contains(list, code) {
+				delete(set, code)
+			}
+		}
+	}
+	return set, true
+}
+
+// withGeoRollups fills the region, sub-region and continent breakdowns from
+// the country counts. Unknown and unlisted countries roll up to Unknown.
+func withGeoRollups(b Breakdown) Breakdown {
+	regions := map[string]int{}
+	subregions := map[string]int{}
+	continents := map[string]int{}
+	for _, c := range b.Countries {
+		cr := countryRegions[c.Name]
+		addCount(regions, orUnknownName(cr.Region), c.Count)
+		addCount(subregions, orUnknownName(cr.Subregion), c.Count)
+		addCount(continents, orUnknownName(cr.Continent), c.Count)
+	}
+	b.Regions = sortCounts(regions)
+	b.Subregions = sortCounts(subregions)
+	b.Continents = sortCounts(continents)
+	return b
+}
+
+func orUnknownName(s string) string {
+	if s == "" {
+		return "Unknown"
+	}
+	return s
+}
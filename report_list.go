@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReportsPageSize = 50
+	maxReportsPageSize     = 500
+)
+
+type ReportsPage struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Filter      StatsFilter    `json:"filter"`
+	Limit       int            `json:"limit"`
+	Reports     []StoredReport `json:"reports"`
+	// NextCursor is set when more reports follow; pass it back as ?cursor=.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// reportCursor is the keyset position of the last report on a page. Listings
+// are ordered by receivedAt descending with the fingerprint as tie-breaker.
+type reportCursor struct {
+	ReceivedAt  time.Time
+	Fingerprint string
+}
+
+func (c reportCursor) encode() string {
+	raw := strconv.FormatInt(c.ReceivedAt.UnixNano(), 10) + ":" + c.Fingerprint
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeReportCursor(s string) (*reportCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, fp, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &reportCursor{ReceivedAt: time.Unix(0, n).UTC(), Fingerprint: fp}, nil
+}
+
+// before reports whether sr sorts after the cursor position.
+func (c *reportCursor) before(sr StoredReport) bool {
+	if c == nil {
+		return true
+	}
+	if !sr.ReceivedAt.Equal(c.ReceivedAt) {
+		return sr.ReceivedAt.Before(c.ReceivedAt)
+	}
+	return sr.Fingerprint < c.Fingerprint
+}
+
+// parseReportsQuery reads ?cursor= and ?limit= for GET /api/reports.
+func parseReportsQuery(q url.Values) (*reportCursor, int, error) {
+	limit := defaultReportsPageSize
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid limit")
+		}
+		limit = v
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > maxReportsPageSize {
+		limit = maxReportsPageSize
+	}
+
+	var cursor *reportCursor
+	if raw := strings.TrimSpace(q.Get("cursor")); raw != "" {
+		c, err := decodeReportCursor(raw)
+		if err != nil {
+			return nil, 0, err
+		}
+		cursor = c
+	}
+	return cursor, limit, nil
+}
+
+// listStoredReports pages through an in-memory slice; backends without a native
+// keyset query use it.
+func listStoredReports(stored []StoredReport, filter StatsFilter, after *reportCursor, limit int) []StoredReport {
+	out := make([]StoredReport, 0, limit)
+	for _, sr := range stored {
+		if after.before(sr) && matchesStatsFilter(sr.Report, filter) {
+			out = append(out, sr)
+		}
+	}
+	sortStoredReportsDesc(out)
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func sortStoredReportsDesc(reports []StoredReport) {
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].ReceivedAt.Equal(reports[j].ReceivedAt) {
+			return reports[i].ReceivedAt.After(reports[j].ReceivedAt)
+		}
+		return reports[i].Fingerprint > reports[j].Fingerprint
+	})
+}
+
+func (s *Store) ListReports(now time.Time, filter StatsFilter, after *reportCursor, limit int) (ReportsPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Ask for one extra report to learn whether another page follows.
+	reports, err := s.reports.List(ctx, filter, after, limit+1)
+	if err != nil {
+		return ReportsPage{}, err
+	}
+	page := ReportsPage{
+		GeneratedAt: now,
+		Filter:      filter,
+		Limit:       limit,
+		Reports:     reports,
+	}
+	if len(reports) > limit {
+		page.Reports = reports[:limit]
+		last := page.Reports[limit-1]
+		page.NextCursor = reportCursor{ReceivedAt: last.ReceivedAt, Fingerprint: last.Fingerprint}.encode()
+	}
+	for i := range page.Reports {
+		page.Reports[i].IP = ""
+		page.Reports[i].Raw = nil
+	}
+	return page, nil
+}
+
+func (s *Store) GetReport(fingerprint string) (StoredReport, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	stored, ok, err := s.reports.Get(ctx, fingerprint)
+	if err != nil || !ok {
+		return StoredReport{}, ok, err
+	}
+	stored.IP = ""
+	stored.Raw = nil
+	return stored, true, nil
+}
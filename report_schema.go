@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reportFieldError is one validation failure, located by a dotted JSON path
+// (e.g. "webgpu.formats[3].format"); Field is empty for body-level errors.
+type reportFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type reportFieldErrors []reportFieldError
+
+func (e reportFieldErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		if fe.Field == "" {
+			parts = append(parts, fe.Message)
+			continue
+		}
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e reportFieldErrors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// asReportFieldErrors locates decode and validation errors where possible.
+func asReportFieldErrors(err error) reportFieldErrors {
+	var fieldErrs reportFieldErrors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return reportFieldErrors{{
+			Field:   jsonFieldPath(typeErr.Field),
+			Message: fmt.Sprintf("expected %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value),
+		}}
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return reportFieldErrors{{Message: fmt.Sprintf("%s (at byte %d)", syntaxErr, syntaxErr.Offset)}}
+	}
+	return reportFieldErrors{{Message: err.Error()}}
+}
+
+// jsonFieldPath rewrites encoding/json's "a.0.b" paths as "a[0].b".
+func jsonFieldPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// reportSchemaAnnotations carries the checks validateReport makes that the
+// Go types alone don't express.
+var reportSchemaAnnotations = map[string]func(*jsonSchema){
+	"Report.schemaVersion": func(s *jsonSchema) {
+		s.Description = "Detector schema version; omit or 0 for unversioned clients."
+		s.Minimum = ptr(int64(0))
+		s.Maximum = ptr(int64(currentReportSchemaVersion))
+	},
+	"Report.geo": func(s *jsonSchema) {
+		s.Description = "Ignored on submit; the server derives the country itself."
+	},
+	"ClientInfo.fingerprint": func(s *jsonSchema) {
+		s.Description = "Deduplication key; reports without one are keyed by a hash of the report."
+	},
+	"WebGPUReport.formats": func(s *jsonSchema) {
+		s.MaxItems = ptr(300)
+	},
+	"WebGPUFormat.format": func(s *jsonSchema) {
+		s.Description = "A GPUTextureFormat, or one of the WebGL-only etc1/pvrtc names."
+		s.Enum = textureFormatNames()
+	},
+	"WebGPUFormat.kind": func(s *jsonSchema) {
+		s.Description = "Recomputed by the server."
+	},
+	"WebGPUFormat.hdr": func(s *jsonSchema) {
+		s.Description = "Recomputed by the server except for ASTC, where it reports the HDR profile."
+	},
+	"WebGPUFormat.compressed": func(s *jsonSchema) {
+		s.Description = "Recomputed by the server."
+	},
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func textureFormatNames() []string {
+	names := make([]string, 0, len(textureFormatCatalog))
+	for name := range textureFormatCatalog {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// reportJSONSchema describes the body accepted by POST /api/report.
+func reportJSONSchema() *jsonSchema {
+	g := newJSONSchemaGenerator("#/$defs/", reportSchemaAnnotations)
+	root := g.schemaFor(reflect.TypeFor[Report]())
+	return &jsonSchema{
+		Schema:      "https://json-schema.org/draft/2020-12/schema",
+		Title:       "hdr-detection report",
+		Description: fmt.Sprintf("Body of POST /api/report (schemaVersion %d). Unknown properties are ignored.", currentReportSchemaVersion),
+		Ref:         root.Ref,
+		Defs:        g.defs,
+	}
+}
+
+// reportSchemaResponse is encoded once; the schema only changes with the binary.
+var reportSchemaResponse = sync.OnceValues(func() (cachedResponse, error) {
+	return encodeCachedResponse(time.Now(), func() (any, error) {
+		return reportJSONSchema(), nil
+	})
+})
+
+func handleReportSchema(w http.ResponseWriter, r *http.Request) {
+	resp, err := reportSchemaResponse()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "schema unavailable", "details": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	writeCachedJSON(w, r, resp)
+}
+
+type ValidateResponse struct {
+	Valid       bool               `json:"valid"`
+	Errors      []reportFieldError `json:"errors"`
+	Fingerprint string             `json:"fingerprint,omitempty"`
+	// Report is the body as it would be stored, after normalization and
+	// migrations, so probe authors can see what the server changed.
+	Report *Report `json:"report,omitempty"`
+}
+
+// handleValidate runs the checks of handleReport on a body without storing it
+// or touching the submission counters. It still takes a rate limit token.
+func handleValidate(w http.ResponseWriter, r *http.Request, store *Store) {
+	if !store.allowIP(time.Now(), clientIP(r)) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": "rate limited"})
+		return
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if ct != "" && !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{"error": "content-type must be application/json"})
+		return
+	}
+
+	body, err := decodedRequestBody(w, r, store.cfg.MaxBodyBytes)
+	if err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	defer body.Close()
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+
+	report, _, err := decodeReport(raw)
+	if err != nil {
+		writeJSON(w, http.StatusOK, ValidateResponse{Errors: asReportFieldErrors(err)})
+		return
+	}
+	report.Geo = nil
+	fingerprint := extractFingerprint(report)
+	if fingerprint == "" {
+		fingerprint = fallbackFingerprint(report)
+	}
+	writeJSON(w, http.StatusOK, ValidateResponse{
+		Valid:       true,
+		Errors:      []reportFieldError{},
+		Fingerprint: fingerprint,
+		Report:      &report,
+	})
+}
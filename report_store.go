@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportStore is the persistence backend behind Store. Implementations own
+// fingerprint uniqueness, dedupe-window merging and MaxReports pruning; Store
+// keeps the submission counters and rate limiting on top of it.
+type ReportStore interface {
+	// Name identifies the backend ("memory", "mongo", ...).
+	Name() string
+	// Ping reports whether the backend is reachable.
+	Ping(ctx context.Context) error
+	// Submit inserts the report or merges it into an existing record with the
+	// same fingerprint. raw is the gzip-compressed submitted JSON (may be nil)
+	// and always replaces the previously stored payload.
+	Submit(ctx context.Context, now time.Time, ip string, fingerprint string, report Report, raw []byte) (submitChange, error)
+	// Load returns the most recent stored reports (at most MaxReports) without
+	// their raw payloads.
+	Load(ctx context.Context) ([]StoredReport, error)
+	// Count returns the number of stored reports.
+	Count(ctx context.Context) (int, error)
+	// Prune evicts the oldest reports beyond MaxReports and returns them.
+	Prune(ctx context.Context) ([]Report, error)
+	// Get looks up a single report by fingerprint, including its raw payload.
+	Get(ctx context.Context, fingerprint string) (StoredReport, bool, error)
+	// List returns up to limit reports matching filter, newest receivedAt
+	// first, starting after the cursor (nil for the first page). Raw payloads
+	// are not included.
+	List(ctx context.Context, filter StatsFilter, after *reportCursor, limit int) ([]StoredReport, error)
+	// Export calls fn for every report matching filter, newest receivedAt
+	// first, without materializing the result set. Raw payloads are not
+	// included. A non-nil error from fn stops the iteration and is returned.
+	Export(ctx context.Context, filter StatsFilter, fn func(StoredReport) error) error
+	// Replace overwrites the stored report and its derived fields in place,
+	// keeping timestamps and the raw payload (used by `migrate`).
+	Replace(ctx context.Context, fingerprint string, report Report) error
+}
+
+// statsAggregator is implemented by backends that can compute the /api/stats
+// aggregates themselves. ok is false when the filter can't be expressed by the
+// backend, in which case Store falls back to computeStats over Load, which
+// remains the reference implementation.
+type statsAggregator interface {
+	AggregateStats(ctx context.Context, filter StatsFilter) (stats StatsResponse, ok bool, err error)
+	// CountDatacenter returns the number of stored reports flagged as
+	// datacenter traffic, for Totals.
+	CountDatacenter(ctx context.Context) (int, error)
+}
+
+// openReportStore selects a backend from a -store spec: "memory", "mongo" or
+// "sqlite:<path>". An empty spec keeps the historical behavior of using MongoDB
+// when a connection string is configured and memory otherwise.
+func openReportStore(ctx context.Context, spec string, mongoURI string, cfg Config) (ReportStore, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch strings.ToLower(kind) {
+	case "":
+		mongo, err := openAndInitMongo(ctx, mongoURI, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("mongo init: %w", err)
+		}
+		if mongo == nil {
+			return newMemoryReportStore(cfg), nil
+		}
+		return mongo, nil
+	case "memory":
+		return newMemoryReportStore(cfg), nil
+	case "mongo", "mongodb":
+		mongo, err := openAndInitMongo(ctx, mongoURI, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("mongo init: %w", err)
+		}
+		if mongo == nil {
+			return nil, fmt.Errorf("mongo store selected but no MONGO_URI configured")
+		}
+		return mongo, nil
+	case "sqlite":
+		sqlite, err := openAndInitSQLite(ctx, arg, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite init: %w", err)
+		}
+		return sqlite, nil
+	default:
+		return nil, fmt.Errorf("unknown store %q (want memory, mongo or sqlite:<path>)", spec)
+	}
+}
+
+type submitOutcome int
+
+const (
+	submitNew submitOutcome = iota
+	submitUpdated
+	submitDuplicate
+)
+
+// submitChange describes what Submit did to the stored set: the report now
+// stored under the fingerprint and the one it replaced, if any. Store uses it
+// to keep the incremental stats counters in step with the backend.
+type submitChange struct {
+	Outcome  submitOutcome
+	Previous *Report
+	Stored   Report
+}
+
+type memoryReportStore struct {
+	mu sync.Mutex
+
+	maxReports int
+	dedupeTTL  time.Duration
+
+	reports      map[string]StoredReport // by fingerprint
+	order        []string                // insertion order
+	lastSeenByFP map[string]time.Time
+}
+
+func newMemoryReportStore(cfg Config) *memoryReportStore {
+	return &memoryReportStore{
+		maxReports:   cfg.MaxReports,
+		dedupeTTL:    cfg.DedupeTTL,
+		reports:      make(map[string]StoredReport),
+		lastSeenByFP: make(map[string]time.Time),
+	}
+}
+
+func (m *memoryReportStore) Name() string { return "memory" }
+
+func (m *memoryReportStore) Ping(ctx context.Context) error { return nil }
+
+func (m *memoryReportStore) Submit(ctx context.Context, now time.Time, ip string, fingerprint string, report Report, raw []byte) (submitChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Prune drops lastSeenByFP together with the report, so a fingerprint
+	// inside the dedupe window is always still stored.
+	if lastSeen, ok := m.lastSeenByFP[fingerprint]; ok && now.Sub(lastSeen) < m.dedupeTTL {
+		m.lastSeenByFP[fingerprint] = now
+		existing := m.reports[fingerprint]
+		merged := mergeReportsPreferNew(report, existing.Report)
+		m.reports[fingerprint] = StoredReport{
+			Fingerprint: fingerprint,
+			CreatedAt:   existing.CreatedAt,
+			ReceivedAt:  now,
+			IP:          ip,
+			Report:      merged,
+			Raw:         raw,
+		}
+		return submitChange{Outcome: submitDuplicate, Previous: &existing.Report, Stored: merged}, nil
+	}
+
+	// Accept: replace old entry if exists.
+	if existing, ok := m.reports[fingerprint]; ok {
+		m.reports[fingerprint] = StoredReport{
+			Fingerprint: fingerprint,
+			CreatedAt:   existing.CreatedAt,
+			ReceivedAt:  now,
+			IP:          ip,
+			Report:      report,
+			Raw:         raw,
+		}
+		m.lastSeenByFP[fingerprint] = now
+		return submitChange{Outcome: submitUpdated, Previous: &existing.Report, Stored: report}, nil
+	}
+
+	m.reports[fingerprint] = StoredReport{
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ReceivedAt:  now,
+		IP:          ip,
+		Report:      report,
+		Raw:         raw,
+	}
+	m.order = append(m.order, fingerprint)
+	m.lastSeenByFP[fingerprint] = now
+	return submitChange{Outcome: submitNew, Stored: report}, nil
+}
+
+func (m *memoryReportStore) Load(ctx context.Context) ([]StoredReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]StoredReport, 0, len(m.reports))
+	for _, stored := range m.reports {
+		stored.Raw = nil
+		out = append(out, stored)
+	}
+	return out, nil
+}
+
+func (m *memoryReportStore) Count(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.reports), nil
+}
+
+func (m *memoryReportStore) Prune(ctx context.Context) ([]Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.maxReports <= 0 {
+		return nil, nil
+	}
+	var evicted []Report
+	for len(m.order) > m.maxReports {
+		oldest := m.order[0]
+		m.order = m.order[1:]
+		if stored, ok := m.reports[oldest]; ok {
+			evicted = append(evicted, stored.Report)
+		}
+		delete(m.reports, oldest)
+		delete(m.lastSeenByFP, oldest)
+	}
+	return evicted, nil
+}
+
+func (m *memoryReportStore) Get(ctx context.Context, fingerprint string) (StoredReport, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.reports[fingerprint]
+	return stored, ok, nil
+}
+
+func (m *memoryReportStore) Replace(ctx context.Context, fingerprint string, report Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.reports[fingerprint]
+	if !ok {
+		return fmt.Errorf("replace report: %s not found", fingerprint)
+	}
+	stored.Report = report
+	m.reports[fingerprint] = stored
+	return nil
+}
+
+func (m *memoryReportStore) List(ctx context.Context, filter StatsFilter, after *reportCursor, limit int) ([]StoredReport, error) {
+	stored, err := m.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return listStoredReports(stored, filter, after, limit), nil
+}
+
+func (m *memoryReportStore) Export(ctx context.Context, filter StatsFilter, fn func(StoredReport) error) error {
+	stored, err := m.Load(ctx)
+	if err != nil {
+		return err
+	}
+	sortStoredReportsDesc(stored)
+	for _, sr := range stored {
+		if !matchesStatsFilter(sr.Report, filter) {
+			continue
+		}
+		if err := fn(sr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
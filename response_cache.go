@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCache keeps encoded /api/stats and /api/compat bodies keyed by the
+// endpoint and its normalized query. Entries are dropped when a submission
+// changes the stored reports and expire after ttl, which bounds staleness of
+// the submission counters and of writes made by other instances.
+type responseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	generation uint64
+	entries    map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	body     []byte
+	etag     string
+	storedAt time.Time
+	// gzip and br hold the precompressed body (nil below minCompressBytes).
+	gzip []byte
+	br   []byte
+}
+
+// maxCachedResponses bounds memory when clients vary filters freely.
+const maxCachedResponses = 512
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+// invalidate drops every entry; responses computed concurrently with the
+// change are not stored (see get).
+func (c *responseCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation += 1
+	clear(c.entries)
+}
+
+// get returns the cached body for key or computes, encodes and stores it.
+func (c *responseCache) get(now time.Time, key string, compute func() (any, error)) (cachedResponse, error) {
+	if c == nil || c.ttl <= 0 {
+		return encodeCachedResponse(now, compute)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && now.Sub(entry.storedAt) < c.ttl {
+		return entry, nil
+	}
+
+	entry, err := encodeCachedResponse(now, compute)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		if len(c.entries) >= maxCachedResponses {
+			for k, e := range c.entries {
+				if now.Sub(e.storedAt) >= c.ttl {
+					delete(c.entries, k)
+				}
+			}
+			if len(c.entries) >= maxCachedResponses {
+				clear(c.entries)
+			}
+		}
+		c.entries[key] = entry
+	}
+	return entry, nil
+}
+
+func encodeCachedResponse(now time.Time, compute func() (any, error)) (cachedResponse, error) {
+	v, err := compute()
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return cachedResponse{}, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	resp := cachedResponse{
+		body:     buf.Bytes(),
+		etag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		storedAt: now,
+	}
+	if len(resp.body) >= minCompressBytes {
+		resp.gzip = compressBytes(resp.body, "gzip")
+		resp.br = compressBytes(resp.body, "br")
+	}
+	return resp, nil
+}
+
+// responseCacheKey builds a key from the endpoint and its normalized options.
+func responseCacheKey(endpoint string, parts ...any) string {
+	var b strings.Builder
+	b.WriteString(endpoint)
+	for _, p := range parts {
+		raw, _ := json.Marshal(p)
+		b.WriteByte('|')
+		b.Write(raw)
+	}
+	return b.String()
+}
+
+// writeCachedJSON replaces the API's default no-store policy with revalidation
+// against the ETag and answers matching If-None-Match with 304. Compressed
+// variants get their own strong ETag, as their bytes differ; a validator of
+// any variant revalidates the entry.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, resp cachedResponse) {
+	body, etag := resp.body, resp.etag
+	switch negotiateEncoding(r) {
+	case "br":
+		if resp.br != nil {
+			body, etag = resp.br, encodedETag(resp.etag, "br")
+			w.Header().Set("Content-Encoding", "br")
+		}
+	case "gzip":
+		if resp.gzip != nil {
+			body, etag = resp.gzip, encodedETag(resp.etag, "gz")
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), resp.etag, encodedETag(resp.etag, "br"), encodedETag(resp.etag, "gz")) {
+		w.Header().Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// encodedETag derives the validator of a compressed variant, e.g. "<hash>-br".
+func encodedETag(etag, suffix string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + suffix + `"`
+}
+
+// etagMatches implements the weak comparison If-None-Match calls for against
+// any of etags.
+func etagMatches(header string, etags ...string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if slices.Contains(etags, candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCachedJSONETags(t *testing.T) {
+	resp, err := encodeCachedResponse(time.Now(), func() (any, error) {
+		return map[string]string{"pad": strings.Repeat("x", 2*minCompressBytes)}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.Trim(resp.etag, `"`)
+
+	serve := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		writeCachedJSON(w, r, resp)
+		return w
+	}
+
+	etags := map[string]string{}
+	for _, tt := range []struct{ acceptEncoding, encoding, etag string }{
+		{"", "", `"` + hash + `"`},
+		{"gzip", "gzip", `"` + hash + `-gz"`},
+		{"br, gzip;q=0.5", "br", `"` + hash + `-br"`},
+	} {
+		w := serve(tt.acceptEncoding, "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != tt.encoding || w.Header().Get("ETag") != tt.etag {
+			t.Errorf("Accept-Encoding %q: %d, encoding %q, etag %s; want 200, %q, %s",
+				tt.acceptEncoding, w.Code, w.Header().Get("Content-Encoding"), w.Header().Get("ETag"), tt.encoding, tt.etag)
+		}
+		etags[tt.acceptEncoding] = w.Header().Get("ETag")
+	}
+
+	// Any variant's validator revalidates the entry, whichever encoding the
+	// client now negotiates.
+	for _, etag := range etags {
+		for acceptEncoding := range etags {
+			if w := serve(acceptEncoding, etag); w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("If-None-Match %s with Accept-Encoding %q: %d, encoding %q; want 304 without encoding",
+					etag, acceptEncoding, w.Code, w.Header().Get("Content-Encoding"))
+			}
+		}
+	}
+	for _, ifNoneMatch := range []string{`W/"` + hash + `-br"`, `"other", "` + hash + `-gz"`, "*"} {
+		if w := serve("gzip", ifNoneMatch); w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: %d, want 304", ifNoneMatch, w.Code)
+		}
+	}
+	for _, ifNoneMatch := range []string{`"other"`, `"` + hash + `-zstd"`} {
+		if w := serve("gzip", ifNoneMatch); w.Code != http.StatusOK {
+			t.Errorf("If-None-Match %s: %d, want 200", ifNoneMatch, w.Code)
+		}
+	}
+}
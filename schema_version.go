@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// currentReportSchemaVersion is the Report.SchemaVersion sent by this build of
+// the detector (app.js). Reports without one predate versioning and count as 1.
+//
+//	1: unversioned reports.
+//	2: WebGL compressed formats are always reported by constant name.
+//	3: WebGPU format entries are checked against textureFormatCatalog.
+const currentReportSchemaVersion = 3
+
+// reportMigration upgrades reports produced before Version. Apply must be
+// idempotent and reports whether it changed anything; SchemaVersion itself is
+// left alone, as it records which detector produced the data.
+type reportMigration struct {
+	Version     int
+	Description string
+	Apply       func(r *Report) bool
+}
+
+// reportMigrations is ordered by Version.
+var reportMigrations = []reportMigration{
+	{
+		Version:     2,
+		Description: "rename raw WebGL compressed texture enums (0x8c00...) to their constant names",
+		Apply:       migrateWebGLCompressedFormatNames,
+	},
+	{
+		Version:     3,
+		Description: "recompute WebGPU format kind/hdr/compressed from the catalog and drop unknown formats",
+		Apply:       migrateWebGPUFormatsToCatalog,
+	},
+}
+
+func reportSchemaVersion(r Report) int {
+	if r.SchemaVersion <= 0 {
+		return 1
+	}
+	return r.SchemaVersion
+}
+
+// migrateReport applies every migration newer than the report's schema.
+func migrateReport(r *Report) bool {
+	version := reportSchemaVersion(*r)
+	changed := false
+	for _, m := range reportMigrations {
+		if version < m.Version && m.Apply(r) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func migrateWebGLCompressedFormatNames(r *Report) bool {
+	changed := false
+	for _, gl := range []*WebGLReport{&r.WebGL2, &r.WebGL1} {
+		seen := make(map[string]bool, len(gl.CompressedFormats))
+		out := make([]string, 0, len(gl.CompressedFormats))
+		for _, cf := range gl.CompressedFormats {
+			name := normalizeWebGLCompressedFormat(cf)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			out = append(out, name)
+		}
+		if !slices.Equal(out, gl.CompressedFormats) && len(gl.CompressedFormats) > 0 {
+			gl.CompressedFormats = out
+			changed = true
+		}
+	}
+	return changed
+}
+
+// runMigrateCommand implements `hdr-detection migrate`, which persists the
+// read-time migrations so backend-side aggregation sees upgraded documents.
+func runMigrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	mongoURI := fs.String("mongo-uri", strings.TrimSpace(firstEnv("MONGO_URI", "MONGODB_URI")), "MongoDB connection string (env MONGO_URI/MONGODB_URI)")
+	storeSpec := fs.String("store", strings.TrimSpace(os.Getenv("STORE")), "report store: mongo or sqlite:<path> (env STORE; default mongo when MONGO_URI is set)")
+	dryRun := fs.Bool("dry-run", false, "only report how many documents would be rewritten")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cfg := Config{MaxReports: 2000}
+	reports, err := openReportStore(ctx, *storeSpec, *mongoURI, cfg)
+	if err != nil {
+		return fmt.Errorf("store init: %w", err)
+	}
+	if reports.Name() == "memory" {
+		return fmt.Errorf("nothing to migrate in the in-memory store; set -store or -mongo-uri")
+	}
+
+	for _, m := range reportMigrations {
+		fmt.Fprintf(os.Stderr, "v%d: %s\n", m.Version, m.Description)
+	}
+
+	scanned, rewritten := 0, 0
+	var after *reportCursor
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		page, err := reports.List(ctx, StatsFilter{}, after, maxReportsPageSize)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("migrate: %w", err)
+		}
+		for _, sr := range page {
+			scanned += 1
+			if !sr.Migrated {
+				continue
+			}
+			rewritten += 1
+			if *dryRun {
+				continue
+			}
+			if err := reports.Replace(ctx, sr.Fingerprint, sr.Report); err != nil {
+				cancel()
+				return fmt.Errorf("migrate %s: %w", sr.Fingerprint, err)
+			}
+		}
+		cancel()
+		if len(page) < maxReportsPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		after = &reportCursor{ReceivedAt: last.ReceivedAt, Fingerprint: last.Fingerprint}
+	}
+
+	verb := "Rewrote"
+	if *dryRun {
+		verb = "Would rewrite"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d of %d reports\n", verb, rewritten, scanned)
+	return nil
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+
+	maxReports int
+	dedupeTTL  time.Duration
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS reports (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	fingerprint      TEXT    NOT NULL UNIQUE,
+	created_at       INTEGER NOT NULL,
+	received_at      INTEGER NOT NULL,
+	browser          TEXT    NOT NULL DEFAULT '',
+	os               TEXT    NOT NULL DEFAULT '',
+	device_type      TEXT    NOT NULL DEFAULT '',
+	cpu_arch         TEXT    NOT NULL DEFAULT '',
+	country          TEXT    NOT NULL DEFAULT '',
+	apple_silicon    INTEGER,
+	webgpu_available INTEGER NOT NULL DEFAULT 0,
+	webgl2_available INTEGER NOT NULL DEFAULT 0,
+	webgl1_available INTEGER NOT NULL DEFAULT 0,
+	hdr_display      INTEGER NOT NULL DEFAULT 0,
+	gpu_vendor       TEXT    NOT NULL DEFAULT '',
+	gpu_architecture TEXT    NOT NULL DEFAULT '',
+	gpu_family       TEXT    NOT NULL DEFAULT '',
+	gpu_model        TEXT    NOT NULL DEFAULT '',
+	gpu_backend      TEXT    NOT NULL DEFAULT '',
+	schema_version   INTEGER NOT NULL DEFAULT 1,
+	as_org           TEXT    NOT NULL DEFAULT '',
+	datacenter       INTEGER NOT NULL DEFAULT 0,
+	report           TEXT    NOT NULL,
+	raw_report       BLOB
+);
+CREATE INDEX IF NOT EXISTS created_at_desc ON reports (created_at DESC);
+CREATE INDEX IF NOT EXISTS received_at_fingerprint_desc ON reports (received_at DESC, fingerprint DESC);
+CREATE INDEX IF NOT EXISTS browser ON reports (browser);
+CREATE INDEX IF NOT EXISTS os ON reports (os);
+CREATE INDEX IF NOT EXISTS country ON reports (country);
+CREATE INDEX IF NOT EXISTS device_type ON reports (device_type);
+CREATE INDEX IF NOT EXISTS cpu_arch ON reports (cpu_arch);
+CREATE INDEX IF NOT EXISTS apple_silicon ON reports (apple_silicon);
+CREATE INDEX IF NOT EXISTS webgpu_available ON reports (webgpu_available);
+CREATE INDEX IF NOT EXISTS webgl2_available ON reports (webgl2_available);
+CREATE INDEX IF NOT EXISTS webgl1_available ON reports (webgl1_available);
+CREATE INDEX IF NOT EXISTS hdr_display ON reports (hdr_display);
+`
+
+// sqliteAddedIndexes covers indexes on columns from sqliteAddedColumns; they
+// can only be created once the columns exist.
+const sqliteAddedIndexes = `
+CREATE INDEX IF NOT EXISTS gpu_vendor ON reports (gpu_vendor);
+CREATE INDEX IF NOT EXISTS gpu_architecture ON reports (gpu_architecture);
+CREATE INDEX IF NOT EXISTS gpu_family ON reports (gpu_family);
+CREATE INDEX IF NOT EXISTS schema_version ON reports (schema_version);
+CREATE INDEX IF NOT EXISTS datacenter ON reports (datacenter);
+`
+
+func openAndInitSQLite(ctx context.Context, path string, cfg Config) (*sqliteStore, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("sqlite: empty database path")
+	}
+
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "synchronous(NORMAL)")
+	dsn := "file:" + path + "?" + q.Encode()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite open: %w", err)
+	}
+	// SQLite allows a single writer; serialize access instead of retrying on SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	initCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := db.ExecContext(initCtx, sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sqlite init schema: %w", err)
+	}
+	if err := sqliteEnsureColumns(initCtx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{
+		db:         db,
+		path:       path,
+		maxReports: cfg.MaxReports,
+		dedupeTTL:  cfg.DedupeTTL,
+	}, nil
+}
+
+// sqliteAddedColumns lists columns introduced after the initial schema, so
+// databases created by older builds are upgraded in place.
+var sqliteAddedColumns = []struct {
+	Name string
+	Decl string
+}{
+	{Name: "raw_report", Decl: "BLOB"},
+	{Name: "gpu_vendor", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "gpu_architecture", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "gpu_family", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "gpu_model", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "gpu_backend", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "schema_version", Decl: "INTEGER NOT NULL DEFAULT 1"},
+	{Name: "as_org", Decl: "TEXT NOT NULL DEFAULT ''"},
+	{Name: "datacenter", Decl: "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func sqliteEnsureColumns(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_info('reports')`)
+	if err != nil {
+		return fmt.Errorf("sqlite table info: %w", err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("sqlite table info: %w", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sqlite table info: %w", err)
+	}
+
+	for _, col := range sqliteAddedColumns {
+		if existing[col.Name] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE reports ADD COLUMN "+col.Name+" "+col.Decl); err != nil {
+			return fmt.Errorf("sqlite add column %s: %w", col.Name, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, sqliteAddedIndexes); err != nil {
+		return fmt.Errorf("sqlite create indexes: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Name() string { return "sqlite" }
+
+func (s *sqliteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqliteStore) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reports`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count reports: %w", err)
+	}
+	return n, nil
+}
+
+func (s *sqliteStore) Load(ctx context.Context) ([]StoredReport, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT fingerprint, created_at, received_at, report, NULL FROM reports ORDER BY created_at DESC LIMIT ?`,
+		sqliteLimit(s.maxReports))
+	if err != nil {
+		return nil, fmt.Errorf("load reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]StoredReport, 0, 256)
+	for rows.Next() {
+		stored, err := scanSQLiteReport(rows)
+		if err != nil {
+			continue
+		}
+		reports = append(reports, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (s *sqliteStore) Get(ctx context.Context, fingerprint string) (StoredReport, bool, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT fingerprint, created_at, received_at, report, raw_report FROM reports WHERE fingerprint = ?`, fingerprint)
+	stored, err := scanSQLiteReport(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StoredReport{}, false, nil
+	}
+	if err != nil {
+		return StoredReport{}, false, fmt.Errorf("get report: %w", err)
+	}
+	return stored, true, nil
+}
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSQLiteReport reads a fingerprint, created_at, received_at, report,
+// raw_report row. A report that fails to decode is returned with only its
+// keys set, alongside the error.
+func scanSQLiteReport(row sqliteScanner) (StoredReport, error) {
+	var (
+		fingerprint string
+		createdAt   int64
+		receivedAt  int64
+		raw         string
+		rawReport   []byte
+	)
+	if err := row.Scan(&fingerprint, &createdAt, &receivedAt, &raw, &rawReport); err != nil {
+		return StoredReport{}, err
+	}
+	stored := StoredReport{
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Unix(0, createdAt).UTC(),
+		ReceivedAt:  time.Unix(0, receivedAt).UTC(),
+		Raw:         rawReport,
+	}
+	if err := json.Unmarshal([]byte(raw), &stored.Report); err != nil {
+		// Keep the keys so callers paging by them can step over the row.
+		stored.Report, stored.Raw = Report{}, nil
+		return stored, fmt.Errorf("decode report: %w", err)
+	}
+	stored.Migrated = migrateReport(&stored.Report)
+	return stored, nil
+}
+
+// List walks reports newest-first with a keyset query and applies the filter
+// in Go, stopping as soon as the page is full.
+func (s *sqliteStore) List(ctx context.Context, filter StatsFilter, after *reportCursor, limit int) ([]StoredReport, error) {
+	query := `SELECT fingerprint, created_at, received_at, report, NULL FROM reports`
+	var args []any
+	if after != nil {
+		query += ` WHERE received_at < ? OR (received_at = ? AND fingerprint < ?)`
+		n := after.ReceivedAt.UnixNano()
+		args = append(args, n, n, after.Fingerprint)
+	}
+	query += ` ORDER BY received_at DESC, fingerprint DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]StoredReport, 0, limit)
+	for rows.Next() && len(reports) < limit {
+		stored, err := scanSQLiteReport(rows)
+		if err != nil {
+			continue
+		}
+		if matchesStatsFilter(stored.Report, filter) {
+			reports = append(reports, stored)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reports: %w", err)
+	}
+	return reports, nil
+}
+
+// Export reads the table in keyset-paged batches rather than through one
+// open cursor: the store has a single connection, and fn runs at the pace of
+// the client downloading the export, so holding the cursor across fn would
+// block every submission until the download finished.
+func (s *sqliteStore) Export(ctx context.Context, filter StatsFilter, fn func(StoredReport) error) error {
+	var after *reportCursor
+	for {
+		batch, next, err := s.exportBatch(ctx, after)
+		if err != nil {
+			return err
+		}
+		for _, stored := range batch {
+			if !matchesStatsFilter(stored.Report, filter) {
+				continue
+			}
+			if err := fn(stored); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		after = next
+	}
+}
+
+// sqliteExportBatchSize is how many rows Export reads per query.
+const sqliteExportBatchSize = 500
+
+// exportBatch reads up to sqliteExportBatchSize rows after the cursor, newest
+// receivedAt first, skipping rows that fail to decode. next is the cursor for
+// the following batch, nil once the table is exhausted.
+func (s *sqliteStore) exportBatch(ctx context.Context, after *reportCursor) (batch []StoredReport, next *reportCursor, err error) {
+	query := `SELECT fingerprint, created_at, received_at, report, NULL FROM reports`
+	var args []any
+	if after != nil {
+		query += ` WHERE received_at < ? OR (received_at = ? AND fingerprint < ?)`
+		n := after.ReceivedAt.UnixNano()
+		args = append(args, n, n, after.Fingerprint)
+	}
+	query += ` ORDER BY received_at DESC, fingerprint DESC LIMIT ?`
+	args = append(args, sqliteExportBatchSize)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("export reports: %w", err)
+	}
+	defer rows.Close()
+
+	read := 0
+	var last StoredReport
+	for rows.Next() {
+		stored, err := scanSQLiteReport(rows)
+		if stored.Fingerprint == "" {
+			return nil, nil, fmt.Errorf("export reports: %w", err)
+		}
+		read += 1
+		last = stored
+		if err == nil {
+			batch = append(batch, stored)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate reports: %w", err)
+	}
+	if read == sqliteExportBatchSize {
+		next = &reportCursor{ReceivedAt: last.ReceivedAt, Fingerprint: last.Fingerprint}
+	}
+	return batch, next, nil
+}
+
+func (s *sqliteStore) Prune(ctx context.Context) ([]Report, error) {
+	if s.maxReports <= 0 {
+		return nil, nil
+	}
+
+	count, err := s.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if count <= s.maxReports {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, report FROM reports ORDER BY created_at ASC LIMIT ?`, count-s.maxReports)
+	if err != nil {
+		return nil, fmt.Errorf("prune select: %w", err)
+	}
+	var (
+		ids     []int64
+		evicted []Report
+	)
+	for rows.Next() {
+		var (
+			id  int64
+			raw string
+		)
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("prune scan: %w", err)
+		}
+		var report Report
+		if err := json.Unmarshal([]byte(raw), &report); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("decode report: %w", err)
+		}
+		migrateReport(&report)
+		ids = append(ids, id)
+		evicted = append(evicted, report)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("prune iterate: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM reports WHERE id = ?`, id); err != nil {
+			return nil, fmt.Errorf("prune delete: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return evicted, nil
+}
+
+func (s *sqliteStore) Submit(ctx context.Context, now time.Time, _ip string, fingerprint string, report Report, raw []byte) (submitChange, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return submitChange{}, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		existingReceivedAt int64
+		existingRaw        string
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT received_at, report FROM reports WHERE fingerprint = ?`, fingerprint).
+		Scan(&existingReceivedAt, &existingRaw)
+
+	change := submitChange{Outcome: submitNew, Stored: report}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := sqliteInsertReport(ctx, tx, now, fingerprint, report, raw); err != nil {
+			return submitChange{}, err
+		}
+	case err != nil:
+		return submitChange{}, fmt.Errorf("select receivedAt: %w", err)
+	default:
+		// Existing fingerprint: enforce dedupe window (but still update the record).
+		var existing Report
+		if err := json.Unmarshal([]byte(existingRaw), &existing); err != nil {
+			return submitChange{}, fmt.Errorf("decode existing report: %w", err)
+		}
+		// Upgrade before merging: Previous is subtracted from the stats
+		// counters, which only ever counted migrated reports.
+		migrateReport(&existing)
+		merged := mergeReportsPreferNew(report, existing)
+		if err := sqliteUpdateReport(ctx, tx, now, fingerprint, merged, raw); err != nil {
+			return submitChange{}, err
+		}
+		change = submitChange{Outcome: submitUpdated, Previous: &existing, Stored: merged}
+		if now.Sub(time.Unix(0, existingReceivedAt)) < s.dedupeTTL {
+			change.Outcome = submitDuplicate
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return submitChange{}, fmt.Errorf("commit: %w", err)
+	}
+	return change, nil
+}
+
+func sqliteInsertReport(ctx context.Context, tx *sql.Tx, now time.Time, fingerprint string, report Report, rawReport []byte) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	meta := reportMetaFromReport(report)
+	_, err = tx.ExecContext(ctx, `INSERT INTO reports (
+		fingerprint, created_at, received_at,
+		browser, os, device_type, cpu_arch, country,
+		gpu_vendor, gpu_architecture, gpu_family, gpu_model, gpu_backend, apple_silicon,
+		webgpu_available, webgl2_available, webgl1_available, hdr_display, schema_version,
+		as_org, datacenter,
+		report, raw_report
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fingerprint, now.UnixNano(), now.UnixNano(),
+		meta.Browser, meta.OS, meta.DeviceType, meta.CPUArch, meta.Country,
+		meta.GPUVendor, meta.GPUArchitecture, meta.GPUFamily, meta.GPUModel, meta.GPUBackend, sqliteNullBool(meta.AppleSilicon),
+		meta.WebGPUAvailable, meta.WebGL2Available, meta.WebGL1Available, meta.HDRDisplay, meta.SchemaVersion,
+		meta.ASOrg, meta.Datacenter,
+		string(raw), rawReport,
+	)
+	if err != nil {
+		return fmt.Errorf("insert report: %w", err)
+	}
+	return nil
+}
+
+func sqliteUpdateReport(ctx context.Context, tx *sql.Tx, now time.Time, fingerprint string, merged Report, rawReport []byte) error {
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	meta := reportMetaFromReport(merged)
+	// Like the Mongo backend, only overwrite string meta columns when the merged report has a value.
+	_, err = tx.ExecContext(ctx, `UPDATE reports SET
+		received_at = ?,
+		browser = COALESCE(NULLIF(?, ''), browser),
+		os = COALESCE(NULLIF(?, ''), os),
+		device_type = COALESCE(NULLIF(?, ''), device_type),
+		cpu_arch = COALESCE(NULLIF(?, ''), cpu_arch),
+		country = COALESCE(NULLIF(?, ''), country),
+		gpu_vendor = COALESCE(NULLIF(?, ''), gpu_vendor),
+		gpu_architecture = COALESCE(NULLIF(?, ''), gpu_architecture),
+		gpu_family = COALESCE(NULLIF(?, ''), gpu_family),
+		gpu_model = COALESCE(NULLIF(?, ''), gpu_model),
+		gpu_backend = COALESCE(NULLIF(?, ''), gpu_backend),
+		apple_silicon = COALESCE(?, apple_silicon),
+		webgpu_available = ?,
+		webgl2_available = ?,
+		webgl1_available = ?,
+		hdr_display = ?,
+		schema_version = ?,
+		as_org = COALESCE(NULLIF(?, ''), as_org),
+		datacenter = ?,
+		report = ?,
+		raw_report = ?
+	WHERE fingerprint = ?`,
+		now.UnixNano(),
+		meta.Browser, meta.OS, meta.DeviceType, meta.CPUArch, meta.Country,
+		meta.GPUVendor, meta.GPUArchitecture, meta.GPUFamily, meta.GPUModel, meta.GPUBackend,
+		sqliteNullBool(meta.AppleSilicon),
+		meta.WebGPUAvailable, meta.WebGL2Available, meta.WebGL1Available, meta.HDRDisplay, meta.SchemaVersion,
+		meta.ASOrg, meta.Datacenter,
+		string(raw), rawReport,
+		fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("update report: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Replace(ctx context.Context, fingerprint string, report Report) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	meta := reportMetaFromReport(report)
+	res, err := s.db.ExecContext(ctx, `UPDATE reports SET
+		browser = ?, os = ?, device_type = ?, cpu_arch = ?, country = ?,
+		gpu_vendor = ?, gpu_architecture = ?, gpu_family = ?, gpu_model = ?, gpu_backend = ?,
+		apple_silicon = ?,
+		webgpu_available = ?, webgl2_available = ?, webgl1_available = ?, hdr_display = ?,
+		schema_version = ?,
+		as_org = ?, datacenter = ?,
+		report = ?
+	WHERE fingerprint = ?`,
+		meta.Browser, meta.OS, meta.DeviceType, meta.CPUArch, meta.Country,
+		meta.GPUVendor, meta.GPUArchitecture, meta.GPUFamily, meta.GPUModel, meta.GPUBackend,
+		sqliteNullBool(meta.AppleSilicon),
+		meta.WebGPUAvailable, meta.WebGL2Available, meta.WebGL1Available, meta.HDRDisplay,
+		meta.SchemaVersion,
+		meta.ASOrg, meta.Datacenter,
+		string(raw),
+		fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("replace report: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("replace report: %s not found", fingerprint)
+	}
+	return nil
+}
+
+func sqliteNullBool(v *bool) sql.NullBool {
+	if v == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *v, Valid: true}
+}
+
+// sqliteLimit maps a non-positive MaxReports to SQLite's "no limit".
+func sqliteLimit(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
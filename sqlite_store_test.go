@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T, cfg Config) *sqliteStore {
+	t.Helper()
+	s, err := openAndInitSQLite(context.Background(), filepath.Join(t.TempDir(), "reports.db"), cfg)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+// TestSQLiteExportPages checks that Export walks every batch newest first,
+// skips rows it cannot decode, and leaves the single connection free while
+// fn runs (a slow download must not block submissions).
+func TestSQLiteExportPages(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t, Config{})
+
+	total := sqliteExportBatchSize*2 + 7
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := range total {
+		r := Report{Client: &ClientInfo{Parsed: &ClientParsed{Browser: &NameVersion{Name: "Chrome"}}}}
+		if i%3 == 0 {
+			r.Client.Parsed.Browser.Name = "Firefox"
+		}
+		// Pairs share a receivedAt so the fingerprint tie-break is exercised.
+		now := t0.Add(time.Duration(i/2) * time.Second)
+		if _, err := s.Submit(ctx, now, "", fmt.Sprintf("fp-%05d", i), r, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE reports SET report = '{' WHERE fingerprint = ?`, "fp-00601"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err := s.Export(ctx, StatsFilter{}, func(sr StoredReport) error {
+		if len(got)%sqliteExportBatchSize == 0 {
+			wctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			if _, err := s.Count(wctx); err != nil {
+				return fmt.Errorf("store blocked during export: %w", err)
+			}
+		}
+		got = append(got, sr.Fingerprint)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != total-1 {
+		t.Fatalf("exported %d reports, want %d", len(got), total-1)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] >= got[i-1] {
+			t.Fatalf("export order: %s after %s", got[i], got[i-1])
+		}
+	}
+	for _, fp := range got {
+		if fp == "fp-00601" {
+			t.Fatal("undecodable row exported")
+		}
+	}
+
+	firefox := 0
+	if err := s.Export(ctx, StatsFilter{Browser: "firefox"}, func(StoredReport) error {
+		firefox += 1
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (total + 2) / 3; firefox != want {
+		t.Fatalf("filtered export: %d reports, want %d", firefox, want)
+	}
+
+	stop := fmt.Errorf("stop")
+	n := 0
+	err = s.Export(ctx, StatsFilter{}, func(StoredReport) error {
+		n += 1
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Fatalf("stopping export: err %v after %d reports", err, n)
+	}
+}
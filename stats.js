@@ -14,6 +14,7 @@ const dom = {
   fWebgl2Available: document.getElementById("fWebgl2Available"),
   fWebgl1Available: document.getElementById("fWebgl1Available"),
   fAppleSilicon: document.getElementById("fAppleSilicon"),
+  fExcludeDatacenter: document.getElementById("fExcludeDatacenter"),
   fWebgpuFeature: document.getElementById("fWebgpuFeature"),
   fWebgl2Ext: document.getElementById("fWebgl2Ext"),
   fWebgl1Ext: document.getElementById("fWebgl1Ext"),
@@ -23,6 +24,7 @@ const dom = {
   acceptedCount: document.getElementById("acceptedCount"),
   duplicateCount: document.getElementById("duplicateCount"),
   rateLimitedCount: document.getElementById("rateLimitedCount"),
+  datacenterCount: document.getElementById("datacenterCount"),
   webgpuAvailableCount: document.getElementById("webgpuAvailableCount"),
   webgpuTestedCount: document.getElementById("webgpuTestedCount"),
   webglAvailability: document.getElementById("webglAvailability"),
@@ -237,6 +239,7 @@ function getSelectionParamsFromControls() {
   if (dom.fWebgpuAvailable?.checked) params.set("webgpuAvailable", "1");
   if (dom.fWebgl2Available?.checked) params.set("webgl2Available", "1");
   if (dom.fWebgl1Available?.checked) params.set("webgl1Available", "1");
+  if (dom.fExcludeDatacenter?.checked) params.set("excludeDatacenter", "1");
 
   const webgpuFeature = (dom.fWebgpuFeature?.value || "").trim();
   if (webgpuFeature) params.append("webgpuFeature", webgpuFeature);
@@ -266,6 +269,7 @@ function applyControlsFromQuery(q) {
   dom.fWebgpuAvailable.checked = truthy(get("webgpuAvailable"));
   dom.fWebgl2Available.checked = truthy(get("webgl2Available"));
   dom.fWebgl1Available.checked = truthy(get("webgl1Available"));
+  dom.fExcludeDatacenter.checked = truthy(get("excludeDatacenter"));
 
   dom.fWebgpuFeature.value = get("webgpuFeature");
   dom.fWebgl2Ext.value = get("webgl2Ext");
@@ -298,6 +302,7 @@ function clearSelectionFilter(key) {
   else if (key === "webgpuAvailable") dom.fWebgpuAvailable.checked = false;
   else if (key === "webgl2Available") dom.fWebgl2Available.checked = false;
   else if (key === "webgl1Available") dom.fWebgl1Available.checked = false;
+  else if (key === "excludeDatacenter") dom.fExcludeDatacenter.checked = false;
   else if (key === "webgpuFeature") dom.fWebgpuFeature.value = "";
   else if (key === "webgl2Ext") dom.fWebgl2Ext.value = "";
   else if (key === "webgl1Ext") dom.fWebgl1Ext.value = "";
@@ -326,6 +331,7 @@ function renderActiveFilterChips() {
   if (params.get("webgpuAvailable")) add("webgpuAvailable", "WebGPU available");
   if (params.get("webgl2Available")) add("webgl2Available", "WebGL2 available");
   if (params.get("webgl1Available")) add("webgl1Available", "WebGL1 available");
+  if (params.get("excludeDatacenter")) add("excludeDatacenter", "No datacenter traffic");
   if (params.get("webgpuFeature")) add("webgpuFeature", `WebGPU feature: ${params.get("webgpuFeature")}`);
   if (params.get("webgl2Ext")) add("webgl2Ext", `WebGL2 ext: ${params.get("webgl2Ext")}`);
   if (params.get("webgl1Ext")) add("webgl1Ext", `WebGL1 ext: ${params.get("webgl1Ext")}`);
@@ -356,6 +362,7 @@ function captureStateFromControls() {
       webgpuAvailable: Boolean(dom.fWebgpuAvailable?.checked),
       webgl2Available: Boolean(dom.fWebgl2Available?.checked),
       webgl1Available: Boolean(dom.fWebgl1Available?.checked),
+      excludeDatacenter: Boolean(dom.fExcludeDatacenter?.checked),
       webgpuFeature: dom.fWebgpuFeature?.value || "",
       webgl2Ext: dom.fWebgl2Ext?.value || "",
       webgl1Ext: dom.fWebgl1Ext?.value || "",
@@ -454,6 +461,7 @@ function applyControlsFromState(state, { applySelection = true, applyLocal = tru
     dom.fWebgpuAvailable.checked = Boolean(sel.webgpuAvailable);
     dom.fWebgl2Available.checked = Boolean(sel.webgl2Available);
     dom.fWebgl1Available.checked = Boolean(sel.webgl1Available);
+    dom.fExcludeDatacenter.checked = Boolean(sel.excludeDatacenter);
     dom.fWebgpuFeature.value = String(sel.webgpuFeature || "");
     dom.fWebgl2Ext.value = String(sel.webgl2Ext || "");
     dom.fWebgl1Ext.value = String(sel.webgl1Ext || "");
@@ -531,6 +539,7 @@ function renderOverview(stats) {
   dom.acceptedCount.textContent = String(totals.accepted ?? "-");
   dom.duplicateCount.textContent = String(totals.duplicates ?? "-");
   dom.rateLimitedCount.textContent = String(totals.rateLimited ?? "-");
+  dom.datacenterCount.textContent = totals.stored == null ? "-" : formatCountAndPct(Number(totals.datacenter || 0), Number(totals.stored));
   dom.uptime.textContent = formatUptime(Number(stats?.uptimeSec || 0));
 
   const webgpuAvail = Number(stats?.webgpu?.availableCount || 0);
@@ -820,6 +829,7 @@ function clearSelectionControls() {
   dom.fWebgl2Available.checked = false;
   dom.fWebgl1Available.checked = false;
   dom.fAppleSilicon.checked = false;
+  dom.fExcludeDatacenter.checked = false;
 
   dom.fWebgpuFeature.value = "";
   dom.fWebgl2Ext.value = "";
@@ -879,6 +889,7 @@ dom.fWebgpuAvailable.addEventListener("change", () => refresh());
 dom.fWebgl2Available.addEventListener("change", () => refresh());
 dom.fWebgl1Available.addEventListener("change", () => refresh());
 dom.fAppleSilicon.addEventListener("change", () => refresh());
+dom.fExcludeDatacenter.addEventListener("change", () => refresh());
 
 let textDebounce = null;
 const queueRefresh = () => {
@@ -934,6 +945,7 @@ dom.hdrOnly.addEventListener("change", updateLocalViews);
     "webgpuAvailable",
     "webgl2Available",
     "webgl1Available",
+    "excludeDatacenter",
     "webgpuFeature",
     "webgl2Ext",
     "webgl1Ext",
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statsAccumulator holds the running /api/stats aggregates. add(r, 1) counts a
+// report and add(r, -1) exactly undoes it, so the same type serves both the
+// per-request computeStats pass and the incremental counters kept by Store.
+type statsAccumulator struct {
+	matched int
+	// datacenter counts matched reports flagged as datacenter traffic; it
+	// feeds Totals rather than the response sections.
+	datacenter int
+
+	browserCounts   map[string]int
+	osCounts        map[string]int
+	countryCounts   map[string]int
+	deviceCounts    map[string]int
+	cpuCounts       map[string]int
+	gpuVendorCounts map[string]int
+	gpuArchCounts   map[string]int
+
+	formats         map[string]*formatAccumulator
+	webgpuLimits    webgpuLimitsCounter
+	webgpuAvailable int
+	webgpuTested    int
+
+	webgl2 webglAccumulator
+	webgl1 webglAccumulator
+
+	dynamicRangeHigh int
+	colorRec2020     int
+	colorP3          int
+	hdrVideoTested   int
+	hdrVideoStats    []HDRVideoCodecStat
+}
+
+// formatAccumulator keeps per-format counters plus the votes behind the
+// static-ish metadata, so Kind/Compressed can be recomputed after removals.
+type formatAccumulator struct {
+	stat       FormatStat
+	kinds      map[string]int
+	compressed int
+}
+
+type webglAccumulator struct {
+	available        int
+	extCounts        map[string]int
+	compressedCounts map[string]int
+	limits           webglLimitsCounter
+	tests            WebGLTextureTestStats
+	anisoSupported   int
+	anisoCounts      map[string]int
+}
+
+func newWebGLAccumulator() webglAccumulator {
+	return webglAccumulator{
+		extCounts:        map[string]int{},
+		compressedCounts: map[string]int{},
+		limits:           newWebGLLimitsCounter(),
+		anisoCounts:      map[string]int{},
+	}
+}
+
+func newStatsAccumulator() *statsAccumulator {
+	acc := &statsAccumulator{
+		browserCounts:   map[string]int{},
+		osCounts:        map[string]int{},
+		countryCounts:   map[string]int{},
+		deviceCounts:    map[string]int{},
+		cpuCounts:       map[string]int{},
+		gpuVendorCounts: map[string]int{},
+		gpuArchCounts:   map[string]int{},
+		formats:         map[string]*formatAccumulator{},
+		webgpuLimits:    newWebGPULimitsCounter(),
+		webgl2:          newWebGLAccumulator(),
+		webgl1:          newWebGLAccumulator(),
+		hdrVideoStats:   make([]HDRVideoCodecStat, len(hdrVideoCodecs)),
+	}
+	for i, c := range hdrVideoCodecs {
+		acc.hdrVideoStats[i].Codec = c.Key
+	}
+	return acc
+}
+
+// add counts r with the given weight (1 to add, -1 to remove).
+func (acc *statsAccumulator) add(r Report, delta int) {
+	acc.matched += delta
+
+	// Client breakdowns
+	if r.Client != nil && r.Client.Parsed != nil {
+		if r.Client.Parsed.Browser != nil && r.Client.Parsed.Browser.Name != "" {
+			addCount(acc.browserCounts, r.Client.Parsed.Browser.Name, delta)
+		}
+		if r.Client.Parsed.OS != nil && r.Client.Parsed.OS.Name != "" {
+			addCount(acc.osCounts, r.Client.Parsed.OS.Name, delta)
+		}
+		if r.Client.Parsed.Device != nil && r.Client.Parsed.Device.Type != "" {
+			addCount(acc.deviceCounts, r.Client.Parsed.Device.Type, delta)
+		}
+
+		if arch := reportCPUArch(r); arch != "" {
+			addCount(acc.cpuCounts, arch, delta)
+		}
+	} else if r.UserAgent != "" {
+		addCount(acc.browserCounts, "Unknown", delta)
+		addCount(acc.osCounts, "Unknown", delta)
+		addCount(acc.deviceCounts, "Unknown", delta)
+		addCount(acc.cpuCounts, "Unknown", delta)
+	}
+
+	// GPU breakdowns
+	if vendor := reportGPUVendor(r); vendor != "" {
+		addCount(acc.gpuVendorCounts, vendor, delta)
+	} else {
+		addCount(acc.gpuVendorCounts, "Unknown", delta)
+	}
+	if arch := reportGPUArchitecture(r); arch != "" {
+		addCount(acc.gpuArchCounts, arch, delta)
+	} else {
+		addCount(acc.gpuArchCounts, "Unknown", delta)
+	}
+
+	// Geo
+	if r.Geo != nil && r.Geo.CountryCode != "" {
+		addCount(acc.countryCounts, r.Geo.CountryCode, delta)
+	} else {
+		addCount(acc.countryCounts, "Unknown", delta)
+	}
+	if r.Geo != nil && r.Geo.Datacenter {
+		acc.datacenter += delta
+	}
+
+	// Display
+	if r.Display != nil {
+		if r.Display.DynamicRangeHigh {
+			acc.dynamicRangeHigh += delta
+		}
+		if r.Display.ColorGamutRec2020 {
+			acc.colorRec2020 += delta
+		}
+		if r.Display.ColorGamutP3 {
+			acc.colorP3 += delta
+		}
+		if r.Display.HDRVideoDecoding != nil && r.Display.HDRVideoDecoding.Available {
+			acc.hdrVideoTested += delta
+		}
+	}
+	for i, c := range hdrVideoCodecs {
+		res, ok := reportHDRVideoCodec(r, c.Key)
+		if !ok {
+			continue
+		}
+		stat := &acc.hdrVideoStats[i]
+		stat.Tested += delta
+		if res.Supported {
+			stat.Supported += delta
+		}
+		if res.Smooth {
+			stat.Smooth += delta
+		}
+		if res.PowerEfficient {
+			stat.PowerEfficient += delta
+		}
+	}
+
+	// WebGPU format list (may be derived from WebGL when WebGPU is blocked/unavailable).
+	if r.WebGPU.Available {
+		acc.webgpuAvailable += delta
+		acc.webgpuLimits.add(r.WebGPU.Limits, delta)
+	}
+	if len(r.WebGPU.Formats) > 0 {
+		acc.webgpuTested += delta
+		for _, f := range r.WebGPU.Formats {
+			acc.addFormat(f, delta)
+		}
+	}
+
+	acc.webgl2.add(r.WebGL2, delta)
+	acc.webgl1.add(r.WebGL1, delta)
+}
+
+func (acc *statsAccumulator) addFormat(f WebGPUFormat, delta int) {
+	fa := acc.formats[f.Format]
+	if fa == nil {
+		fa = &formatAccumulator{stat: FormatStat{Format: f.Format}, kinds: map[string]int{}}
+		acc.formats[f.Format] = fa
+	}
+	stat := &fa.stat
+	stat.Tested += delta
+	if f.Kind != "" {
+		addCount(fa.kinds, f.Kind, delta)
+	}
+	if f.Compressed {
+		fa.compressed += delta
+	}
+	if f.HDR {
+		stat.HDRCount += delta
+	}
+	if f.Sampled {
+		stat.Sampled += delta
+	}
+	if f.Filterable != nil && *f.Filterable {
+		stat.Filterable += delta
+	}
+	if f.Renderable {
+		stat.Renderable += delta
+	}
+	if f.Storage {
+		stat.Storage += delta
+	}
+	if f.Sampled || f.Renderable || f.Storage {
+		stat.Any += delta
+	}
+	if stat.Tested == 0 {
+		delete(acc.formats, f.Format)
+	}
+}
+
+func (acc *webglAccumulator) add(gl WebGLReport, delta int) {
+	if !gl.Available {
+		return
+	}
+	acc.available += delta
+	for k, v := range gl.Extensions {
+		if v {
+			addCount(acc.extCounts, k, delta)
+		}
+	}
+	seen := make(map[string]bool, len(gl.CompressedFormats))
+	for _, cf := range gl.CompressedFormats {
+		if name := normalizeWebGLCompressedFormat(cf); name != "" && !seen[name] {
+			seen[name] = true
+			addCount(acc.compressedCounts, name, delta)
+		}
+	}
+	if gl.Limits != nil {
+		acc.limits.add(gl.Limits, delta)
+	}
+	if gl.TextureTests != nil {
+		if gl.TextureTests.FloatTexture {
+			acc.tests.FloatTexture += delta
+		}
+		if gl.TextureTests.HalfFloatTexture {
+			acc.tests.HalfFloatTexture += delta
+		}
+		if gl.TextureTests.FloatRenderable {
+			acc.tests.FloatRenderable += delta
+		}
+		if gl.TextureTests.HalfFloatRenderable {
+			acc.tests.HalfFloatRenderable += delta
+		}
+	}
+	if gl.Anisotropy != nil && gl.Anisotropy.Supported {
+		acc.anisoSupported += delta
+		if gl.Anisotropy.Max != nil {
+			addCount(acc.anisoCounts, fmt.Sprintf("%d", int(*gl.Anisotropy.Max+0.5)), delta)
+		}
+	}
+}
+
+func (acc *webglAccumulator) stats() WebGLContextStats {
+	return WebGLContextStats{
+		AvailableCount:    acc.available,
+		Extensions:        sortCounts(acc.extCounts),
+		CompressedFormats: sortCounts(acc.compressedCounts),
+		Limits:            acc.limits.stats(acc.available),
+		TextureTests:      acc.tests,
+		Anisotropy: WebGLAnisotropyStats{
+			Supported: acc.anisoSupported,
+			Max:       sortCounts(acc.anisoCounts),
+		},
+	}
+}
+
+// formatStats resolves the per-format metadata from textureFormatCatalog.
+// Formats outside the catalog (only in reports stored before it was enforced)
+// fall back to "any report says yes" and the most reported Kind.
+func (acc *statsAccumulator) formatStats() []FormatStat {
+	m := make(map[string]*FormatStat, len(acc.formats))
+	for name, fa := range acc.formats {
+		stat := fa.stat
+		stat.HDR = stat.HDRCount > 0
+		stat.Compressed = fa.compressed > 0
+		for _, kind := range sortCounts(fa.kinds) {
+			stat.Kind = kind.Name
+			break
+		}
+		formatStatMetadata(&stat)
+		m[name] = &stat
+	}
+	return sortFormatStats(m)
+}
+
+func (acc *statsAccumulator) response(now time.Time, startedAt time.Time, totals Totals, filter StatsFilter) StatsResponse {
+	hdrVideoStats := make([]HDRVideoCodecStat, len(acc.hdrVideoStats))
+	copy(hdrVideoStats, acc.hdrVideoStats)
+
+	return StatsResponse{
+		GeneratedAt: now,
+		UptimeSec:   int64(now.Sub(startedAt).Seconds()),
+		Totals:      totals,
+		Selection: Selection{
+			Matched: acc.matched,
+			Filter:  filter,
+		},
+		Breakdown: withGeoRollups(Breakdown{
+			Browsers:         sortCounts(acc.browserCounts),
+			OS:               sortCounts(acc.osCounts),
+			Countries:        sortCounts(acc.countryCounts),
+			DeviceTypes:      sortCounts(acc.deviceCounts),
+			CPUArch:          sortCounts(acc.cpuCounts),
+			GPUVendors:       sortCounts(acc.gpuVendorCounts),
+			GPUArchitectures: sortCounts(acc.gpuArchCounts),
+		}),
+		WebGPU: WebGPUStats{
+			AvailableCount: acc.webgpuAvailable,
+			TestedCount:    acc.webgpuTested,
+			Limits:         acc.webgpuLimits.stats(),
+			Formats:        acc.formatStats(),
+		},
+		WebGL: WebGLStats{
+			WebGL2: acc.webgl2.stats(),
+			WebGL1: acc.webgl1.stats(),
+		},
+		Display: DisplayStats{
+			DynamicRangeHigh:  acc.dynamicRangeHigh,
+			ColorGamutRec2020: acc.colorRec2020,
+			ColorGamutP3:      acc.colorP3,
+			HDRVideoTested:    acc.hdrVideoTested,
+			HDRVideoCodecs:    hdrVideoStats,
+		},
+	}
+}
+
+// statsCounters are the incrementally maintained aggregates behind unfiltered
+// /api/stats. They are seeded from a full Load on first use and then adjusted
+// on every submission: the replaced report (if any) is removed, the stored one
+// added, and reports evicted by pruning removed. Counters only see writes made
+// through this process, so CheckStatsCounters periodically compares them with
+// a full recompute and resyncs on drift (e.g. several instances sharing one
+// MongoDB database).
+type statsCounters struct {
+	ready bool
+	acc   *statsAccumulator
+}
+
+// loadStatsCountersLocked seeds the counters from the backend. s.countersMu
+// must be held.
+func (s *Store) loadStatsCountersLocked(ctx context.Context) error {
+	stored, err := s.reports.Load(ctx)
+	if err != nil {
+		return err
+	}
+	acc := newStatsAccumulator()
+	for _, sr := range stored {
+		acc.add(sr.Report, 1)
+	}
+	s.counters = statsCounters{ready: true, acc: acc}
+	return nil
+}
+
+// applySubmitLocked folds a backend change into the counters. s.countersMu
+// must be held; counters that were never seeded are left alone.
+func (s *Store) applySubmitLocked(change submitChange, evicted []Report) {
+	if !s.counters.ready {
+		return
+	}
+	if change.Previous != nil {
+		s.counters.acc.add(*change.Previous, -1)
+	}
+	s.counters.acc.add(change.Stored, 1)
+	for _, r := range evicted {
+		s.counters.acc.add(r, -1)
+	}
+}
+
+// unfilteredStats answers an unfiltered /api/stats request from the counters
+// without touching the backend.
+func (s *Store) unfilteredStats(now time.Time) (StatsResponse, error) {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	if !s.counters.ready {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := s.loadStatsCountersLocked(ctx); err != nil {
+			return StatsResponse{}, err
+		}
+	}
+	totals, startedAt := s.totals(s.counters.acc.matched, s.counters.acc.datacenter)
+	return s.counters.acc.response(now, startedAt, totals, StatsFilter{}), nil
+}
+
+// CheckStatsCounters compares the incremental counters with a full recompute
+// over the stored reports. On mismatch it returns the differing sections and
+// resyncs the counters from the recompute.
+func (s *Store) CheckStatsCounters(ctx context.Context) ([]string, error) {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	if !s.counters.ready {
+		return nil, s.loadStatsCountersLocked(ctx)
+	}
+	stored, err := s.reports.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fresh := newStatsAccumulator()
+	for _, sr := range stored {
+		fresh.add(sr.Report, 1)
+	}
+
+	now := time.Now()
+	got := s.counters.acc.response(now, now, Totals{}, StatsFilter{})
+	want := fresh.response(now, now, Totals{}, StatsFilter{})
+	sections := []struct {
+		name      string
+		got, want any
+	}{
+		{"selection", got.Selection, want.Selection},
+		{"breakdown", got.Breakdown, want.Breakdown},
+		{"webgpu", got.WebGPU, want.WebGPU},
+		{"webgl", got.WebGL, want.WebGL},
+		{"display", got.Display, want.Display},
+	}
+	var drift []string
+	for _, sec := range sections {
+		a, err := json.Marshal(sec.got)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(sec.want)
+		if err != nil {
+			return nil, err
+		}
+		if string(a) != string(b) {
+			drift = append(drift, sec.name)
+		}
+	}
+	sort.Strings(drift)
+	if len(drift) > 0 {
+		s.counters = statsCounters{ready: true, acc: fresh}
+	}
+	return drift, nil
+}
+
+func (s *Store) checkStatsCountersEvery(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		drift, err := s.CheckStatsCounters(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("stats counters check failed: %v", err)
+			continue
+		}
+		if len(drift) > 0 {
+			log.Printf("stats counters drifted (%s); resynced from a full recompute", strings.Join(drift, ", "))
+		}
+	}
+}
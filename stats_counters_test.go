@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func counterTestReport(fnv string, browser string, country string) Report {
+	return Report{
+		SchemaVersion: currentReportSchemaVersion,
+		Client: &ClientInfo{
+			Fingerprint: &ClientFingerprint{FNV1a: fnv},
+			Parsed:      &ClientParsed{Browser: &NameVersion{Name: browser}, OS: &NameVersion{Name: "Windows"}},
+		},
+		Geo:     &GeoInfo{CountryCode: country},
+		Display: &DisplayInfo{DynamicRangeHigh: true, HDRCapable: true},
+		WebGPU: WebGPUReport{
+			Available: true,
+			Limits:    &WebGPULimits{MaxTextureDimension2D: 16384},
+			Formats: []WebGPUFormat{
+				{Format: "rgba16float", Kind: "float", HDR: true, Sampled: true, Renderable: true},
+				{Format: "bc7-rgba-unorm", Kind: "float", Compressed: true, Sampled: true},
+			},
+		},
+		WebGL2: WebGLReport{
+			Available:         true,
+			Extensions:        map[string]bool{"EXT_color_buffer_float": true},
+			CompressedFormats: []string{"COMPRESSED_RGBA_S3TC_DXT5_EXT"},
+		},
+	}
+}
+
+// legacyCounterTestReport predates both schema migrations: raw WebGL enums,
+// a WebGPU format with stale metadata and one the catalog doesn't know.
+func legacyCounterTestReport(browser string) Report {
+	return Report{
+		Client: &ClientInfo{Parsed: &ClientParsed{Browser: &NameVersion{Name: browser}}},
+		Geo:    &GeoInfo{CountryCode: "DE", Datacenter: true},
+		WebGPU: WebGPUReport{
+			Available: true,
+			Formats: []WebGPUFormat{
+				{Format: "rgba16float", Kind: "unorm", Sampled: true},
+				{Format: "rgba16float", Kind: "unorm", Sampled: true},
+				{Format: "not-a-format", Kind: "float", Sampled: true},
+			},
+		},
+		WebGL2: WebGLReport{
+			Available:         true,
+			CompressedFormats: []string{"0x8c00", "COMPRESSED_RGB_PVRTC_4BPPV1_IMG", "0x8C02"},
+		},
+	}
+}
+
+// TestStatsCountersMatchRecompute drives the incremental counters through
+// new submissions, a duplicate merged into a legacy record, pruning and a
+// reseed, comparing them after each step with computeStats over Load().
+func TestStatsCountersMatchRecompute(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{MaxReports: 3, DedupeTTL: time.Hour, CleanupEvery: time.Minute, LimiterIdleTTL: time.Minute}
+
+	backends := map[string]func(t *testing.T) ReportStore{
+		"memory": func(t *testing.T) ReportStore { return newMemoryReportStore(cfg) },
+		"sqlite": func(t *testing.T) ReportStore {
+			s, err := openAndInitSQLite(ctx, filepath.Join(t.TempDir(), "reports.db"), cfg)
+			if err != nil {
+				t.Fatalf("open sqlite: %v", err)
+			}
+			t.Cleanup(func() { s.db.Close() })
+			return s
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			reports := open(t)
+			t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			// Rows written by an older detector, straight into the backend.
+			for i, fp := range []string{"fnv1a:legacy-b", "fnv1a:legacy-a"} {
+				if _, err := reports.Submit(ctx, t0.Add(time.Duration(i)*time.Second), "", fp, legacyCounterTestReport("Firefox"), nil); err != nil {
+					t.Fatalf("seed %s: %v", fp, err)
+				}
+			}
+
+			s := NewStore(cfg, reports)
+			now := t0.Add(time.Minute)
+			assertCountersMatch(t, s, now, "seeded")
+
+			submit := func(step string, r Report) submitResult {
+				t.Helper()
+				now = now.Add(time.Second)
+				res, err := s.Submit(now, "192.0.2.1", r)
+				if err != nil {
+					t.Fatalf("%s: submit: %v", step, err)
+				}
+				return res
+			}
+
+			submit("new", counterTestReport("aaa", "Chrome", "US"))
+			assertCountersMatch(t, s, now, "new")
+
+			// Same fingerprint as legacy-a, without WebGPU formats or WebGL:
+			// the merge keeps (and the counters must first remove) the
+			// stored, migrated sections.
+			dup := counterTestReport("legacy-a", "Edge", "")
+			dup.WebGPU.Formats = nil
+			dup.WebGL2 = WebGLReport{}
+			if res := submit("duplicate", dup); res.Status != "duplicate" {
+				t.Fatalf("duplicate: status %q", res.Status)
+			}
+			assertCountersMatch(t, s, now, "duplicate")
+
+			// Over MaxReports: legacy-b is evicted.
+			submit("prune", counterTestReport("bbb", "Safari", "FR"))
+			if n, _ := reports.Count(ctx); n != 3 {
+				t.Fatalf("prune: %d reports stored, want 3", n)
+			}
+			if _, ok, _ := reports.Get(ctx, "fnv1a:legacy-b"); ok {
+				t.Fatalf("prune: legacy-b still stored")
+			}
+			assertCountersMatch(t, s, now, "prune")
+
+			if drift, err := s.CheckStatsCounters(ctx); err != nil || len(drift) > 0 {
+				t.Fatalf("CheckStatsCounters = %v, %v; want no drift", drift, err)
+			}
+
+			// Reseeding after a reset (as after a failed Prune) starts over
+			// from Load().
+			s.countersMu.Lock()
+			s.counters = statsCounters{}
+			s.countersMu.Unlock()
+			assertCountersMatch(t, s, now, "reset")
+			submit("after reset", counterTestReport("ccc", "Chrome", "JP"))
+			assertCountersMatch(t, s, now, "after reset")
+		})
+	}
+}
+
+func TestCheckStatsCountersResyncsDrift(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(Config{MaxReports: 10, DedupeTTL: time.Hour, CleanupEvery: time.Minute}, nil)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := s.Submit(now, "192.0.2.1", counterTestReport("aaa", "Chrome", "US")); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := s.CheckStatsCounters(ctx); err != nil || len(drift) > 0 {
+		t.Fatalf("seed: CheckStatsCounters = %v, %v", drift, err)
+	}
+
+	// A write the counters never saw, e.g. from another instance.
+	s.countersMu.Lock()
+	s.counters.acc.add(counterTestReport("zzz", "Safari", "FR"), 1)
+	s.countersMu.Unlock()
+
+	drift, err := s.CheckStatsCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"breakdown", "display", "selection", "webgl", "webgpu"}; !slices.Equal(drift, want) {
+		t.Fatalf("drift = %v, want %v", drift, want)
+	}
+	if drift, err := s.CheckStatsCounters(ctx); err != nil || len(drift) > 0 {
+		t.Fatalf("after resync: CheckStatsCounters = %v, %v", drift, err)
+	}
+	assertCountersMatch(t, s, now, "resynced")
+}
+
+// assertCountersMatch compares the counter-backed unfiltered stats with a
+// full computeStats pass over the backend.
+func assertCountersMatch(t *testing.T, s *Store, now time.Time, step string) {
+	t.Helper()
+	got, err := s.Stats(now, StatsFilter{})
+	if err != nil {
+		t.Fatalf("%s: stats: %v", step, err)
+	}
+	totals, startedAt, reports, err := s.snapshot()
+	if err != nil {
+		t.Fatalf("%s: snapshot: %v", step, err)
+	}
+	want := computeStats(now, startedAt, totals, reports, StatsFilter{})
+
+	a, _ := json.Marshal(got)
+	b, _ := json.Marshal(want)
+	if string(a) != string(b) {
+		t.Errorf("%s: counters differ from computeStats(Load())\n got  %s\n want %s", step, a, b)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// textureUsage is the set of usages a format can support on at least some
+// devices; claims outside it are cleared on submit.
+type textureUsage uint8
+
+const (
+	textureUsageSampled textureUsage = 1 << iota
+	textureUsageRenderable
+	textureUsageStorage
+)
+
+// textureFormatInfo holds the static properties of a texture format. Kind,
+// Compressed and HDR follow the detector's classification (app.js formatKind,
+// formatIsCompressed, formatIsHdr) and replace whatever a client sends. The
+// one exception is the depth formats: formatIsHdr matches the "float" in
+// depth32float, but a depth buffer holds no color, so they are not HDR here.
+type textureFormatInfo struct {
+	Name string
+	Kind string
+	// SampleType is the WGSL sample type: float, sint, uint or depth. Only
+	// float formats can be filterable.
+	SampleType string
+	Usages     textureUsage
+	Compressed bool
+	HDR        bool
+	// HDRProfile marks ASTC formats whose HDR support depends on the device's
+	// ASTC HDR profile; the client's hdr flag is kept for them.
+	HDRProfile bool
+	// WebGLOnly marks formats only reported from WebGL (no GPUTextureFormat).
+	WebGLOnly bool
+}
+
+// textureFormats lists every format the detector reports, in the order of
+// WEBGPU_TEXTURE_FORMATS in app.js: the GPUTextureFormat values it probes,
+// followed by the WebGL-derived ETC1/PVRTC names.
+var textureFormats = []textureFormatInfo{
+	{Name: "r8unorm", Kind: "unorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r8snorm", Kind: "snorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r8uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r8sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r16uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r16sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r16float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rg8unorm", Kind: "unorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg8snorm", Kind: "snorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg8uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg8sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r32uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r32sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "r32float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rg16uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg16sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg16float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rg32uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg32sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rg32float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rgba8unorm", Kind: "unorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba8unorm-srgb", Kind: "srgb", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "rgba8snorm", Kind: "snorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba8uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba8sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "bgra8unorm", Kind: "unorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "bgra8unorm-srgb", Kind: "srgb", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "rgb9e5ufloat", Kind: "float", SampleType: "float", Usages: textureUsageSampled, HDR: true},
+	{Name: "rgb10a2uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgb10a2unorm", Kind: "unorm", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rg11b10ufloat", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rgba16uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba16sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba16float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "rgba32uint", Kind: "uint", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba32sint", Kind: "sint", SampleType: "sint", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage},
+	{Name: "rgba32float", Kind: "float", SampleType: "float", Usages: textureUsageSampled | textureUsageRenderable | textureUsageStorage, HDR: true},
+	{Name: "stencil8", Kind: "depth/stencil", SampleType: "uint", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "depth16unorm", Kind: "depth/stencil", SampleType: "depth", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "depth24plus", Kind: "depth/stencil", SampleType: "depth", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "depth24plus-stencil8", Kind: "depth/stencil", SampleType: "depth", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "depth32float", Kind: "depth/stencil", SampleType: "depth", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "depth32float-stencil8", Kind: "depth/stencil", SampleType: "depth", Usages: textureUsageSampled | textureUsageRenderable},
+	{Name: "bc1-rgba-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc1-rgba-unorm-srgb", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc2-rgba-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc2-rgba-unorm-srgb", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc3-rgba-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc3-rgba-unorm-srgb", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc4-r-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc4-r-snorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc5-rg-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc5-rg-snorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc6h-rgb-ufloat", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDR: true},
+	{Name: "bc6h-rgb-float", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDR: true},
+	{Name: "bc7-rgba-unorm", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "bc7-rgba-unorm-srgb", Kind: "compressed-bc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgb8unorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgb8unorm-srgb", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgb8a1unorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgb8a1unorm-srgb", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgba8unorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc2-rgba8unorm-srgb", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "eac-r11unorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "eac-r11snorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "eac-rg11unorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "eac-rg11snorm", Kind: "compressed-etc2/eac", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-4x4-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-4x4-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-5x4-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-5x4-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-5x5-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-5x5-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-6x5-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-6x5-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-6x6-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-6x6-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-8x5-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-8x5-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-8x6-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-8x6-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-8x8-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-8x8-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-10x5-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-10x5-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-10x6-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-10x6-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-10x8-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-10x8-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-10x10-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-10x10-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-12x10-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-12x10-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "astc-12x12-unorm", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, HDRProfile: true},
+	{Name: "astc-12x12-unorm-srgb", Kind: "compressed-astc", SampleType: "float", Usages: textureUsageSampled, Compressed: true},
+	{Name: "etc1-rgb8unorm", Kind: "compressed-etc1", SampleType: "float", Usages: textureUsageSampled, Compressed: true, WebGLOnly: true},
+	{Name: "pvrtc-rgb-2bpp-unorm", Kind: "compressed-pvrtc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, WebGLOnly: true},
+	{Name: "pvrtc-rgb-4bpp-unorm", Kind: "compressed-pvrtc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, WebGLOnly: true},
+	{Name: "pvrtc-rgba-2bpp-unorm", Kind: "compressed-pvrtc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, WebGLOnly: true},
+	{Name: "pvrtc-rgba-4bpp-unorm", Kind: "compressed-pvrtc", SampleType: "float", Usages: textureUsageSampled, Compressed: true, WebGLOnly: true},
+}
+
+// textureFormatCatalog indexes textureFormats by name.
+var textureFormatCatalog = func() map[string]textureFormatInfo {
+	m := make(map[string]textureFormatInfo, len(textureFormats))
+	for _, f := range textureFormats {
+		m[f.Name] = f
+	}
+	return m
+}()
+
+func lookupTextureFormat(name string) (textureFormatInfo, bool) {
+	info, ok := textureFormatCatalog[name]
+	return info, ok
+}
+
+// normalizeWebGPUFormats checks each entry against the catalog. Unknown
+// formats are reported as reportFieldErrors; otherwise static metadata is
+// recomputed, duplicates are dropped and usages the format can never have are
+// cleared. Every catalog format can be sampled, so a format claimed renderable
+// or storage is marked sampled too.
+func normalizeWebGPUFormats(formats []WebGPUFormat) ([]WebGPUFormat, error) {
+	var errs reportFieldErrors
+	seen := make(map[string]bool, len(formats))
+	out := make([]WebGPUFormat, 0, len(formats))
+	for i, f := range formats {
+		info, ok := lookupTextureFormat(f.Format)
+		if !ok {
+			errs = append(errs, reportFieldError{
+				Field:   fmt.Sprintf("webgpu.formats[%d].format", i),
+				Message: fmt.Sprintf("unknown texture format %q", f.Format),
+			})
+			continue
+		}
+		if seen[f.Format] {
+			continue
+		}
+		seen[f.Format] = true
+
+		f.Kind = info.Kind
+		f.Compressed = info.Compressed
+		if !info.HDRProfile {
+			f.HDR = info.HDR
+		}
+		f.Sampled = f.Sampled && info.Usages&textureUsageSampled != 0
+		f.Renderable = f.Renderable && info.Usages&textureUsageRenderable != 0
+		f.Storage = f.Storage && info.Usages&textureUsageStorage != 0
+		if f.Renderable || f.Storage {
+			f.Sampled = info.Usages&textureUsageSampled != 0
+		}
+		if info.SampleType != "float" || !f.Sampled {
+			f.Filterable = nil
+		}
+		out = append(out, f)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// migrateWebGPUFormatsToCatalog re-derives format metadata for reports
+// submitted before the server enforced the catalog. Entries that would now be
+// rejected are dropped instead, keeping the rest of the report.
+func migrateWebGPUFormatsToCatalog(r *Report) bool {
+	if len(r.WebGPU.Formats) == 0 {
+		return false
+	}
+	out := make([]WebGPUFormat, 0, len(r.WebGPU.Formats))
+	for _, f := range r.WebGPU.Formats {
+		if slices.ContainsFunc(out, func(prev WebGPUFormat) bool { return prev.Format == f.Format }) {
+			continue
+		}
+		normalized, err := normalizeWebGPUFormats([]WebGPUFormat{f})
+		if err != nil {
+			continue
+		}
+		out = append(out, normalized...)
+	}
+	if slices.EqualFunc(out, r.WebGPU.Formats, sameWebGPUFormat) {
+		return false
+	}
+	r.WebGPU.Formats = out
+	return true
+}
+
+func sameWebGPUFormat(a, b WebGPUFormat) bool {
+	return a.Format == b.Format && a.Kind == b.Kind && a.HDR == b.HDR && a.Compressed == b.Compressed &&
+		a.Sampled == b.Sampled && a.Renderable == b.Renderable && a.Storage == b.Storage &&
+		(a.Filterable == nil) == (b.Filterable == nil) && (a.Filterable == nil || *a.Filterable == *b.Filterable)
+}
+
+// formatStatMetadata fills the static FormatStat fields from the catalog.
+// Only the ASTC HDR profile still depends on what devices report.
+func formatStatMetadata(stat *FormatStat) {
+	info, ok := lookupTextureFormat(stat.Format)
+	if !ok {
+		return
+	}
+	stat.Kind = info.Kind
+	stat.Compressed = info.Compressed
+	stat.HDR = info.HDR
+	if info.HDRProfile {
+		stat.HDR = stat.HDRCount > 0
+	}
+}
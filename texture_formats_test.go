@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeWebGPUFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		in   WebGPUFormat
+		want WebGPUFormat
+	}{
+		{
+			"metadata recomputed",
+			WebGPUFormat{Format: "rgba16float", Kind: "unorm", Sampled: true, Filterable: ptr(true)},
+			WebGPUFormat{Format: "rgba16float", Kind: "float", HDR: true, Sampled: true, Filterable: ptr(true)},
+		},
+		{
+			"client hdr claim replaced",
+			WebGPUFormat{Format: "rgba8unorm-srgb", HDR: true, Compressed: true, Sampled: true},
+			WebGPUFormat{Format: "rgba8unorm-srgb", Kind: "srgb", Sampled: true},
+		},
+		{
+			"astc keeps the device's hdr profile",
+			WebGPUFormat{Format: "astc-4x4-unorm", HDR: true, Sampled: true},
+			WebGPUFormat{Format: "astc-4x4-unorm", Kind: "compressed-astc", HDR: true, Compressed: true, Sampled: true},
+		},
+		{
+			"depth formats are not hdr",
+			WebGPUFormat{Format: "depth32float", HDR: true, Sampled: true, Renderable: true},
+			WebGPUFormat{Format: "depth32float", Kind: "depth/stencil", Sampled: true, Renderable: true},
+		},
+		{
+			"impossible usages cleared",
+			WebGPUFormat{Format: "bc7-rgba-unorm", Sampled: true, Renderable: true, Storage: true},
+			WebGPUFormat{Format: "bc7-rgba-unorm", Kind: "compressed-bc", Compressed: true, Sampled: true},
+		},
+		{
+			"renderable implies sampled",
+			WebGPUFormat{Format: "rgba16float", Renderable: true},
+			WebGPUFormat{Format: "rgba16float", Kind: "float", HDR: true, Sampled: true, Renderable: true},
+		},
+		{
+			"storage implies sampled",
+			WebGPUFormat{Format: "r32float", Storage: true},
+			WebGPUFormat{Format: "r32float", Kind: "float", HDR: true, Sampled: true, Storage: true},
+		},
+		{
+			"cleared usages imply nothing",
+			WebGPUFormat{Format: "bc7-rgba-unorm", Renderable: true},
+			WebGPUFormat{Format: "bc7-rgba-unorm", Kind: "compressed-bc", Compressed: true},
+		},
+		{
+			"filterable needs a sampled float format",
+			WebGPUFormat{Format: "r8uint", Sampled: true, Filterable: ptr(true)},
+			WebGPUFormat{Format: "r8uint", Kind: "uint", Sampled: true},
+		},
+		{
+			"filterable dropped when not sampled",
+			WebGPUFormat{Format: "rgba8unorm-srgb", Filterable: ptr(false)},
+			WebGPUFormat{Format: "rgba8unorm-srgb", Kind: "srgb"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := normalizeWebGPUFormats([]WebGPUFormat{tt.in})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != 1 || !sameWebGPUFormat(got[0], tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	got, err := normalizeWebGPUFormats([]WebGPUFormat{
+		{Format: "rgba16float", Sampled: true},
+		{Format: "rgba16float", Renderable: true},
+		{Format: "r8unorm"},
+	})
+	if err != nil || len(got) != 2 || got[0].Renderable || got[1].Format != "r8unorm" {
+		t.Errorf("duplicates: got %+v, %v, want the first rgba16float entry and r8unorm", got, err)
+	}
+
+	_, err = normalizeWebGPUFormats([]WebGPUFormat{{Format: "r8unorm"}, {Format: "rgba64float"}, {Format: "bogus"}})
+	var fieldErrs reportFieldErrors
+	if !errors.As(err, &fieldErrs) || len(fieldErrs) != 2 || fieldErrs[0].Field != "webgpu.formats[1].format" || fieldErrs[1].Field != "webgpu.formats[2].format" {
+		t.Errorf("unknown formats: error = %v, want field errors for entries 1 and 2", err)
+	}
+}
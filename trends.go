@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxTrendBuckets = 1000
+
+type TrendsResponse struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Options     TrendsOptions `json:"options"`
+	Selection   Selection     `json:"selection"`
+	Buckets     []TrendBucket `json:"buckets"`
+}
+
+type TrendsOptions struct {
+	Metric string    `json:"metric"`
+	Bucket string    `json:"bucket"`
+	By     string    `json:"by"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+type TrendBucket struct {
+	Start     time.Time `json:"start"`
+	Matched   int       `json:"matched"`
+	Tested    int       `json:"tested"`
+	Supported int       `json:"supported"`
+}
+
+// trendMetric reports whether a report could answer the metric (tested) and
+// whether the answer was yes (supported).
+type trendMetric func(r Report) (tested bool, supported bool)
+
+// textureFamilyPrefixes mirrors the format families of the compat matrix.
+var textureFamilyPrefixes = map[string][]string{
+	"astc":  {"astc-"},
+	"etc2":  {"etc2-", "eac-"},
+	"etc1":  {"etc1-"},
+	"pvrtc": {"pvrtc-"},
+	"bc13":  {"bc1-", "bc2-", "bc3-"},
+	"rgtc":  {"bc4-", "bc5-"},
+	"bc6h":  {"bc6h-"},
+	"bc7":   {"bc7-"},
+}
+
+// lookupTrendMetric resolves a metric name. Supported forms:
+//
+//	webgpuAvailable, webgl2Available, webgl1Available, hdrDisplay
+//	astc, astcHdr, etc2, etc1, pvrtc, bc13, rgtc, bc6h, bc7 (any-usage family support)
+//	format:<GPUTextureFormat>, webgpuFeature:<name>, hdrVideo:<codec key>
+func lookupTrendMetric(name string) (trendMetric, bool) {
+	switch name {
+	case "webgpuAvailable":
+		return func(r Report) (bool, bool) { return true, r.WebGPU.Available }, true
+	case "webgl2Available":
+		return func(r Report) (bool, bool) { return true, r.WebGL2.Available }, true
+	case "webgl1Available":
+		return func(r Report) (bool, bool) { return true, r.WebGL1.Available }, true
+	case "hdrDisplay":
+		return func(r Report) (bool, bool) { return true, reportHDRDisplay(r) }, true
+	case "astcHdr":
+		return func(r Report) (bool, bool) {
+			astcSupported := false
+			hdrSupported := false
+			for _, f := range r.WebGPU.Formats {
+				if strings.HasPrefix(f.Format, "astc-") && formatSupportedAny(f) {
+					astcSupported = true
+					if f.HDR {
+						hdrSupported = true
+					}
+				}
+			}
+			return astcSupported, hdrSupported
+		}, true
+	}
+	if prefixes, ok := textureFamilyPrefixes[name]; ok {
+		return func(r Report) (bool, bool) {
+			tested := false
+			supported := false
+			for _, f := range r.WebGPU.Formats {
+				if !hasAnyPrefix(f.Format, prefixes) {
+					continue
+				}
+				tested = true
+				if formatSupportedAny(f) {
+					supported = true
+				}
+			}
+			return tested, supported
+		}, true
+	}
+
+	kind, arg, ok := strings.Cut(name, ":")
+	if !ok || strings.TrimSpace(arg) == "" {
+		return nil, false
+	}
+	arg = strings.TrimSpace(arg)
+	switch kind {
+	case "format":
+		return func(r Report) (bool, bool) {
+			for _, f := range r.WebGPU.Formats {
+				if f.Format == arg {
+					return true, formatSupportedAny(f)
+				}
+			}
+			return false, false
+		}, true
+	case "webgpuFeature":
+		return func(r Report) (bool, bool) {
+			return r.WebGPU.Available, reportHasWebGPUFeature(r, arg)
+		}, true
+	case "hdrVideo":
+		return func(r Report) (bool, bool) {
+			res, ok := reportHDRVideoCodec(r, arg)
+			return ok, ok && res.Supported
+		}, true
+	}
+	return nil, false
+}
+
+func formatSupportedAny(f WebGPUFormat) bool {
+	return f.Sampled || f.Renderable || f.Storage
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTrendsOptions(q url.Values, now time.Time) (TrendsOptions, error) {
+	opts := TrendsOptions{
+		Metric: strings.TrimSpace(q.Get("metric")),
+		Bucket: strings.ToLower(strings.TrimSpace(q.Get("bucket"))),
+		By:     strings.TrimSpace(q.Get("by")),
+	}
+	if opts.Metric == "" {
+		opts.Metric = "webgpuAvailable"
+	}
+	if _, ok := lookupTrendMetric(opts.Metric); !ok {
+		return TrendsOptions{}, fmt.Errorf("unknown metric %q", opts.Metric)
+	}
+
+	switch opts.Bucket {
+	case "day", "week", "month":
+		// ok
+	case "":
+		opts.Bucket = "week"
+	default:
+		return TrendsOptions{}, fmt.Errorf("bucket must be day, week or month")
+	}
+
+	switch strings.ToLower(opts.By) {
+	case "", "createdat":
+		opts.By = "createdAt"
+	case "receivedat":
+		opts.By = "receivedAt"
+	default:
+		return TrendsOptions{}, fmt.Errorf("by must be createdAt or receivedAt")
+	}
+
+	var err error
+	opts.To = now.UTC()
+	if raw := strings.TrimSpace(q.Get("to")); raw != "" {
+		if opts.To, err = parseTrendTime(raw); err != nil {
+			return TrendsOptions{}, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	opts.From = opts.To.AddDate(0, 0, -90)
+	if raw := strings.TrimSpace(q.Get("from")); raw != "" {
+		if opts.From, err = parseTrendTime(raw); err != nil {
+			return TrendsOptions{}, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if !opts.From.Before(opts.To) {
+		return TrendsOptions{}, fmt.Errorf("from must be before to")
+	}
+	n := 0
+	for t := truncateToBucket(opts.From, opts.Bucket); t.Before(opts.To); t = nextBucket(t, opts.Bucket) {
+		n += 1
+		if n > maxTrendBuckets {
+			return TrendsOptions{}, fmt.Errorf("too many buckets (max %d); narrow the range or use a larger bucket", maxTrendBuckets)
+		}
+	}
+	return opts, nil
+}
+
+func parseTrendTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// truncateToBucket returns the UTC start of the bucket containing t. Weeks
+// start on Monday.
+func truncateToBucket(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return day
+	}
+}
+
+func nextBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "month":
+		return t.AddDate(0, 1, 0)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func (s *Store) Trends(now time.Time, filter StatsFilter, opts TrendsOptions) (TrendsResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	stored, err := s.reports.Load(ctx)
+	if err != nil {
+		return TrendsResponse{}, err
+	}
+	return computeTrends(now, stored, filter, opts)
+}
+
+func computeTrends(now time.Time, stored []StoredReport, filter StatsFilter, opts TrendsOptions) (TrendsResponse, error) {
+	metric, ok := lookupTrendMetric(opts.Metric)
+	if !ok {
+		return TrendsResponse{}, fmt.Errorf("unknown metric %q", opts.Metric)
+	}
+
+	buckets := map[time.Time]*TrendBucket{}
+	first := truncateToBucket(opts.From, opts.Bucket)
+	for t := first; t.Before(opts.To) && len(buckets) < maxTrendBuckets; t = nextBucket(t, opts.Bucket) {
+		buckets[t] = &TrendBucket{Start: t}
+	}
+
+	matched := 0
+	for _, sr := range stored {
+		at := sr.CreatedAt
+		if opts.By == "receivedAt" || at.IsZero() {
+			at = sr.ReceivedAt
+		}
+		if at.Before(opts.From) || !at.Before(opts.To) {
+			continue
+		}
+		if !matchesStatsFilter(sr.Report, filter) {
+			continue
+		}
+		b := buckets[truncateToBucket(at, opts.Bucket)]
+		if b == nil {
+			continue
+		}
+		matched += 1
+		b.Matched += 1
+		tested, supported := metric(sr.Report)
+		if tested {
+			b.Tested += 1
+			if supported {
+				b.Supported += 1
+			}
+		}
+	}
+
+	out := make([]TrendBucket, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+
+	return TrendsResponse{
+		GeneratedAt: now,
+		Options:     opts,
+		Selection: Selection{
+			Matched: matched,
+			Filter:  filter,
+		},
+		Buckets: out,
+	}, nil
+}
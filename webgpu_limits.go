@@ -0,0 +1,308 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WebGPULimits mirrors GPUSupportedLimits as reported by the adapter. Zero
+// means the client did not report the limit.
+type WebGPULimits struct {
+	MaxTextureDimension1D                     int64 `json:"maxTextureDimension1D,omitempty"`
+	MaxTextureDimension2D                     int64 `json:"maxTextureDimension2D,omitempty"`
+	MaxTextureDimension3D                     int64 `json:"maxTextureDimension3D,omitempty"`
+	MaxTextureArrayLayers                     int64 `json:"maxTextureArrayLayers,omitempty"`
+	MaxBindGroups                             int64 `json:"maxBindGroups,omitempty"`
+	MaxBindGroupsPlusVertexBuffers            int64 `json:"maxBindGroupsPlusVertexBuffers,omitempty"`
+	MaxBindingsPerBindGroup                   int64 `json:"maxBindingsPerBindGroup,omitempty"`
+	MaxDynamicUniformBuffersPerPipelineLayout int64 `json:"maxDynamicUniformBuffersPerPipelineLayout,omitempty"`
+	MaxDynamicStorageBuffersPerPipelineLayout int64 `json:"maxDynamicStorageBuffersPerPipelineLayout,omitempty"`
+	MaxSampledTexturesPerShaderStage          int64 `json:"maxSampledTexturesPerShaderStage,omitempty"`
+	MaxSamplersPerShaderStage                 int64 `json:"maxSamplersPerShaderStage,omitempty"`
+	MaxStorageBuffersPerShaderStage           int64 `json:"maxStorageBuffersPerShaderStage,omitempty"`
+	MaxStorageTexturesPerShaderStage          int64 `json:"maxStorageTexturesPerShaderStage,omitempty"`
+	MaxUniformBuffersPerShaderStage           int64 `json:"maxUniformBuffersPerShaderStage,omitempty"`
+	MaxUniformBufferBindingSize               int64 `json:"maxUniformBufferBindingSize,omitempty"`
+	MaxStorageBufferBindingSize               int64 `json:"maxStorageBufferBindingSize,omitempty"`
+	MinUniformBufferOffsetAlignment           int64 `json:"minUniformBufferOffsetAlignment,omitempty"`
+	MinStorageBufferOffsetAlignment           int64 `json:"minStorageBufferOffsetAlignment,omitempty"`
+	MaxVertexBuffers                          int64 `json:"maxVertexBuffers,omitempty"`
+	MaxBufferSize                             int64 `json:"maxBufferSize,omitempty"`
+	MaxVertexAttributes                       int64 `json:"maxVertexAttributes,omitempty"`
+	MaxVertexBufferArrayStride                int64 `json:"maxVertexBufferArrayStride,omitempty"`
+	MaxInterStageShaderVariables              int64 `json:"maxInterStageShaderVariables,omitempty"`
+	MaxColorAttachments                       int64 `json:"maxColorAttachments,omitempty"`
+	MaxColorAttachmentBytesPerSample          int64 `json:"maxColorAttachmentBytesPerSample,omitempty"`
+	MaxComputeWorkgroupStorageSize            int64 `json:"maxComputeWorkgroupStorageSize,omitempty"`
+	MaxComputeInvocationsPerWorkgroup         int64 `json:"maxComputeInvocationsPerWorkgroup,omitempty"`
+	MaxComputeWorkgroupSizeX                  int64 `json:"maxComputeWorkgroupSizeX,omitempty"`
+	MaxComputeWorkgroupSizeY                  int64 `json:"maxComputeWorkgroupSizeY,omitempty"`
+	MaxComputeWorkgroupSizeZ                  int64 `json:"maxComputeWorkgroupSizeZ,omitempty"`
+	MaxComputeWorkgroupsPerDimension          int64 `json:"maxComputeWorkgroupsPerDimension,omitempty"`
+}
+
+// webgpuLimitNames lists the GPUSupportedLimits members in spec order.
+var webgpuLimitNames = []string{
+	"maxTextureDimension1D",
+	"maxTextureDimension2D",
+	"maxTextureDimension3D",
+	"maxTextureArrayLayers",
+	"maxBindGroups",
+	"maxBindGroupsPlusVertexBuffers",
+	"maxBindingsPerBindGroup",
+	"maxDynamicUniformBuffersPerPipelineLayout",
+	"maxDynamicStorageBuffersPerPipelineLayout",
+	"maxSampledTexturesPerShaderStage",
+	"maxSamplersPerShaderStage",
+	"maxStorageBuffersPerShaderStage",
+	"maxStorageTexturesPerShaderStage",
+	"maxUniformBuffersPerShaderStage",
+	"maxUniformBufferBindingSize",
+	"maxStorageBufferBindingSize",
+	"minUniformBufferOffsetAlignment",
+	"minStorageBufferOffsetAlignment",
+	"maxVertexBuffers",
+	"maxBufferSize",
+	"maxVertexAttributes",
+	"maxVertexBufferArrayStride",
+	"maxInterStageShaderVariables",
+	"maxColorAttachments",
+	"maxColorAttachmentBytesPerSample",
+	"maxComputeWorkgroupStorageSize",
+	"maxComputeInvocationsPerWorkgroup",
+	"maxComputeWorkgroupSizeX",
+	"maxComputeWorkgroupSizeY",
+	"maxComputeWorkgroupSizeZ",
+	"maxComputeWorkgroupsPerDimension",
+}
+
+// value returns the limit with the given GPUSupportedLimits name.
+func (l *WebGPULimits) value(name string) (int64, bool) {
+	if l == nil {
+		return 0, false
+	}
+	var v int64
+	switch name {
+	case "maxTextureDimension1D":
+		v = l.MaxTextureDimension1D
+	case "maxTextureDimension2D":
+		v = l.MaxTextureDimension2D
+	case "maxTextureDimension3D":
+		v = l.MaxTextureDimension3D
+	case "maxTextureArrayLayers":
+		v = l.MaxTextureArrayLayers
+	case "maxBindGroups":
+		v = l.MaxBindGroups
+	case "maxBindGroupsPlusVertexBuffers":
+		v = l.MaxBindGroupsPlusVertexBuffers
+	case "maxBindingsPerBindGroup":
+		v = l.MaxBindingsPerBindGroup
+	case "maxDynamicUniformBuffersPerPipelineLayout":
+		v = l.MaxDynamicUniformBuffersPerPipelineLayout
+	case "maxDynamicStorageBuffersPerPipelineLayout":
+		v = l.MaxDynamicStorageBuffersPerPipelineLayout
+	case "maxSampledTexturesPerShaderStage":
+		v = l.MaxSampledTexturesPerShaderStage
+	case "maxSamplersPerShaderStage":
+		v = l.MaxSamplersPerShaderStage
+	case "maxStorageBuffersPerShaderStage":
+		v = l.MaxStorageBuffersPerShaderStage
+	case "maxStorageTexturesPerShaderStage":
+		v = l.MaxStorageTexturesPerShaderStage
+	case "maxUniformBuffersPerShaderStage":
+		v = l.MaxUniformBuffersPerShaderStage
+	case "maxUniformBufferBindingSize":
+		v = l.MaxUniformBufferBindingSize
+	case "maxStorageBufferBindingSize":
+		v = l.MaxStorageBufferBindingSize
+	case "minUniformBufferOffsetAlignment":
+		v = l.MinUniformBufferOffsetAlignment
+	case "minStorageBufferOffsetAlignment":
+		v = l.MinStorageBufferOffsetAlignment
+	case "maxVertexBuffers":
+		v = l.MaxVertexBuffers
+	case "maxBufferSize":
+		v = l.MaxBufferSize
+	case "maxVertexAttributes":
+		v = l.MaxVertexAttributes
+	case "maxVertexBufferArrayStride":
+		v = l.MaxVertexBufferArrayStride
+	case "maxInterStageShaderVariables":
+		v = l.MaxInterStageShaderVariables
+	case "maxColorAttachments":
+		v = l.MaxColorAttachments
+	case "maxColorAttachmentBytesPerSample":
+		v = l.MaxColorAttachmentBytesPerSample
+	case "maxComputeWorkgroupStorageSize":
+		v = l.MaxComputeWorkgroupStorageSize
+	case "maxComputeInvocationsPerWorkgroup":
+		v = l.MaxComputeInvocationsPerWorkgroup
+	case "maxComputeWorkgroupSizeX":
+		v = l.MaxComputeWorkgroupSizeX
+	case "maxComputeWorkgroupSizeY":
+		v = l.MaxComputeWorkgroupSizeY
+	case "maxComputeWorkgroupSizeZ":
+		v = l.MaxComputeWorkgroupSizeZ
+	case "maxComputeWorkgroupsPerDimension":
+		v = l.MaxComputeWorkgroupsPerDimension
+	default:
+		return 0, false
+	}
+	return v, v > 0
+}
+
+type WebGPULimitsStats struct {
+	MaxTextureDimension1D                     []CountItem `json:"maxTextureDimension1D"`
+	MaxTextureDimension2D                     []CountItem `json:"maxTextureDimension2D"`
+	MaxTextureDimension3D                     []CountItem `json:"maxTextureDimension3D"`
+	MaxTextureArrayLayers                     []CountItem `json:"maxTextureArrayLayers"`
+	MaxBindGroups                             []CountItem `json:"maxBindGroups"`
+	MaxBindGroupsPlusVertexBuffers            []CountItem `json:"maxBindGroupsPlusVertexBuffers"`
+	MaxBindingsPerBindGroup                   []CountItem `json:"maxBindingsPerBindGroup"`
+	MaxDynamicUniformBuffersPerPipelineLayout []CountItem `json:"maxDynamicUniformBuffersPerPipelineLayout"`
+	MaxDynamicStorageBuffersPerPipelineLayout []CountItem `json:"maxDynamicStorageBuffersPerPipelineLayout"`
+	MaxSampledTexturesPerShaderStage          []CountItem `json:"maxSampledTexturesPerShaderStage"`
+	MaxSamplersPerShaderStage                 []CountItem `json:"maxSamplersPerShaderStage"`
+	MaxStorageBuffersPerShaderStage           []CountItem `json:"maxStorageBuffersPerShaderStage"`
+	MaxStorageTexturesPerShaderStage          []CountItem `json:"maxStorageTexturesPerShaderStage"`
+	MaxUniformBuffersPerShaderStage           []CountItem `json:"maxUniformBuffersPerShaderStage"`
+	MaxUniformBufferBindingSize               []CountItem `json:"maxUniformBufferBindingSize"`
+	MaxStorageBufferBindingSize               []CountItem `json:"maxStorageBufferBindingSize"`
+	MinUniformBufferOffsetAlignment           []CountItem `json:"minUniformBufferOffsetAlignment"`
+	MinStorageBufferOffsetAlignment           []CountItem `json:"minStorageBufferOffsetAlignment"`
+	MaxVertexBuffers                          []CountItem `json:"maxVertexBuffers"`
+	MaxBufferSize                             []CountItem `json:"maxBufferSize"`
+	MaxVertexAttributes                       []CountItem `json:"maxVertexAttributes"`
+	MaxVertexBufferArrayStride                []CountItem `json:"maxVertexBufferArrayStride"`
+	MaxInterStageShaderVariables              []CountItem `json:"maxInterStageShaderVariables"`
+	MaxColorAttachments                       []CountItem `json:"maxColorAttachments"`
+	MaxColorAttachmentBytesPerSample          []CountItem `json:"maxColorAttachmentBytesPerSample"`
+	MaxComputeWorkgroupStorageSize            []CountItem `json:"maxComputeWorkgroupStorageSize"`
+	MaxComputeInvocationsPerWorkgroup         []CountItem `json:"maxComputeInvocationsPerWorkgroup"`
+	MaxComputeWorkgroupSizeX                  []CountItem `json:"maxComputeWorkgroupSizeX"`
+	MaxComputeWorkgroupSizeY                  []CountItem `json:"maxComputeWorkgroupSizeY"`
+	MaxComputeWorkgroupSizeZ                  []CountItem `json:"maxComputeWorkgroupSizeZ"`
+	MaxComputeWorkgroupsPerDimension          []CountItem `json:"maxComputeWorkgroupsPerDimension"`
+}
+
+type webgpuLimitsCounter struct {
+	counts map[string]map[string]int
+}
+
+func newWebGPULimitsCounter() webgpuLimitsCounter {
+	c := webgpuLimitsCounter{counts: make(map[string]map[string]int, len(webgpuLimitNames))}
+	for _, name := range webgpuLimitNames {
+		c.counts[name] = map[string]int{}
+	}
+	return c
+}
+
+func (c *webgpuLimitsCounter) add(l *WebGPULimits, delta int) {
+	if l == nil {
+		return
+	}
+	for _, name := range webgpuLimitNames {
+		if v, ok := l.value(name); ok {
+			addCount(c.counts[name], strconv.FormatInt(v, 10), delta)
+		}
+	}
+}
+
+func (c *webgpuLimitsCounter) stats() WebGPULimitsStats {
+	return WebGPULimitsStats{
+		MaxTextureDimension1D:                     sortCounts(c.counts["maxTextureDimension1D"]),
+		MaxTextureDimension2D:                     sortCounts(c.counts["maxTextureDimension2D"]),
+		MaxTextureDimension3D:                     sortCounts(c.counts["maxTextureDimension3D"]),
+		MaxTextureArrayLayers:                     sortCounts(c.counts["maxTextureArrayLayers"]),
+		MaxBindGroups:                             sortCounts(c.counts["maxBindGroups"]),
+		MaxBindGroupsPlusVertexBuffers:            sortCounts(c.counts["maxBindGroupsPlusVertexBuffers"]),
+		MaxBindingsPerBindGroup:                   sortCounts(c.counts["maxBindingsPerBindGroup"]),
+		MaxDynamicUniformBuffersPerPipelineLayout: sortCounts(c.counts["maxDynamicUniformBuffersPerPipelineLayout"]),
+		MaxDynamicStorageBuffersPerPipelineLayout: sortCounts(c.counts["maxDynamicStorageBuffersPerPipelineLayout"]),
+		MaxSampledTexturesPerShaderStage:          sortCounts(c.counts["maxSampledTexturesPerShaderStage"]),
+		MaxSamplersPerShaderStage:                 sortCounts(c.counts["maxSamplersPerShaderStage"]),
+		MaxStorageBuffersPerShaderStage:           sortCounts(c.counts["maxStorageBuffersPerShaderStage"]),
+		MaxStorageTexturesPerShaderStage:          sortCounts(c.counts["maxStorageTexturesPerShaderStage"]),
+		MaxUniformBuffersPerShaderStage:           sortCounts(c.counts["maxUniformBuffersPerShaderStage"]),
+		MaxUniformBufferBindingSize:               sortCounts(c.counts["maxUniformBufferBindingSize"]),
+		MaxStorageBufferBindingSize:               sortCounts(c.counts["maxStorageBufferBindingSize"]),
+		MinUniformBufferOffsetAlignment:           sortCounts(c.counts["minUniformBufferOffsetAlignment"]),
+		MinStorageBufferOffsetAlignment:           sortCounts(c.counts["minStorageBufferOffsetAlignment"]),
+		MaxVertexBuffers:                          sortCounts(c.counts["maxVertexBuffers"]),
+		MaxBufferSize:                             sortCounts(c.counts["maxBufferSize"]),
+		MaxVertexAttributes:                       sortCounts(c.counts["maxVertexAttributes"]),
+		MaxVertexBufferArrayStride:                sortCounts(c.counts["maxVertexBufferArrayStride"]),
+		MaxInterStageShaderVariables:              sortCounts(c.counts["maxInterStageShaderVariables"]),
+		MaxColorAttachments:                       sortCounts(c.counts["maxColorAttachments"]),
+		MaxColorAttachmentBytesPerSample:          sortCounts(c.counts["maxColorAttachmentBytesPerSample"]),
+		MaxComputeWorkgroupStorageSize:            sortCounts(c.counts["maxComputeWorkgroupStorageSize"]),
+		MaxComputeInvocationsPerWorkgroup:         sortCounts(c.counts["maxComputeInvocationsPerWorkgroup"]),
+		MaxComputeWorkgroupSizeX:                  sortCounts(c.counts["maxComputeWorkgroupSizeX"]),
+		MaxComputeWorkgroupSizeY:                  sortCounts(c.counts["maxComputeWorkgroupSizeY"]),
+		MaxComputeWorkgroupSizeZ:                  sortCounts(c.counts["maxComputeWorkgroupSizeZ"]),
+		MaxComputeWorkgroupsPerDimension:          sortCounts(c.counts["maxComputeWorkgroupsPerDimension"]),
+	}
+}
+
+// parseWebGPUMinLimits parses "name:value" pairs (e.g. maxTextureDimension2D:16384)
+// into a filter map. Unknown limit names and malformed values are ignored.
+func parseWebGPUMinLimits(values []string) map[string]int64 {
+	out := map[string]int64{}
+	for _, raw := range splitCSVParams(values) {
+		name, val, ok := strings.Cut(raw, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !isWebGPULimitName(name) {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil || v < 0 {
+			continue
+		}
+		out[name] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func isWebGPULimitName(name string) bool {
+	for _, n := range webgpuLimitNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// webgpuLimitLowerIsBetter reports whether a smaller value of the limit is
+// the more capable one: the minUniformBufferOffsetAlignment and
+// minStorageBufferOffsetAlignment limits, for which a webgpuMinLimit filter
+// value is a maximum.
+func webgpuLimitLowerIsBetter(name string) bool {
+	return strings.HasPrefix(name, "min")
+}
+
+// reportMeetsWebGPUMinLimits reports whether every named limit is at least
+// as capable as the given value. Unreported limits never match.
+func reportMeetsWebGPUMinLimits(r Report, mins map[string]int64) bool {
+	if len(mins) == 0 {
+		return true
+	}
+	if !r.WebGPU.Available || r.WebGPU.Limits == nil {
+		return false
+	}
+	for name, bound := range mins {
+		v, ok := r.WebGPU.Limits.value(name)
+		if !ok {
+			return false
+		}
+		if webgpuLimitLowerIsBetter(name) && v > bound || !webgpuLimitLowerIsBetter(name) && v < bound {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestReportMeetsWebGPUMinLimits(t *testing.T) {
+	r := Report{WebGPU: WebGPUReport{Available: true, Limits: &WebGPULimits{
+		MaxTextureDimension2D:           8192,
+		MinUniformBufferOffsetAlignment: 256,
+		MinStorageBufferOffsetAlignment: 32,
+	}}}
+
+	tests := []struct {
+		limits map[string]int64
+		want   bool
+	}{
+		{nil, true},
+		{map[string]int64{"maxTextureDimension2D": 8192}, true},
+		{map[string]int64{"maxTextureDimension2D": 4096}, true},
+		{map[string]int64{"maxTextureDimension2D": 16384}, false},
+		// Alignments: smaller is better, so the filter value is a maximum.
+		{map[string]int64{"minUniformBufferOffsetAlignment": 256}, true},
+		{map[string]int64{"minUniformBufferOffsetAlignment": 512}, true},
+		{map[string]int64{"minUniformBufferOffsetAlignment": 64}, false},
+		{map[string]int64{"minStorageBufferOffsetAlignment": 32, "maxTextureDimension2D": 8192}, true},
+		{map[string]int64{"minStorageBufferOffsetAlignment": 16, "maxTextureDimension2D": 8192}, false},
+		// Unreported limits never match.
+		{map[string]int64{"maxBufferSize": 0}, false},
+	}
+	for _, tt := range tests {
+		if got := reportMeetsWebGPUMinLimits(r, tt.limits); got != tt.want {
+			t.Errorf("reportMeetsWebGPUMinLimits(%v) = %v, want %v", tt.limits, got, tt.want)
+		}
+	}
+
+	unavailable := Report{WebGPU: WebGPUReport{Limits: r.WebGPU.Limits}}
+	if reportMeetsWebGPUMinLimits(unavailable, map[string]int64{"maxTextureDimension2D": 1}) {
+		t.Error("limits of an unavailable adapter matched")
+	}
+}